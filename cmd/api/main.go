@@ -1,17 +1,37 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"instashop/internal/config"
 	"instashop/internal/server"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
 
-	server := server.NewServer()
-
-	err := server.ListenAndServe()
+	cfg, err := config.Load()
 	if err != nil {
-		panic(fmt.Sprintf("cannot start server: %s", err))
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// SIGINT or SIGTERM starts a graceful shutdown; a second one kills the
+	// process as usual because stop restores the default handling
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := server.NewServer(cfg).Run(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,387 @@
+// Package config loads the application settings once at startup. Settings
+// come from, in increasing order of priority: the defaults below, an optional
+// YAML file, a .env file and the process environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+
+	"instashop/internal/validation"
+)
+
+// DefaultFile is the YAML file read when CONFIG_FILE is not set. It is
+// optional; CONFIG_FILE, when set, must exist.
+const DefaultFile = "config.yaml"
+
+// Config holds every setting of the application. Each field names its
+// environment variable in the env tag and its default in the default tag.
+type Config struct {
+	ServiceName string   `yaml:"service_name" env:"SERVICE_NAME" default:"instashop"`
+	Server      Server   `yaml:"server"`
+	Database    Database `yaml:"database"`
+	JWT         JWT      `yaml:"jwt"`
+	Mail        Mail     `yaml:"mail"`
+	Storage     Storage  `yaml:"storage"`
+	Payment     Payment  `yaml:"payment"`
+	Admin       Admin    `yaml:"admin"`
+}
+
+// Server configures the HTTP server. ShutdownTimeout bounds how long
+// in-flight requests and queued work get to finish on shutdown, and
+// HealthCheckTimeout how long each readiness check may take.
+type Server struct {
+	Port               int           `yaml:"port" env:"PORT" default:"8080"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"15s"`
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+}
+
+type Database struct {
+	Host            string        `yaml:"host" env:"DB_HOST"`
+	Port            int           `yaml:"port" env:"DB_PORT" default:"5432"`
+	Username        string        `yaml:"username" env:"DB_USERNAME"`
+	Password        string        `yaml:"password" env:"DB_PASSWORD"`
+	Name            string        `yaml:"name" env:"DB_DATABASE"`
+	SSLMode         string        `yaml:"ssl_mode" env:"DB_SSLMODE" default:"disable"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"50"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"50"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m"`
+}
+
+// DSN returns the Postgres connection URL for the database.
+func (d Database) DSN() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.Username, d.Password),
+		Host:     net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		Path:     "/" + d.Name,
+		RawQuery: url.Values{"sslmode": {d.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// JWT configures access tokens. The same secret signs tokens and verifies
+// them in the auth middleware.
+type JWT struct {
+	Secret string `yaml:"secret" env:"JWT_SECRET"`
+}
+
+// Mail configures how email is delivered. The smtp transport sends through
+// the SMTP server; the file transport writes .eml files to FileDir instead.
+// Queued emails are sent by Workers goroutines and retried up to MaxAttempts
+// times, waiting RetryBackoff, then twice as long each time, up to
+// MaxRetryBackoff.
+type Mail struct {
+	Transport       string        `yaml:"transport" env:"MAIL_TRANSPORT" default:"smtp"`
+	Host            string        `yaml:"host" env:"MAILTRAP_HOST"`
+	Port            int           `yaml:"port" env:"MAILTRAP_PORT" default:"587"`
+	Username        string        `yaml:"username" env:"MAILTRAP_USER"`
+	Password        string        `yaml:"password" env:"MAILTRAP_PASS"`
+	Sender          string        `yaml:"sender" env:"SENDER"`
+	FileDir         string        `yaml:"file_dir" env:"MAIL_FILE_DIR" default:"mail"`
+	Workers         int           `yaml:"workers" env:"MAIL_WORKERS" default:"2"`
+	MaxAttempts     int           `yaml:"max_attempts" env:"MAIL_MAX_ATTEMPTS" default:"5"`
+	PollInterval    time.Duration `yaml:"poll_interval" env:"MAIL_POLL_INTERVAL" default:"2s"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff" env:"MAIL_RETRY_BACKOFF" default:"30s"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff" env:"MAIL_MAX_RETRY_BACKOFF" default:"1h"`
+}
+
+// Storage selects where uploads are kept. The local driver stores them in
+// UploadDir and serves them under UploadBaseURL.
+type Storage struct {
+	Driver        string     `yaml:"driver" env:"STORAGE_DRIVER" default:"local"`
+	UploadDir     string     `yaml:"upload_dir" env:"UPLOAD_DIR" default:"uploads"`
+	UploadBaseURL string     `yaml:"upload_base_url" env:"UPLOAD_BASE_URL" default:"/uploads"`
+	Cloudinary    Cloudinary `yaml:"cloudinary"`
+}
+
+type Cloudinary struct {
+	CloudName string `yaml:"cloud_name" env:"CLOUDINARY_CLOUD_NAME"`
+	APIKey    string `yaml:"api_key" env:"CLOUDINARY_API_KEY"`
+	APISecret string `yaml:"api_secret" env:"CLOUDINARY_API_SECRET"`
+	Folder    string `yaml:"folder" env:"CLOUDINARY_FOLDER" default:"instashop"`
+}
+
+// Payment selects the payment provider. "fake" is for local development.
+type Payment struct {
+	Provider          string `yaml:"provider" env:"PAYMENT_PROVIDER" default:"paystack"`
+	Currency          string `yaml:"currency" env:"PAYMENT_CURRENCY" default:"NGN"`
+	CallbackURL       string `yaml:"callback_url" env:"PAYMENT_CALLBACK_URL"`
+	WebhookSecret     string `yaml:"webhook_secret" env:"PAYMENT_WEBHOOK_SECRET"`
+	PaystackSecretKey string `yaml:"paystack_secret_key" env:"PAYSTACK_SECRET_KEY"`
+	PaystackBaseURL   string `yaml:"paystack_base_url" env:"PAYSTACK_BASE_URL"`
+}
+
+// Admin configures admin invites. BootstrapToken allows creating the first
+// admin and may be left empty once one exists.
+type Admin struct {
+	InviteURL      string `yaml:"invite_url" env:"ADMIN_INVITE_URL"`
+	BootstrapToken string `yaml:"bootstrap_token" env:"ADMIN_BOOTSTRAP_TOKEN"`
+}
+
+// ValidationError lists every setting that is missing or has an invalid
+// value, so they can all be fixed at once.
+type ValidationError struct {
+	Missing []string
+	Invalid []string
+}
+
+func (e *ValidationError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing required settings: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "invalid settings: "+strings.Join(e.Invalid, "; "))
+	}
+	return "config: " + strings.Join(parts, "; ")
+}
+
+// Load reads the configuration from the YAML file named by CONFIG_FILE (or
+// DefaultFile if it exists), the .env file and the environment, and
+// validates it.
+func Load() (*Config, error) {
+	data, err := read()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, os.LookupEnv)
+}
+
+// LoadDatabase is Load for commands that only need the database, such as
+// migrate: it reads the same sources but only validates the database
+// settings.
+func LoadDatabase() (*Database, error) {
+	data, err := read()
+	if err != nil {
+		return nil, err
+	}
+	return ParseDatabase(data, os.LookupEnv)
+}
+
+// read loads the .env file into the environment and returns the YAML file,
+// which is empty when there is none.
+func read() ([]byte, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("config: failed to read .env: %w", err)
+	}
+
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = DefaultFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("config: failed to read %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// Parse builds the configuration from YAML data, which may be empty, and
+// the variables returned by lookupEnv, and validates it.
+func Parse(data []byte, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := &Config{}
+	verr := &ValidationError{}
+	if err := decode(cfg, reflect.ValueOf(cfg).Elem(), data, lookupEnv, verr); err != nil {
+		return nil, err
+	}
+
+	cfg.validate(verr)
+	if len(verr.Missing) > 0 || len(verr.Invalid) > 0 {
+		return nil, verr
+	}
+	return cfg, nil
+}
+
+// ParseDatabase is Parse for the database settings alone. Settings of the
+// other sections are neither required nor checked.
+func ParseDatabase(data []byte, lookupEnv func(string) (string, bool)) (*Database, error) {
+	cfg := &Config{}
+	verr := &ValidationError{}
+	if err := decode(cfg, reflect.ValueOf(&cfg.Database).Elem(), data, lookupEnv, verr); err != nil {
+		return nil, err
+	}
+
+	cfg.Database.validate(verr)
+	if len(verr.Missing) > 0 || len(verr.Invalid) > 0 {
+		return nil, verr
+	}
+	return &cfg.Database, nil
+}
+
+// decode fills cfg from the defaults, the YAML data and then the environment.
+// Defaults and environment variables are only applied to the settings in
+// section, which is cfg itself or one of its sections; values that do not
+// parse are recorded in verr.
+func decode(cfg *Config, section reflect.Value, data []byte, lookupEnv func(string) (string, bool), verr *ValidationError) error {
+	walk(section, func(field reflect.Value, tag reflect.StructTag) {
+		if value, ok := tag.Lookup("default"); ok {
+			if err := setValue(field, value); err != nil {
+				panic(fmt.Sprintf("config: bad default for %s: %v", tag.Get("env"), err))
+			}
+		}
+	})
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("config: invalid YAML: %w", err)
+	}
+
+	walk(section, func(field reflect.Value, tag reflect.StructTag) {
+		name := tag.Get("env")
+		value, ok := lookupEnv(name)
+		if !ok || value == "" {
+			return
+		}
+		if err := setValue(field, value); err != nil {
+			verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s: %v", name, err))
+		}
+	})
+	return nil
+}
+
+// validate records the settings that are required, including the ones only
+// required by the chosen storage and payment drivers.
+func (c *Config) validate(verr *ValidationError) {
+	require := func(name, value string) {
+		if strings.TrimSpace(value) == "" {
+			verr.Missing = append(verr.Missing, name)
+		}
+	}
+	positive := func(name string, value int) {
+		if value <= 0 {
+			verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s: must be positive", name))
+		}
+	}
+	positiveDuration := func(name string, value time.Duration) {
+		if value <= 0 {
+			verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s: must be positive", name))
+		}
+	}
+
+	positive("PORT", c.Server.Port)
+	positiveDuration("SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+	positiveDuration("HEALTH_CHECK_TIMEOUT", c.Server.HealthCheckTimeout)
+
+	c.Database.validate(verr)
+
+	require("JWT_SECRET", c.JWT.Secret)
+
+	switch c.Mail.Transport {
+	case "smtp":
+		require("MAILTRAP_HOST", c.Mail.Host)
+		positive("MAILTRAP_PORT", c.Mail.Port)
+	case "file":
+		require("MAIL_FILE_DIR", c.Mail.FileDir)
+	default:
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("MAIL_TRANSPORT: unknown transport %q, want smtp or file", c.Mail.Transport))
+	}
+	require("SENDER", c.Mail.Sender)
+	positive("MAIL_WORKERS", c.Mail.Workers)
+	positive("MAIL_MAX_ATTEMPTS", c.Mail.MaxAttempts)
+	positiveDuration("MAIL_POLL_INTERVAL", c.Mail.PollInterval)
+	positiveDuration("MAIL_RETRY_BACKOFF", c.Mail.RetryBackoff)
+	if c.Mail.MaxRetryBackoff < c.Mail.RetryBackoff {
+		verr.Invalid = append(verr.Invalid, "MAIL_MAX_RETRY_BACKOFF: must be at least MAIL_RETRY_BACKOFF")
+	}
+
+	switch c.Storage.Driver {
+	case "local":
+		require("UPLOAD_DIR", c.Storage.UploadDir)
+	case "cloudinary":
+		require("CLOUDINARY_CLOUD_NAME", c.Storage.Cloudinary.CloudName)
+		require("CLOUDINARY_API_KEY", c.Storage.Cloudinary.APIKey)
+		require("CLOUDINARY_API_SECRET", c.Storage.Cloudinary.APISecret)
+	default:
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("STORAGE_DRIVER: unknown driver %q, want local or cloudinary", c.Storage.Driver))
+	}
+
+	switch c.Payment.Provider {
+	case "paystack":
+		require("PAYSTACK_SECRET_KEY", c.Payment.PaystackSecretKey)
+	case "fake":
+		require("PAYMENT_WEBHOOK_SECRET", c.Payment.WebhookSecret)
+	default:
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("PAYMENT_PROVIDER: unknown provider %q, want paystack or fake", c.Payment.Provider))
+	}
+	require("PAYMENT_CURRENCY", c.Payment.Currency)
+	if c.Payment.Currency != "" && !validation.IsCurrency(c.Payment.Currency) {
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("PAYMENT_CURRENCY: %q is not a three-letter currency code", c.Payment.Currency))
+	}
+}
+
+// validate records the database settings that are missing or invalid.
+func (d *Database) validate(verr *ValidationError) {
+	for _, setting := range []struct{ name, value string }{
+		{"DB_HOST", d.Host},
+		{"DB_USERNAME", d.Username},
+		{"DB_DATABASE", d.Name},
+	} {
+		if strings.TrimSpace(setting.value) == "" {
+			verr.Missing = append(verr.Missing, setting.name)
+		}
+	}
+	if d.Port <= 0 {
+		verr.Invalid = append(verr.Invalid, "DB_PORT: must be positive")
+	}
+	if d.MaxOpenConns <= 0 {
+		verr.Invalid = append(verr.Invalid, "DB_MAX_OPEN_CONNS: must be positive")
+	}
+	if d.MaxIdleConns < 0 {
+		verr.Invalid = append(verr.Invalid, "DB_MAX_IDLE_CONNS: must not be negative")
+	}
+}
+
+// walk calls fn for every setting, i.e. every field with an env tag, in the
+// nested structs of v.
+func walk(v reflect.Value, fn func(field reflect.Value, tag reflect.StructTag)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		tag := t.Field(i).Tag
+		if _, ok := tag.Lookup("env"); ok {
+			fn(field, tag)
+		} else if field.Kind() == reflect.Struct {
+			walk(field, fn)
+		}
+	}
+}
+
+// setValue parses value into a setting of type string, int, bool or
+// time.Duration.
+func setValue(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
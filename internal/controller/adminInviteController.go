@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/service"
+	"instashop/internal/utils"
+)
+
+type AdminInviteController struct {
+	AdminInviteService *service.AdminInviteService
+}
+
+func NewAdminInviteController(adminInviteService *service.AdminInviteService) *AdminInviteController {
+	return &AdminInviteController{AdminInviteService: adminInviteService}
+}
+
+type CreateInviteRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// CreateInviteHandler emails an admin invite on behalf of the current admin
+func (ctrl *AdminInviteController) CreateInviteHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req CreateInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	invite, err := ctrl.AdminInviteService.CreateInvite(c.Request.Context(), principal.UserID, req.Email)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invite": invite})
+}
+
+type BootstrapInviteRequest struct {
+	Email          string `json:"email" binding:"required,email"`
+	BootstrapToken string `json:"bootstrap_token" binding:"required"`
+}
+
+// BootstrapInviteHandler emails the invite for the first admin. It only works
+// with the configured bootstrap token and while no admin exists.
+func (ctrl *AdminInviteController) BootstrapInviteHandler(c *gin.Context) {
+	var req BootstrapInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	invite, err := ctrl.AdminInviteService.CreateBootstrapInvite(c.Request.Context(), req.BootstrapToken, req.Email)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invite": invite})
+}
+
+type AcceptInviteRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"omitempty,min=3,max=50"`
+	Password string `json:"password" binding:"required"`
+}
+
+// AcceptInviteHandler redeems an admin invite, creating or promoting the
+// invited account
+func (ctrl *AdminInviteController) AcceptInviteHandler(c *gin.Context) {
+	var req AcceptInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := ctrl.AdminInviteService.AcceptInvite(c.Request.Context(), req.Token, req.Username, req.Password)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invite accepted",
+		"user":    gin.H{"id": user.ID, "username": user.Username, "email": user.Email, "role": user.Role},
+	})
+}
+
+type ChangeRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user editor admin"`
+}
+
+// ChangeRoleHandler sets the role of a user
+func (ctrl *AdminInviteController) ChangeRoleHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid user ID format"))
+		return
+	}
+
+	var req ChangeRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := ctrl.AdminInviteService.ChangeRole(c.Request.Context(), principal.UserID, uint(userID), req.Role)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": gin.H{"id": user.ID, "username": user.Username, "email": user.Email, "role": user.Role},
+	})
+}
+
+// RoleChangesHandler returns the audit trail of a user's role changes
+func (ctrl *AdminInviteController) RoleChangesHandler(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid user ID format"))
+		return
+	}
+
+	changes, err := ctrl.AdminInviteService.ListRoleChanges(c.Request.Context(), uint(userID))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role_changes": changes})
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/utils"
+	"instashop/internal/validation"
+)
+
+// bindJSON decodes the JSON body into req and checks it against req's binding
+// tags. Fields req does not declare are rejected, so clients cannot set
+// fields such as prices or owners the server decides. On failure it pushes a
+// 400 for a body that is not JSON, or a 422 listing each failing field.
+func bindJSON(c *gin.Context, req interface{}) bool {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(req); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		var syntaxErr *json.SyntaxError
+		switch {
+		case errors.Is(err, io.EOF):
+			_ = c.Error(utils.NewBadRequestError("Request body is required"))
+		case errors.As(err, &typeErr):
+			_ = c.Error(invalidPayload(validation.FieldError{Field: typeErr.Field, Reason: "must be " + jsonType(typeErr.Type)}))
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			_ = c.Error(invalidPayload(validation.FieldError{Field: field, Reason: "is not allowed"}))
+		case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+			_ = c.Error(utils.NewBadRequestError("Request body is not valid JSON"))
+		default:
+			_ = c.Error(utils.NewBadRequestError("Invalid request payload"))
+		}
+		return false
+	}
+
+	if failures := validation.Struct(req); failures != nil {
+		_ = c.Error(invalidPayload(failures...))
+		return false
+	}
+	return true
+}
+
+func invalidPayload(failures ...validation.FieldError) *utils.CustomError {
+	return utils.NewValidationError("Invalid request payload").WithDetails(failures)
+}
+
+// jsonType names the JSON type a Go type is decoded from.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "a whole number"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a positive whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/service"
+	"instashop/internal/utils"
+)
+
+type CartController struct {
+	CartService *service.CartService
+}
+
+func NewCartController(cartService *service.CartService) *CartController {
+	return &CartController{CartService: cartService}
+}
+
+type AddCartItemRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	VariantID uint `json:"variant_id"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// GetCart returns the user's cart with current prices and totals
+func (ctrl *CartController) GetCart(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	cart, err := ctrl.CartService.GetCart(c.Request.Context(), principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cart": cart})
+}
+
+// AddItem adds a product to the user's cart
+func (ctrl *CartController) AddItem(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req AddCartItemRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := ctrl.CartService.AddItem(c.Request.Context(), principal.UserID, req.ProductID, req.VariantID, req.Quantity); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	ctrl.GetCart(c)
+}
+
+// UpdateItem changes the quantity of a product in the user's cart. The
+// variant_id query parameter picks a variant of the product.
+func (ctrl *CartController) UpdateItem(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, err := strconv.ParseUint(c.Param("productID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid product ID format"))
+		return
+	}
+
+	variantID, ok := variantIDQuery(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateCartItemRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := ctrl.CartService.UpdateItem(c.Request.Context(), principal.UserID, uint(productID), variantID, req.Quantity); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	ctrl.GetCart(c)
+}
+
+// RemoveItem removes a product from the user's cart. The variant_id query
+// parameter picks a variant of the product.
+func (ctrl *CartController) RemoveItem(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, err := strconv.ParseUint(c.Param("productID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid product ID format"))
+		return
+	}
+
+	variantID, ok := variantIDQuery(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.CartService.RemoveItem(c.Request.Context(), principal.UserID, uint(productID), variantID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	ctrl.GetCart(c)
+}
+
+// Checkout places an order for everything in the user's cart
+func (ctrl *CartController) Checkout(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	order, err := ctrl.CartService.Checkout(c.Request.Context(), principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"order": order})
+}
+
+// variantIDQuery parses the optional variant_id query parameter, writing a 400
+// response when it is malformed. It returns 0 when the parameter is absent.
+func variantIDQuery(c *gin.Context) (uint, bool) {
+	raw := c.Query("variant_id")
+	if raw == "" {
+		return 0, true
+	}
+	variantID, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid variant ID format"))
+		return 0, false
+	}
+	return uint(variantID), true
+}
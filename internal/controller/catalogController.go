@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/service"
+	"instashop/internal/utils"
+)
+
+type CatalogController struct {
+	CatalogService *service.CatalogService
+}
+
+func NewCatalogController(catalogService *service.CatalogService) *CatalogController {
+	return &CatalogController{CatalogService: catalogService}
+}
+
+// ListProducts handles the public catalog. Supports q (full-text search),
+// category (slug, including subcategories), tag (slug), min_price, max_price,
+// sort (newest, price_asc, price_desc), cursor and limit.
+func (ctrl *CatalogController) ListProducts(c *gin.Context) {
+	query, ok := parseCatalogQuery(c)
+	if !ok {
+		return
+	}
+
+	page, err := ctrl.CatalogService.ListProducts(c.Request.Context(), query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// parseCatalogQuery reads the catalog query parameters, writing a 400
+// response when one is malformed.
+func parseCatalogQuery(c *gin.Context) (service.CatalogQuery, bool) {
+	query := service.CatalogQuery{
+		Search:   c.Query("q"),
+		Category: c.Query("category"),
+		Tag:      c.Query("tag"),
+		Sort:     c.Query("sort"),
+		Cursor:   c.Query("cursor"),
+	}
+
+	if minPriceStr := c.Query("min_price"); minPriceStr != "" {
+		minPrice, err := strconv.ParseFloat(minPriceStr, 64)
+		if err != nil {
+			_ = c.Error(utils.NewBadRequestError("Invalid min_price"))
+			return query, false
+		}
+		query.MinPrice = &minPrice
+	}
+
+	if maxPriceStr := c.Query("max_price"); maxPriceStr != "" {
+		maxPrice, err := strconv.ParseFloat(maxPriceStr, 64)
+		if err != nil {
+			_ = c.Error(utils.NewBadRequestError("Invalid max_price"))
+			return query, false
+		}
+		query.MaxPrice = &maxPrice
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			_ = c.Error(utils.NewBadRequestError("Invalid limit"))
+			return query, false
+		}
+		query.Limit = limit
+	}
+
+	return query, true
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/service"
+	"instashop/internal/utils"
+)
+
+type CategoryController struct {
+	CategoryService *service.CategoryService
+}
+
+func NewCategoryController(categoryService *service.CategoryService) *CategoryController {
+	return &CategoryController{CategoryService: categoryService}
+}
+
+// ListCategories returns the category tree
+func (ctrl *CategoryController) ListCategories(c *gin.Context) {
+	categories, err := ctrl.CategoryService.ListCategories(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// CreateCategory adds a category, optionally under a parent category
+func (ctrl *CategoryController) CreateCategory(c *gin.Context) {
+	var req service.CategoryInput
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	category, err := ctrl.CategoryService.CreateCategory(c.Request.Context(), req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"category": category})
+}
+
+// UpdateCategory renames or moves a category. parent_id 0 moves it to the
+// top level.
+func (ctrl *CategoryController) UpdateCategory(c *gin.Context) {
+	categoryID, ok := categoryIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.CategoryUpdate
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	category, err := ctrl.CategoryService.UpdateCategory(c.Request.Context(), categoryID, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"category": category})
+}
+
+// DeleteCategory removes a category without subcategories
+func (ctrl *CategoryController) DeleteCategory(c *gin.Context) {
+	categoryID, ok := categoryIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.CategoryService.DeleteCategory(c.Request.Context(), categoryID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+// categoryIDParam parses the categoryID path parameter, writing a 400
+// response when it is malformed.
+func categoryIDParam(c *gin.Context) (uint, bool) {
+	categoryID, err := strconv.ParseUint(c.Param("categoryID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid category ID format"))
+		return 0, false
+	}
+	return uint(categoryID), true
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/service"
+)
+
+type HealthController struct {
+	HealthService *service.HealthService
+}
+
+func NewHealthController(healthService *service.HealthService) *HealthController {
+	return &HealthController{HealthService: healthService}
+}
+
+// Liveness reports the process is up. It checks no dependencies, so a
+// database outage does not get the process restarted.
+func (ctrl *HealthController) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness reports whether the dependencies are reachable, with a 503 when
+// one is down so no traffic is routed here. Error details are left to the
+// admin endpoint.
+func (ctrl *HealthController) Readiness(c *gin.Context) {
+	readiness := ctrl.HealthService.Ready(c.Request.Context())
+
+	checks := make(map[string]string, len(readiness.Checks))
+	for name, result := range readiness.Checks {
+		checks[name] = result.Status
+	}
+
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{"ready": readiness.Ready, "checks": checks})
+}
+
+// Details returns every check with its error and latency, and the database
+// connection pool statistics
+func (ctrl *HealthController) Details(c *gin.Context) {
+	readiness := ctrl.HealthService.Ready(c.Request.Context())
+
+	c.JSON(http.StatusOK, gin.H{
+		"ready":    readiness.Ready,
+		"checks":   readiness.Checks,
+		"database": ctrl.HealthService.PoolStats(),
+	})
+}
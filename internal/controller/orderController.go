@@ -1,156 +1,219 @@
 package controller
 
 import (
+	"context"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"instashop/internal/middleware"
 	"instashop/internal/model"
 	"instashop/internal/service"
+	"instashop/internal/utils"
 )
 
+// OrderService is what OrderController needs from service.OrderService, so
+// the controller can be tested without a database.
+type OrderService interface {
+	PlaceOrder(ctx context.Context, userID uint, items []service.OrderItemInput) (*model.Order, error)
+	ListOrders(ctx context.Context, userID uint) ([]model.Order, error)
+	CancelOrder(ctx context.Context, orderID uint, userID uint) error
+	ListAllOrders(ctx context.Context, filter service.OrderFilter) ([]model.Order, error)
+	UpdateOrderStatus(ctx context.Context, orderID uint, status model.OrderStatusType, changedBy uint) (*model.Order, error)
+	GetOrderHistory(ctx context.Context, orderID uint, userID uint, canViewAll bool) ([]model.OrderStatusHistory, error)
+}
+
 type OrderController struct {
-	OrderService *service.OrderService
+	OrderService OrderService
 }
 
-func NewOrderController(orderService *service.OrderService) *OrderController {
+func NewOrderController(orderService OrderService) *OrderController {
 	return &OrderController{OrderService: orderService}
 }
 
+type PlaceOrderRequest struct {
+	Items []service.OrderItemInput `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
 func (ctrl *OrderController) PlaceOrderHandler(c *gin.Context) {
-	// Extract userID from the context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	principal, ok := currentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	userIDStr, ok := userID.(string)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+	// Parse the order payload
+	var req PlaceOrderRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	// Convert userIDStr to uint
-	userIDUint, err := strconv.ParseUint(userIDStr, 10, 32)
+	// Call the service to place the order
+	placedOrder, err := ctrl.OrderService.PlaceOrder(c.Request.Context(), principal.UserID, req.Items)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
+		_ = c.Error(err)
 		return
 	}
 
-	// Extract role from the context
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
-		return
-	}
+	c.JSON(http.StatusCreated, gin.H{"order": placedOrder})
+}
 
-	roleStr, ok := role.(string)
+// ListOrdersHandler handles fetching all orders for a specific user
+func (ctrl *OrderController) ListOrdersHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
 	if !ok {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
 		return
 	}
 
-	// Check if the user has the required role to create a product
-	if roleStr != "admin" && roleStr != "editor" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to place an order"})
+	// Call the service to list orders
+	orders, err := ctrl.OrderService.ListOrders(c, principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	// Parse the order payload
-	var order model.Order
-	if err := c.ShouldBindJSON(&order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+// CancelOrderHandler handles canceling an order
+func (ctrl *OrderController) CancelOrderHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	// Set the UserID to the extracted user ID
-	order.UserID = uint(userIDUint)
+	// Extract orderID from the URL
+	orderIDStr := c.Param("orderID")
+	orderID, err := strconv.ParseUint(orderIDStr, 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid order ID"))
+		return
+	}
 
-	// Call the service to place the order
-	placedOrder, err := ctrl.OrderService.PlaceOrder(c, &order)
+	// Call the service to cancel the order
+	err = ctrl.OrderService.CancelOrder(c.Request.Context(), uint(orderID), principal.UserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place order"})
+		_ = c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"order": placedOrder})
+	c.JSON(http.StatusOK, gin.H{"message": "Order canceled successfully"})
 }
 
-// ListOrdersHandler handles fetching all orders for a specific user
-func (ctrl *OrderController) ListOrdersHandler(c *gin.Context) {
-	// Extract userID from the context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
+// ListAllOrdersHandler lists orders across all users for admins. Supports
+// status, user_id, from and to (YYYY-MM-DD, inclusive) query filters.
+func (ctrl *OrderController) ListAllOrdersHandler(c *gin.Context) {
+	filter := service.OrderFilter{
+		Status: model.OrderStatusType(c.Query("status")),
 	}
 
-	userIDUint, err := strconv.ParseUint(userID.(string), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
-		return
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			_ = c.Error(utils.NewBadRequestError("Invalid user ID format"))
+			return
+		}
+		filter.UserID = uint(userID)
 	}
 
-	// Call the service to list orders
-	orders, err := ctrl.OrderService.ListOrders(c, uint(userIDUint))
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := time.Parse(time.DateOnly, fromStr)
+		if err != nil {
+			_ = c.Error(utils.NewBadRequestError("Invalid from date, expected YYYY-MM-DD"))
+			return
+		}
+		filter.From = from
+	}
+
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.Parse(time.DateOnly, toStr)
+		if err != nil {
+			_ = c.Error(utils.NewBadRequestError("Invalid to date, expected YYYY-MM-DD"))
+			return
+		}
+		// Include the whole of the "to" day
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	orders, err := ctrl.OrderService.ListAllOrders(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+		_ = c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"orders": orders})
 }
 
-// CancelOrderHandler handles canceling an order
-func (ctrl *OrderController) CancelOrderHandler(c *gin.Context) {
-	// Extract userID from the context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+// ApproveOrderHandler approves a pending order
+func (ctrl *OrderController) ApproveOrderHandler(c *gin.Context) {
+	ctrl.updateOrderStatus(c, model.OrderStatusApproved)
+}
+
+// DeclineOrderHandler declines an order and releases its stock
+func (ctrl *OrderController) DeclineOrderHandler(c *gin.Context) {
+	ctrl.updateOrderStatus(c, model.OrderStatusDeclined)
+}
+
+type UpdateOrderStatusRequest struct {
+	Status model.OrderStatusType `json:"status" binding:"required"`
+}
+
+// UpdateOrderStatusHandler moves an order to the status in the request body,
+// e.g. to mark it shipped or delivered
+func (ctrl *OrderController) UpdateOrderStatusHandler(c *gin.Context) {
+	var req UpdateOrderStatusRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctrl.updateOrderStatus(c, req.Status)
+}
+
+func (ctrl *OrderController) updateOrderStatus(c *gin.Context, status model.OrderStatusType) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
 		return
 	}
 
-	userIDUint, err := strconv.ParseUint(userID.(string), 10, 32)
+	// Extract orderID from the URL
+	orderIDStr := c.Param("orderID")
+	orderID, err := strconv.ParseUint(orderIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		_ = c.Error(utils.NewBadRequestError("Invalid order ID"))
 		return
 	}
 
-	// Extract role from the context
-	// role, exists := c.Get("role")
-	// if !exists {
-	// 	c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
-	// 	return
-	// }
+	order, err := ctrl.OrderService.UpdateOrderStatus(c.Request.Context(), uint(orderID), status, principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 
-	// roleStr, ok := role.(string)
-	// if !ok {
-	// 	c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
-	// 	return
-	// }
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
 
-	// // Check if the user has the required role to create a product
-	// if roleStr != "user" && roleStr != "editor" {
-	// 	c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to cancel an order"})
-	// 	return
-	// }
+// OrderHistoryHandler returns the status history of an order. Customers can
+// only see their own orders; admins can see any order.
+func (ctrl *OrderController) OrderHistoryHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
 
 	// Extract orderID from the URL
 	orderIDStr := c.Param("orderID")
 	orderID, err := strconv.ParseUint(orderIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		_ = c.Error(utils.NewBadRequestError("Invalid order ID"))
 		return
 	}
 
-	// Call the service to cancel the order
-	err = ctrl.OrderService.CancelOrder(c, uint(orderID), uint(userIDUint))
+	history, err := ctrl.OrderService.GetOrderHistory(c.Request.Context(), uint(orderID), principal.UserID, principal.Can(middleware.PermOrderReadAll))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order canceled successfully"})
+	c.JSON(http.StatusOK, gin.H{"history": history})
 }
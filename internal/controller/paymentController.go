@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/service"
+	"instashop/internal/utils"
+)
+
+type PaymentController struct {
+	PaymentService *service.PaymentService
+}
+
+func NewPaymentController(paymentService *service.PaymentService) *PaymentController {
+	return &PaymentController{PaymentService: paymentService}
+}
+
+// InitializePaymentHandler starts a payment for one of the user's orders and
+// returns the URL where the customer completes it
+func (ctrl *PaymentController) InitializePaymentHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	// Extract orderID from the URL
+	orderID, err := strconv.ParseUint(c.Param("orderID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid order ID"))
+		return
+	}
+
+	payment, err := ctrl.PaymentService.InitializePayment(c.Request.Context(), uint(orderID), principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"payment": payment})
+}
+
+// VerifyPaymentHandler checks the state of a payment with the provider
+func (ctrl *PaymentController) VerifyPaymentHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	payment, err := ctrl.PaymentService.VerifyPayment(c.Request.Context(), c.Param("reference"), principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment})
+}
+
+// WebhookHandler receives signed payment events from the provider
+func (ctrl *PaymentController) WebhookHandler(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid request body"))
+		return
+	}
+
+	if err := ctrl.PaymentService.HandleWebhook(c.Request.Context(), payload, c.Request.Header); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed"})
+}
+
+// RefundPaymentHandler refunds a payment and cancels its order
+func (ctrl *PaymentController) RefundPaymentHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	payment, err := ctrl.PaymentService.RefundPayment(c.Request.Context(), c.Param("reference"), principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment})
+}
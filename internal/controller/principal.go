@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/middleware"
+	"instashop/internal/utils"
+)
+
+// currentPrincipal returns the authenticated caller, writing a 401 response
+// when the route is not behind middleware.VerifyToken.
+func currentPrincipal(c *gin.Context) (*middleware.Principal, bool) {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		_ = c.Error(utils.NewUnauthorizedError("Unauthorized"))
+		return nil, false
+	}
+	return principal, true
+}
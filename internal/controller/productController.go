@@ -1,7 +1,7 @@
 package controller
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strconv"
 
@@ -9,54 +9,59 @@ import (
 
 	"instashop/internal/model"
 	"instashop/internal/service"
+	"instashop/internal/utils"
 )
 
+// ProductService is what ProductController needs from
+// service.ProductService, so the controller can be tested without a database.
+type ProductService interface {
+	CreateProduct(ctx context.Context, userID uint, storeID uint, name, description string, price float64, stock int, taxonomy service.ProductTaxonomy) (*model.Product, error)
+	GetProduct(ctx context.Context, productID uint, userID uint) (*model.Product, error)
+	GetAllProductsByUserID(ctx context.Context, userID uint) ([]model.Product, error)
+	UpdateProduct(ctx context.Context, productID uint, userID uint, updatedProduct model.Product, taxonomy service.ProductTaxonomy) (*model.Product, error)
+	UpdateStock(ctx context.Context, productID uint, userID uint, stock int) (*model.Product, error)
+	DeletePendingProduct(ctx context.Context, productID uint, userID uint) error
+	ListPendingProducts(ctx context.Context) ([]model.Product, error)
+	ReviewProduct(ctx context.Context, productID uint, reviewerID uint, status model.StatusType, reason string) (*model.Product, error)
+}
+
 type ProductController struct {
-	ProductService *service.ProductService
+	ProductService ProductService
 }
 
-func NewProductController(productService *service.ProductService) *ProductController {
+func NewProductController(productService ProductService) *ProductController {
 	return &ProductController{ProductService: productService}
 }
 
-func (ctrl *ProductController) CreateProduct(c *gin.Context) {
-	// Extract userID from the context
-	userID, exists := c.Get("user_id")
-	fmt.Println("Extracted user_id C: ", userID)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
+// CreateProductRequest is a new product. It goes to the seller's first store
+// unless store_id names another store they work at.
+type CreateProductRequest struct {
+	Name        string   `json:"name" binding:"required,max=255"`
+	Description string   `json:"description" binding:"required,max=5000"`
+	Price       float64  `json:"price" binding:"required,price"`
+	Stock       int      `json:"stock" binding:"min=0"`
+	StoreID     uint     `json:"store_id"`
+	CategoryID  *uint    `json:"category_id"`
+	Tags        []string `json:"tags" binding:"max=20,dive,required,max=50"`
+}
 
-	userIDStr, ok := userID.(string)
+func (ctrl *ProductController) CreateProduct(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
-	// Convert userIDStr to uint
-	userIDUint, err := strconv.ParseUint(userIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
 		return
 	}
 
 	// Bind the request body
-	var input struct {
-		Name        string  `json:"name" binding:"required"`
-		Description string  `json:"description" binding:"required"`
-		Price       float64 `json:"price" binding:"required"`
-	}
-
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+	var input CreateProductRequest
+	if !bindJSON(c, &input) {
 		return
 	}
 
 	// Create the product using the ProductService
-	product, err := ctrl.ProductService.CreateProduct(c.Request.Context(), uint(userIDUint), input.Name, input.Description, input.Price)
+	product, err := ctrl.ProductService.CreateProduct(c.Request.Context(), principal.UserID, input.StoreID, input.Name, input.Description, input.Price, input.Stock,
+		service.ProductTaxonomy{CategoryID: input.CategoryID, Tags: input.Tags})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -124,42 +129,8 @@ func (ctrl *ProductController) CreateProduct(c *gin.Context) {
 // }
 
 func (ctrl *ProductController) GetProduct(c *gin.Context) {
-	// Extract userID from the context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	userIDStr, ok := userID.(string)
+	principal, ok := currentPrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
-	// Convert userIDStr to uint
-	userIDUint, err := strconv.ParseUint(userIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
-	// Extract role from the context
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
-		return
-	}
-
-	roleStr, ok := role.(string)
-	if !ok {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
-		return
-	}
-
-	// Check if the user has the required role to create a product
-	if roleStr != "admin" && roleStr != "editor" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to this product"})
 		return
 	}
 
@@ -167,14 +138,14 @@ func (ctrl *ProductController) GetProduct(c *gin.Context) {
 	productIDStr := c.Param("productID")
 	productID, err := strconv.ParseUint(productIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		_ = c.Error(utils.NewBadRequestError("Invalid product ID format"))
 		return
 	}
 
 	// Fetch the product using the ProductService
-	product, err := ctrl.ProductService.GetProduct(c.Request.Context(), uint(productID), uint(userIDUint))
+	product, err := ctrl.ProductService.GetProduct(c.Request.Context(), uint(productID), principal.UserID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -183,91 +154,70 @@ func (ctrl *ProductController) GetProduct(c *gin.Context) {
 
 // GetAllProductsByUserID retrieves all products for a specific user
 func (ctrl *ProductController) GetAllProductsByUserID(c *gin.Context) {
-	// Extract userID from the context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	userIDStr, ok := userID.(string)
+	principal, ok := currentPrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
-	userIDUint, err := strconv.ParseUint(userIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
-	// Extract role from the context
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
-		return
-	}
-
-	roleStr, ok := role.(string)
-	if !ok {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
-		return
-	}
-
-	// Check if the user has the required role to create a product
-	if roleStr != "admin" && roleStr != "editor" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to this product"})
 		return
 	}
 
 	// Fetch products using the ProductService
-	products, err := ctrl.ProductService.GetAllProductsByUserID(c.Request.Context(), uint(userIDUint))
+	products, err := ctrl.ProductService.GetAllProductsByUserID(c.Request.Context(), principal.UserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"products": products})
 }
 
+type UpdateProductRequest struct {
+	Name       string   `json:"name" binding:"required,max=255"`
+	Price      float64  `json:"price" binding:"required,price"`
+	CategoryID *uint    `json:"category_id"`
+	Tags       []string `json:"tags" binding:"omitnil,max=20,dive,required,max=50"`
+}
+
 // UpdateProduct updates an existing product
 func (ctrl *ProductController) UpdateProduct(c *gin.Context) {
-	// Extract userID from the context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	userIDStr, ok := userID.(string)
+	principal, ok := currentPrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	_, err := strconv.ParseUint(userIDStr, 10, 32)
+	// Extract productID from URL parameters
+	productIDStr := c.Param("productID")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
+		_ = c.Error(utils.NewBadRequestError("Invalid product ID format"))
 		return
 	}
 
-	// Extract role from the context
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
+	// Bind the request body. category_id 0 removes the category and an empty
+	// tags list removes the tags; leaving them out keeps them unchanged.
+	var req UpdateProductRequest
+	if !bindJSON(c, &req) {
 		return
 	}
+	updatedProduct := model.Product{Name: req.Name, Price: req.Price}
 
-	roleStr, ok := role.(string)
-	if !ok {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
+	// Update the product using the ProductService
+	product, err := ctrl.ProductService.UpdateProduct(c.Request.Context(), uint(productID), principal.UserID, updatedProduct,
+		service.ProductTaxonomy{CategoryID: req.CategoryID, Tags: req.Tags})
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	// Check if the user has the required role to create a product
-	if roleStr != "admin" && roleStr != "editor" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to this product"})
+	c.JSON(http.StatusOK, gin.H{"product": product})
+}
+
+type UpdateStockRequest struct {
+	Stock *int `json:"stock" binding:"required,min=0"`
+}
+
+// UpdateStock sets the available stock of a product
+func (ctrl *ProductController) UpdateStock(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
 		return
 	}
 
@@ -275,21 +225,18 @@ func (ctrl *ProductController) UpdateProduct(c *gin.Context) {
 	productIDStr := c.Param("productID")
 	productID, err := strconv.ParseUint(productIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		_ = c.Error(utils.NewBadRequestError("Invalid product ID format"))
 		return
 	}
 
-	// Bind the request body to the updated product struct
-	var updatedProduct model.Product
-	if err := c.ShouldBindJSON(&updatedProduct); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+	var req UpdateStockRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	// Update the product using the ProductService
-	product, err := ctrl.ProductService.UpdateProduct(c.Request.Context(), uint(productID), updatedProduct)
+	product, err := ctrl.ProductService.UpdateStock(c.Request.Context(), uint(productID), principal.UserID, *req.Stock)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -298,41 +245,56 @@ func (ctrl *ProductController) UpdateProduct(c *gin.Context) {
 
 // DeletePendingProduct deletes a pending product
 func (ctrl *ProductController) DeletePendingProduct(c *gin.Context) {
-	// Extract userID from the context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	userIDStr, ok := userID.(string)
+	principal, ok := currentPrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	userIDUint, err := strconv.ParseUint(userIDStr, 10, 32)
+	// Extract productID from URL parameters
+	productIDStr := c.Param("productID")
+	productID, err := strconv.ParseUint(productIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
+		_ = c.Error(utils.NewBadRequestError("Invalid product ID format"))
 		return
 	}
 
-	// Extract role from the context
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
+	// Delete the pending product using the ProductService
+	if err := ctrl.ProductService.DeletePendingProduct(c.Request.Context(), uint(productID), principal.UserID); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	roleStr, ok := role.(string)
-	if !ok {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
+	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+}
+
+// ListPendingProducts returns the products waiting for moderation
+func (ctrl *ProductController) ListPendingProducts(c *gin.Context) {
+	products, err := ctrl.ProductService.ListPendingProducts(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	// Check if the user has the required role to create a product
-	if roleStr != "admin" && roleStr != "editor" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to this product"})
+	c.JSON(http.StatusOK, gin.H{"products": products})
+}
+
+type ReviewProductRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// ApproveProduct approves a pending product
+func (ctrl *ProductController) ApproveProduct(c *gin.Context) {
+	ctrl.reviewProduct(c, model.StatusApproved)
+}
+
+// DeclineProduct declines a pending product with a reason
+func (ctrl *ProductController) DeclineProduct(c *gin.Context) {
+	ctrl.reviewProduct(c, model.StatusDeclined)
+}
+
+func (ctrl *ProductController) reviewProduct(c *gin.Context, status model.StatusType) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
 		return
 	}
 
@@ -340,15 +302,23 @@ func (ctrl *ProductController) DeletePendingProduct(c *gin.Context) {
 	productIDStr := c.Param("productID")
 	productID, err := strconv.ParseUint(productIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		_ = c.Error(utils.NewBadRequestError("Invalid product ID format"))
 		return
 	}
 
-	// Delete the pending product using the ProductService
-	if err := ctrl.ProductService.DeletePendingProduct(c.Request.Context(), uint(productID), uint(userIDUint)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// The body is optional when approving
+	var req ReviewProductRequest
+	if c.Request.ContentLength > 0 {
+		if !bindJSON(c, &req) {
+			return
+		}
+	}
+
+	product, err := ctrl.ProductService.ReviewProduct(c.Request.Context(), uint(productID), principal.UserID, status, req.Reason)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"product": product})
 }
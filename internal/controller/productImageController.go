@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/service"
+	"instashop/internal/utils"
+)
+
+// maxImageRequestSize caps the body of an image upload request: a full set of
+// images plus room for the multipart framing.
+const maxImageRequestSize = service.MaxProductImages*utils.MaxImageSize + 1<<20
+
+type ProductImageController struct {
+	ProductImageService *service.ProductImageService
+}
+
+func NewProductImageController(productImageService *service.ProductImageService) *ProductImageController {
+	return &ProductImageController{ProductImageService: productImageService}
+}
+
+// AddImages uploads images for a product. Takes a multipart form with one or
+// more files in the "images" field.
+func (ctrl *ProductImageController) AddImages(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageRequestSize)
+	form, err := c.MultipartForm()
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid or oversized image upload"))
+		return
+	}
+
+	images, err := ctrl.ProductImageService.AddImages(c.Request.Context(), productID, principal.UserID, form.File["images"])
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"images": images})
+}
+
+// ListImages returns a product's images in display order
+func (ctrl *ProductImageController) ListImages(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	images, err := ctrl.ProductImageService.ListImages(c.Request.Context(), productID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"images": images})
+}
+
+type ReorderImagesRequest struct {
+	ImageIDs []uint `json:"image_ids" binding:"required,min=1,max=10"`
+}
+
+// ReorderImages sets the display order of a product's images
+func (ctrl *ProductImageController) ReorderImages(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ReorderImagesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	images, err := ctrl.ProductImageService.ReorderImages(c.Request.Context(), productID, principal.UserID, req.ImageIDs)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"images": images})
+}
+
+// SetPrimaryImage makes an image the product's primary image
+func (ctrl *ProductImageController) SetPrimaryImage(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	imageID, ok := imageIDParam(c)
+	if !ok {
+		return
+	}
+
+	image, err := ctrl.ProductImageService.SetPrimaryImage(c.Request.Context(), productID, imageID, principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"image": image})
+}
+
+// DeleteImage removes an image from a product
+func (ctrl *ProductImageController) DeleteImage(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	imageID, ok := imageIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.ProductImageService.DeleteImage(c.Request.Context(), productID, imageID, principal.UserID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted"})
+}
+
+// productIDParam parses the productID path parameter, writing a 400 response
+// when it is malformed.
+func productIDParam(c *gin.Context) (uint, bool) {
+	productID, err := strconv.ParseUint(c.Param("productID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid product ID format"))
+		return 0, false
+	}
+	return uint(productID), true
+}
+
+// imageIDParam parses the imageID path parameter, writing a 400 response when
+// it is malformed.
+func imageIDParam(c *gin.Context) (uint, bool) {
+	imageID, err := strconv.ParseUint(c.Param("imageID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid image ID format"))
+		return 0, false
+	}
+	return uint(imageID), true
+}
@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/service"
+	"instashop/internal/utils"
+)
+
+type ProductVariantController struct {
+	ProductVariantService *service.ProductVariantService
+}
+
+func NewProductVariantController(productVariantService *service.ProductVariantService) *ProductVariantController {
+	return &ProductVariantController{ProductVariantService: productVariantService}
+}
+
+type AddOptionValueRequest struct {
+	Value string `json:"value" binding:"required,max=50"`
+}
+
+// AddOption adds an option type, such as size, and its values to a product
+func (ctrl *ProductVariantController) AddOption(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.OptionInput
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	option, err := ctrl.ProductVariantService.AddOption(c.Request.Context(), productID, principal.UserID, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"option": option})
+}
+
+// AddOptionValue adds a value to one of a product's options
+func (ctrl *ProductVariantController) AddOptionValue(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	optionID, ok := optionIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req AddOptionValueRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	value, err := ctrl.ProductVariantService.AddOptionValue(c.Request.Context(), productID, optionID, principal.UserID, req.Value)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"value": value})
+}
+
+// DeleteOption removes an option from a product without variants
+func (ctrl *ProductVariantController) DeleteOption(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	optionID, ok := optionIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.ProductVariantService.DeleteOption(c.Request.Context(), productID, optionID, principal.UserID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Option deleted"})
+}
+
+// CreateVariant adds a variant with its own SKU, stock and optional price
+func (ctrl *ProductVariantController) CreateVariant(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.VariantInput
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	variant, err := ctrl.ProductVariantService.CreateVariant(c.Request.Context(), productID, principal.UserID, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"variant": variant})
+}
+
+// UpdateVariant changes the SKU, price or stock of a variant
+func (ctrl *ProductVariantController) UpdateVariant(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	variantID, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.VariantUpdate
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	variant, err := ctrl.ProductVariantService.UpdateVariant(c.Request.Context(), productID, variantID, principal.UserID, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"variant": variant})
+}
+
+// DeleteVariant removes a variant that has never been ordered
+func (ctrl *ProductVariantController) DeleteVariant(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	variantID, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.ProductVariantService.DeleteVariant(c.Request.Context(), productID, variantID, principal.UserID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Variant deleted"})
+}
+
+// optionIDParam parses the optionID path parameter, writing a 400 response
+// when it is malformed.
+func optionIDParam(c *gin.Context) (uint, bool) {
+	optionID, err := strconv.ParseUint(c.Param("optionID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid option ID format"))
+		return 0, false
+	}
+	return uint(optionID), true
+}
+
+// variantIDParam parses the variantID path parameter, writing a 400 response
+// when it is malformed.
+func variantIDParam(c *gin.Context) (uint, bool) {
+	variantID, err := strconv.ParseUint(c.Param("variantID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid variant ID format"))
+		return 0, false
+	}
+	return uint(variantID), true
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/model"
+	"instashop/internal/service"
+	"instashop/internal/utils"
+	"instashop/internal/validation"
+)
+
+type StoreController struct {
+	StoreService   *service.StoreService
+	CatalogService *service.CatalogService
+}
+
+func NewStoreController(storeService *service.StoreService, catalogService *service.CatalogService) *StoreController {
+	return &StoreController{StoreService: storeService, CatalogService: catalogService}
+}
+
+// GetStorePage is the public page of a store: its details and a page of its
+// approved products. Accepts the same query parameters as the catalog.
+func (ctrl *StoreController) GetStorePage(c *gin.Context) {
+	store, err := ctrl.StoreService.GetStoreBySlug(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	query, ok := parseCatalogQuery(c)
+	if !ok {
+		return
+	}
+	query.StoreID = store.ID
+
+	page, err := ctrl.CatalogService.ListProducts(c.Request.Context(), query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"store":       store,
+		"products":    page.Products,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// CreateStore opens a store for the current user. Takes a multipart form
+// with name, slug, description and an optional logo file.
+func (ctrl *StoreController) CreateStore(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	input, logo, ok := storeForm(c)
+	if !ok {
+		return
+	}
+
+	store, err := ctrl.StoreService.CreateStore(c.Request.Context(), principal.UserID, input, logo)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"store": store})
+}
+
+// ListMyStores returns the stores the current user owns or works at
+func (ctrl *StoreController) ListMyStores(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	stores, err := ctrl.StoreService.ListStoresForUser(c.Request.Context(), principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stores": stores})
+}
+
+// GetStore returns a store and its staff to the owner and staff
+func (ctrl *StoreController) GetStore(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	storeID, ok := storeIDParam(c)
+	if !ok {
+		return
+	}
+
+	store, err := ctrl.StoreService.GetManagedStore(c.Request.Context(), storeID, principal.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"store": store})
+}
+
+// UpdateStore changes the details of a store. Takes the same form as
+// CreateStore; empty fields are left unchanged.
+func (ctrl *StoreController) UpdateStore(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	storeID, ok := storeIDParam(c)
+	if !ok {
+		return
+	}
+
+	input, logo, ok := storeForm(c)
+	if !ok {
+		return
+	}
+
+	store, err := ctrl.StoreService.UpdateStore(c.Request.Context(), storeID, principal.UserID, input, logo)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"store": store})
+}
+
+type AddStaffRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// AddStaff adds a user to the store's staff by email
+func (ctrl *StoreController) AddStaff(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	storeID, ok := storeIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req AddStaffRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	staff, err := ctrl.StoreService.AddStaff(c.Request.Context(), storeID, principal.UserID, req.Email)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"staff": staff})
+}
+
+// RemoveStaff removes a user from the store's staff
+func (ctrl *StoreController) RemoveStaff(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	storeID, ok := storeIDParam(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid user ID format"))
+		return
+	}
+
+	if err := ctrl.StoreService.RemoveStaff(c.Request.Context(), storeID, principal.UserID, uint(userID)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Staff member removed"})
+}
+
+// ListStoreOrders returns the orders for the store's products. Supports a
+// status query filter.
+func (ctrl *StoreController) ListStoreOrders(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	storeID, ok := storeIDParam(c)
+	if !ok {
+		return
+	}
+
+	orders, err := ctrl.StoreService.ListStoreOrders(c.Request.Context(), storeID, principal.UserID, model.OrderStatusType(c.Query("status")))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+// UpdateStoreOrderStatus lets store staff approve, decline, ship or deliver
+// one of the store's orders
+func (ctrl *StoreController) UpdateStoreOrderStatus(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	storeID, ok := storeIDParam(c)
+	if !ok {
+		return
+	}
+
+	orderID, err := strconv.ParseUint(c.Param("orderID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid order ID"))
+		return
+	}
+
+	var req UpdateOrderStatusRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	order, err := ctrl.StoreService.UpdateStoreOrderStatus(c.Request.Context(), storeID, uint(orderID), principal.UserID, req.Status)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+// storeIDParam parses the storeID path parameter, writing a 400 response when
+// it is malformed.
+func storeIDParam(c *gin.Context) (uint, bool) {
+	storeID, err := strconv.ParseUint(c.Param("storeID"), 10, 32)
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid store ID format"))
+		return 0, false
+	}
+	return uint(storeID), true
+}
+
+// storeForm reads the store fields and optional logo from a form, pushing a
+// 422 when a field is invalid.
+func storeForm(c *gin.Context) (service.StoreInput, *multipart.FileHeader, bool) {
+	input := service.StoreInput{
+		Name:        c.PostForm("name"),
+		Slug:        c.PostForm("slug"),
+		Description: c.PostForm("description"),
+	}
+	if failures := validation.Struct(input); failures != nil {
+		_ = c.Error(invalidPayload(failures...))
+		return input, nil, false
+	}
+
+	logo, err := c.FormFile("logo")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return input, nil, true
+	}
+	if err != nil {
+		_ = c.Error(utils.NewBadRequestError("Invalid logo upload"))
+		return input, nil, false
+	}
+	return input, logo, true
+}
@@ -1,48 +1,84 @@
 package controller
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
 	"instashop/internal/model"
 	"instashop/internal/service"
+	"instashop/internal/utils"
 )
 
+// UserService is what UserController needs from service.UserService, so the
+// controller can be tested without a database.
+type UserService interface {
+	CreateUser(ctx context.Context, user *model.User) error
+	CreateAdmin(ctx context.Context, createdBy uint, user *model.User) error
+	VerifyEmail(email string, otpToken string) error
+	SendMail(email string) (string, error)
+	Login(email, password string) (*service.TokenPair, error)
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, email, code, newPassword string) error
+}
+
+// TokenService is what UserController needs from service.TokenService.
+type TokenService interface {
+	Refresh(ctx context.Context, refreshToken string) (*service.TokenPair, error)
+	Logout(ctx context.Context, claims *utils.Claims, refreshToken string) error
+}
+
 type UserController struct {
-	UserService *service.UserService
+	UserService  UserService
+	TokenService TokenService
 }
 
-func NewUserController(userService *service.UserService) *UserController {
-	return &UserController{UserService: userService}
+func NewUserController(userService UserService, tokenService TokenService) *UserController {
+	return &UserController{UserService: userService, TokenService: tokenService}
+}
+
+// SignupRequest is the account a user signs up for, or an admin creates.
+// Password strength is checked by the service.
+type SignupRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (r SignupRequest) user() *model.User {
+	return &model.User{Username: r.Username, Email: r.Email, Password: r.Password}
 }
 
 // CreateUser handles the creation of a new user.
 func (ctrl *UserController) CreateUser(c *gin.Context) {
-	var user model.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+	var req SignupRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	if err := ctrl.UserService.CreateUser(c.Request.Context(), &user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := ctrl.UserService.CreateUser(c.Request.Context(), req.user()); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
 }
 
-// CreateAdminUser handles the creation of a new user.
+// CreateAdmin lets an existing admin create another admin account.
 func (ctrl *UserController) CreateAdmin(c *gin.Context) {
-	var user model.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req SignupRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	if err := ctrl.UserService.CreateAdmin(c.Request.Context(), &user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := ctrl.UserService.CreateAdmin(c.Request.Context(), principal.UserID, req.user()); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
@@ -50,57 +86,148 @@ func (ctrl *UserController) CreateAdmin(c *gin.Context) {
 }
 
 type VerifyEmailRequest struct {
-	Email    string `json:"email" binding:"required"`
-	OtpToken string `json:"otpToken" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	OtpToken string `json:"otpToken" binding:"required,max=20"`
 }
 
 // VerifyEmailHandler handles the verification of a user's email
 func (ctrl *UserController) VerifyEmailHandler(c *gin.Context) {
 	var req VerifyEmailRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email and OTP token are required"})
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := ctrl.UserService.VerifyEmail(req.Email, req.OtpToken); err != nil {
+		_ = c.Error(err)
 		return
 	}
-	ctrl.UserService.VerifyEmail(req.Email, req.OtpToken)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
 }
 
 type SendEmailRequest struct {
-	Email string `json:"email" binding:"required"`
+	Email string `json:"email" binding:"required,email"`
 }
 
 func (ctrl *UserController) SendEmailHandler(c *gin.Context) {
 	var req SendEmailRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is required"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	ctrl.UserService.SendMail(req.Email)
+	if _, err := ctrl.UserService.SendMail(req.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Email sent successfully"})
 
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
 }
 
 func (ctrl *UserController) LoginHandler(c *gin.Context) {
 	var req LoginRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	token, err := ctrl.UserService.Login(req.Email, req.Password)
+	tokens, err := ctrl.UserService.Login(req.Email, req.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()}) 
+		_ = c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
+
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshHandler exchanges a refresh token for a new access and refresh token
+func (ctrl *UserController) RefreshHandler(c *gin.Context) {
+	var req RefreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := ctrl.TokenService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutHandler revokes the current access token and, if given, the refresh
+// token issued with it
+func (ctrl *UserController) LogoutHandler(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	// The refresh token is optional
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if !bindJSON(c, &req) {
+			return
+		}
+	}
+
+	if err := ctrl.TokenService.Logout(c.Request.Context(), principal.Claims, req.RefreshToken); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPasswordHandler emails a password reset code
+func (ctrl *UserController) ForgotPasswordHandler(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := ctrl.UserService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a reset code has been sent"})
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	Code        string `json:"code" binding:"required,max=20"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResetPasswordHandler sets a new password using a reset code
+func (ctrl *UserController) ResetPasswordHandler(c *gin.Context) {
+	var req ResetPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := ctrl.UserService.ResetPassword(c.Request.Context(), req.Email, req.Code, req.NewPassword); err != nil {
+		_ = c.Error(err)
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
 }
@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 	"strconv"
 	"time"
 
@@ -13,9 +12,12 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"instashop/internal/config"
 )
 
 type Service interface {
+	Ping(ctx context.Context) error
 	Health() map[string]string
 	Close() error
 	GetGORM() *gorm.DB
@@ -24,34 +26,25 @@ type Service interface {
 type service struct {
 	db     *sql.DB
 	gormDB *gorm.DB
+	name   string
 }
 
-var (
-	dbname     = os.Getenv("DB_DATABASE")
-	password   = os.Getenv("DB_PASSWORD")
-	username   = os.Getenv("DB_USERNAME")
-	port       = os.Getenv("DB_PORT")
-	host       = os.Getenv("DB_HOST")
-	dbInstance *service
-)
+var dbInstance *service
 
-// New initializes the database service.
-func New() Service {
+// New initializes the database service from cfg.
+func New(cfg config.Database) Service {
 	if dbInstance != nil {
 		return dbInstance
 	}
 
-	// Correct DSN for PostgreSQL
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", username, password, host, port, dbname)
-
 	// Open a low-level database connection
-	sqlDB, err := sql.Open("postgres", dsn)
+	sqlDB, err := sql.Open("postgres", cfg.DSN())
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
-	sqlDB.SetConnMaxLifetime(30 * time.Minute) // Set a reasonable lifetime
-	sqlDB.SetMaxIdleConns(50)
-	sqlDB.SetMaxOpenConns(50)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
 
 	// Initialize GORM with the existing connection
 	gormDB, err := gorm.Open(postgres.New(postgres.Config{
@@ -66,10 +59,26 @@ func New() Service {
 	dbInstance = &service{
 		db:     sqlDB,
 		gormDB: gormDB,
+		name:   cfg.Name,
 	}
 	return dbInstance
 }
 
+// Wrap returns a Service over an open GORM connection, e.g. one to a test
+// schema. name is only used in log messages.
+func Wrap(gormDB *gorm.DB, name string) (Service, error) {
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the connection pool: %w", err)
+	}
+	return &service{db: sqlDB, gormDB: gormDB, name: name}, nil
+}
+
+// Ping checks the database is reachable.
+func (s *service) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 // Health checks the health of the database connection.
 func (s *service) Health() map[string]string {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -104,7 +113,7 @@ func (s *service) Health() map[string]string {
 
 // Close closes the database connection.
 func (s *service) Close() error {
-	log.Printf("Disconnected from database: %s", dbname)
+	log.Printf("Disconnected from database: %s", s.name)
 	return s.db.Close()
 }
 
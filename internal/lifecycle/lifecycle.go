@@ -0,0 +1,59 @@
+// Package lifecycle stops the parts of the application in order on shutdown.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Manager runs the registered shutdown hooks in the reverse order of
+// registration, so a component is stopped before the ones it depends on.
+type Manager struct {
+	mu    sync.Mutex
+	hooks []hook
+	done  bool
+}
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func New() *Manager {
+	return &Manager{}
+}
+
+// OnShutdown registers stop to be called on shutdown. Register a component
+// after the components it uses.
+func (m *Manager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Shutdown runs every hook, newest first, and returns their errors joined.
+// A failing or timed out hook does not stop the later ones from running.
+// Only the first call does anything.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.done {
+		m.mu.Unlock()
+		return nil
+	}
+	m.done = true
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		log.Printf("Stopping %s", h.name)
+		if err := h.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", h.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
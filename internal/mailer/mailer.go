@@ -0,0 +1,159 @@
+// Package mailer sends transactional email. Emails are rendered from the
+// templates in templates/, queued in the outbox_emails table and delivered by
+// a pool of workers that retry failures with backoff.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Names of the email templates. Each has a .txt file, which also defines the
+// subject, and a .html file rendered inside layout.html.
+const (
+	TemplateVerifyEmail     = "verify_email"
+	TemplatePasswordReset   = "password_reset"
+	TemplatePasswordChanged = "password_changed"
+	TemplateAdminInvite     = "admin_invite"
+	TemplateProductReviewed = "product_reviewed"
+)
+
+// VerifyEmailData fills TemplateVerifyEmail.
+type VerifyEmailData struct {
+	Code      string
+	ExpiresIn string
+}
+
+// PasswordResetData fills TemplatePasswordReset.
+type PasswordResetData struct {
+	Code      string
+	ExpiresIn string
+}
+
+// AdminInviteData fills TemplateAdminInvite.
+type AdminInviteData struct {
+	Link      string
+	ExpiresAt time.Time
+}
+
+// ProductReviewedData fills TemplateProductReviewed.
+type ProductReviewedData struct {
+	ProductName string
+	Approved    bool
+	Reason      string
+}
+
+// Email is a templated email to one recipient.
+type Email struct {
+	To       string
+	Template string
+	Data     interface{}
+}
+
+// Message is a rendered email, ready for a Transport.
+type Message struct {
+	To       string
+	Template string
+	Subject  string
+	Text     string
+	HTML     string
+}
+
+// Mailer queues transactional email.
+type Mailer interface {
+	// Send renders email and queues it for delivery.
+	Send(ctx context.Context, email Email) error
+	// WithTx returns a Mailer that queues inside tx, so the email is only
+	// sent if tx commits.
+	WithTx(tx *gorm.DB) Mailer
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = mustParseTemplates(
+	TemplateVerifyEmail,
+	TemplatePasswordReset,
+	TemplatePasswordChanged,
+	TemplateAdminInvite,
+	TemplateProductReviewed,
+)
+
+func mustParseTemplates(names ...string) map[string]emailTemplate {
+	parsed := make(map[string]emailTemplate, len(names))
+	for _, name := range names {
+		text := texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/"+name+".txt"))
+		if text.Lookup("subject") == nil {
+			panic(fmt.Sprintf("mailer: template %s.txt does not define a subject", name))
+		}
+		html := htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html"))
+		parsed[name] = emailTemplate{text: text, html: html}
+	}
+	return parsed
+}
+
+// Render fills the template of email with its data.
+func Render(email Email) (*Message, error) {
+	if strings.TrimSpace(email.To) == "" {
+		return nil, fmt.Errorf("email %s has no recipient", email.Template)
+	}
+	tmpl, ok := templates[email.Template]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", email.Template)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", email.Data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", email.Template, err)
+	}
+	if err := tmpl.text.Execute(&text, email.Data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", email.Template, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", email.Data); err != nil {
+		return nil, fmt.Errorf("failed to render %s html: %w", email.Template, err)
+	}
+
+	return &Message{
+		To:       email.To,
+		Template: email.Template,
+		Subject:  strings.TrimSpace(subject.String()),
+		Text:     strings.TrimSpace(text.String()) + "\n",
+		HTML:     html.String(),
+	}, nil
+}
+
+// Direct renders and sends email straight away, without the outbox. Tests use
+// it with a CaptureTransport.
+type Direct struct {
+	Transport Transport
+}
+
+func NewDirect(transport Transport) *Direct {
+	return &Direct{Transport: transport}
+}
+
+func (d *Direct) Send(ctx context.Context, email Email) error {
+	msg, err := Render(email)
+	if err != nil {
+		return err
+	}
+	return d.Transport.Send(ctx, *msg)
+}
+
+// WithTx returns d; a Direct mailer sends whether or not tx commits.
+func (d *Direct) WithTx(*gorm.DB) Mailer {
+	return d
+}
@@ -0,0 +1,218 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/model"
+)
+
+// sendLease is how long a claimed email is hidden from other workers. An
+// email whose worker died mid-send is retried once the lease runs out.
+const sendLease = 5 * time.Minute
+
+// Outbox queues emails in the outbox_emails table.
+type Outbox struct {
+	DB *gorm.DB
+}
+
+func NewOutbox(db *gorm.DB) *Outbox {
+	return &Outbox{DB: db}
+}
+
+func (o *Outbox) Send(ctx context.Context, email Email) error {
+	msg, err := Render(email)
+	if err != nil {
+		return err
+	}
+
+	record := &model.OutboxEmail{
+		Recipient:     msg.To,
+		Template:      msg.Template,
+		Subject:       msg.Subject,
+		TextBody:      msg.Text,
+		HTMLBody:      msg.HTML,
+		Status:        model.EmailPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := o.DB.WithContext(ctx).Create(record).Error; err != nil {
+		return fmt.Errorf("failed to queue email: %w", err)
+	}
+	return nil
+}
+
+func (o *Outbox) WithTx(tx *gorm.DB) Mailer {
+	return &Outbox{DB: tx}
+}
+
+// WorkerConfig tunes the outbox workers.
+type WorkerConfig struct {
+	// Workers is the number of emails sent concurrently
+	Workers int
+	// BatchSize is how many due emails a worker claims at once
+	BatchSize int
+	// PollInterval is how often an idle worker looks for due emails
+	PollInterval time.Duration
+	// MaxAttempts is how many times an email is tried before it is failed
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles with every
+	// further attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Worker is a pool of goroutines delivering due outbox emails through a
+// Transport. Rows are claimed with SKIP LOCKED, so several instances of the
+// application can run workers against the same table.
+type Worker struct {
+	DB        *gorm.DB
+	Transport Transport
+	Config    WorkerConfig
+
+	stop    chan struct{}
+	stopped sync.Once
+	wg      sync.WaitGroup
+}
+
+func NewWorker(db *gorm.DB, transport Transport, cfg WorkerConfig) *Worker {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 10
+	}
+	return &Worker{DB: db, Transport: transport, Config: cfg, stop: make(chan struct{})}
+}
+
+// Start launches the worker goroutines.
+func (w *Worker) Start() {
+	for i := 0; i < w.Config.Workers; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+}
+
+// Stop stops claiming emails and waits for the ones being sent, or until ctx
+// is done. Emails still queued stay in the outbox for the next start.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.stopped.Do(func() { close(w.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("emails still sending: %w", ctx.Err())
+	}
+}
+
+func (w *Worker) run() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		// Keep going while there is a backlog; otherwise wait for the next poll
+		sent, err := w.ProcessBatch(context.Background())
+		if err != nil {
+			log.Printf("Mail worker: %v", err)
+		}
+		if sent > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(w.Config.PollInterval):
+		}
+	}
+}
+
+// ProcessBatch claims up to BatchSize due emails and tries to send each one.
+// It returns how many it claimed.
+func (w *Worker) ProcessBatch(ctx context.Context) (int, error) {
+	var emails []model.OutboxEmail
+	if err := w.DB.WithContext(ctx).Raw(`UPDATE outbox_emails
+		SET attempts = attempts + 1, next_attempt_at = NOW() + (? * INTERVAL '1 second'), updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM outbox_emails
+			WHERE status = ? AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, sendLease.Seconds(), model.EmailPending, w.Config.BatchSize).Scan(&emails).Error; err != nil {
+		return 0, fmt.Errorf("failed to claim emails: %w", err)
+	}
+
+	var errs []error
+	for _, email := range emails {
+		if err := w.deliver(ctx, email); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(emails), errors.Join(errs...)
+}
+
+// deliver sends one claimed email and records the outcome. A failed send is
+// retried after a backoff until MaxAttempts is reached. Once an email is sent
+// or failed its bodies are cleared, as they hold one-time codes and tokens.
+func (w *Worker) deliver(ctx context.Context, email model.OutboxEmail) error {
+	sendErr := w.Transport.Send(ctx, Message{
+		To:       email.Recipient,
+		Template: email.Template,
+		Subject:  email.Subject,
+		Text:     email.TextBody,
+		HTML:     email.HTMLBody,
+	})
+
+	updates := map[string]interface{}{"last_error": ""}
+	switch {
+	case sendErr == nil:
+		updates["status"] = model.EmailSent
+		updates["sent_at"] = gorm.Expr("NOW()")
+		updates["text_body"], updates["html_body"] = "", ""
+	case email.Attempts >= w.Config.MaxAttempts:
+		log.Printf("Giving up on email %d to %s after %d attempts: %v", email.ID, email.Recipient, email.Attempts, sendErr)
+		updates["status"] = model.EmailFailed
+		updates["last_error"] = sendErr.Error()
+		updates["text_body"], updates["html_body"] = "", ""
+	default:
+		delay := Backoff(w.Config.Backoff, w.Config.MaxBackoff, email.Attempts)
+		updates["next_attempt_at"] = gorm.Expr("NOW() + (? * INTERVAL '1 second')", delay.Seconds())
+		updates["last_error"] = sendErr.Error()
+	}
+
+	if err := w.DB.WithContext(ctx).Model(&model.OutboxEmail{}).Where("id = ?", email.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update email %d: %w", email.ID, err)
+	}
+	return nil
+}
+
+// Backoff returns the delay before retrying after the given number of
+// attempts: base, then doubling each time, capped at max.
+func Backoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
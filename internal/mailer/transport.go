@@ -0,0 +1,172 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/gomail.v2"
+
+	"instashop/internal/config"
+)
+
+// Transport delivers rendered messages.
+type Transport interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// gomailMessage builds a multipart message with a plaintext and an HTML part.
+func gomailMessage(sender string, msg Message) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", sender)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetBody("text/plain", msg.Text)
+	m.AddAlternative("text/html", msg.HTML)
+	return m
+}
+
+// SMTPTransport sends through the configured SMTP server. Connections are
+// kept open and reused between messages, up to one per worker.
+type SMTPTransport struct {
+	cfg    config.Mail
+	dialer *gomail.Dialer
+	idle   chan gomail.SendCloser
+}
+
+func NewSMTPTransport(cfg config.Mail, maxIdle int) *SMTPTransport {
+	return &SMTPTransport{
+		cfg:    cfg,
+		dialer: gomail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password),
+		idle:   make(chan gomail.SendCloser, maxIdle),
+	}
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, msg Message) error {
+	m := gomailMessage(t.cfg.Sender, msg)
+
+	// Try an idle connection first. The server may have closed it, so a
+	// failure there is retried once on a fresh connection.
+	select {
+	case conn := <-t.idle:
+		if err := gomail.Send(conn, m); err == nil {
+			t.release(conn)
+			return nil
+		}
+		conn.Close()
+	default:
+	}
+
+	conn, err := t.dialer.Dial()
+	if err != nil {
+		return fmt.Errorf("could not connect to smtp server: %w", err)
+	}
+	if err := gomail.Send(conn, m); err != nil {
+		conn.Close()
+		return fmt.Errorf("could not send email: %w", err)
+	}
+	t.release(conn)
+	return nil
+}
+
+// release keeps conn for the next message, or closes it if enough are idle.
+func (t *SMTPTransport) release(conn gomail.SendCloser) {
+	select {
+	case t.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// Ping checks the SMTP server accepts connections.
+func (t *SMTPTransport) Ping(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.cfg.Host, strconv.Itoa(t.cfg.Port)))
+	if err != nil {
+		return fmt.Errorf("smtp unreachable: %w", err)
+	}
+	return conn.Close()
+}
+
+// Close closes the idle connections.
+func (t *SMTPTransport) Close(context.Context) error {
+	for {
+		select {
+		case conn := <-t.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// FileTransport writes each message to an .eml file in Dir instead of sending
+// it, for local development.
+type FileTransport struct {
+	Dir    string
+	Sender string
+	seq    atomic.Int64
+}
+
+func NewFileTransport(dir, sender string) *FileTransport {
+	return &FileTransport{Dir: dir, Sender: sender}
+}
+
+func (t *FileTransport) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%06d-%s.eml", time.Now().Format("20060102T150405"), t.seq.Add(1), msg.Template)
+	f, err := os.Create(filepath.Join(t.Dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := gomailMessage(t.Sender, msg).WriteTo(f); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return f.Close()
+}
+
+// CaptureTransport keeps messages in memory for tests to inspect.
+type CaptureTransport struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func NewCaptureTransport() *CaptureTransport {
+	return &CaptureTransport{}
+}
+
+func (t *CaptureTransport) Send(ctx context.Context, msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	t.messages = append(t.messages, msg)
+	return nil
+}
+
+// Messages returns the captured messages, oldest first.
+func (t *CaptureTransport) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Message(nil), t.messages...)
+}
+
+// SetErr makes later sends fail with err, or succeed again when err is nil.
+func (t *CaptureTransport) SetErr(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = err
+}
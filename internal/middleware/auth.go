@@ -1,9 +1,9 @@
 package middleware
 
 import (
-	"fmt"
-	"net/http"
-	"os"
+	"context"
+	"log"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -12,14 +12,19 @@ import (
 	"instashop/internal/utils"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
+// TokenRevocationChecker reports whether an otherwise valid access token has
+// been revoked, e.g. by logging out.
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, claims *utils.Claims) (bool, error)
+}
 
-func VerifyToken() gin.HandlerFunc {
+// VerifyToken authenticates the request with its bearer access token, signed
+// with jwtKey, and rejects tokens that have been revoked.
+func VerifyToken(jwtKey []byte, revocations TokenRevocationChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
-			c.Abort()
+			AbortWithError(c, utils.NewUnauthorizedError("Missing token"))
 			return
 		}
 
@@ -30,17 +35,30 @@ func VerifyToken() gin.HandlerFunc {
 			return jwtKey, nil
 		})
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		if err != nil || !token.Valid || claims.Id == "" {
+			AbortWithError(c, utils.NewUnauthorizedError("Invalid token"))
+			return
+		}
+
+		revoked, err := revocations.IsTokenRevoked(c.Request.Context(), claims)
+		if err != nil {
+			log.Printf("could not check token revocation: %v", err)
+			AbortWithError(c, utils.NewInternalServerError("Could not verify token"))
+			return
+		}
+		if revoked {
+			AbortWithError(c, utils.NewUnauthorizedError("Token has been revoked"))
 			return
 		}
 
-		// Set user_id and role in the context
-		c.Set("user_id", claims.UserID)
-		c.Set("role", claims.Role)
+		userID, err := strconv.ParseUint(claims.UserID, 10, 32)
+		if err != nil {
+			AbortWithError(c, utils.NewUnauthorizedError("Invalid token"))
+			return
+		}
 
-		fmt.Printf("Extracted user_id: %s, role: %s from the token\n", claims.UserID, claims.Role)
+		// Expose the authenticated caller to the permission checks and handlers
+		SetPrincipal(c, &Principal{UserID: uint(userID), Role: claims.Role, Claims: claims})
 
 		// Continue to the next handler
 		c.Next()
@@ -1,82 +1,108 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"os"
-	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
 
+	"instashop/internal/repository"
 	"instashop/internal/utils"
 )
 
+// ErrorHandlerMiddleware answers the last error a handler pushed with
+// c.Error using the error envelope:
+//
+//	{
+//	  "success": false,
+//	  "message": "Product not found",
+//	  "error": "NOT_FOUND",
+//	  "httpStatusCode": 404,
+//	  "details": ...,
+//	  "service": "instashop"
+//	}
+//
+// "error" is one of the utils.Code constants and "details" is only set for
+// some errors, such as failed validation.
 func ErrorHandlerMiddleware(c *gin.Context) {
 	c.Next() // Continue middleware pipeline and handlers
 
-	serviceName := os.Getenv("SERVICE_NAME")
-
 	// Check if there's an error to handle
 	err := c.Errors.Last()
-	if err != nil {
-		var statusCode int
-		var response gin.H
+	if err == nil || c.Writer.Written() {
+		return
+	}
+	writeError(c, err.Err)
+}
+
+// AbortWithError stops the request and answers it with err in the error
+// envelope, for middleware that may run without ErrorHandlerMiddleware.
+func AbortWithError(c *gin.Context, err error) {
+	writeError(c, err)
+	c.Abort()
+}
+
+func writeError(c *gin.Context, err error) {
+	customErr := toCustomError(err)
+	if customErr.HTTPStatusCode >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+
+	c.JSON(customErr.HTTPStatusCode, gin.H{
+		"success":        false,
+		"message":        customErr.Message,
+		"error":          customErr.Code,
+		"httpStatusCode": customErr.HTTPStatusCode,
+		"details":        customErr.Details,
+		"service":        utils.ServiceName(),
+	})
+}
 
-		switch customErr := err.Err.(type) {
-		case *utils.CustomError:
-			// Handle custom errors
-			statusCode = customErr.HTTPStatusCode
-			response = gin.H{
-				"success":        customErr.Success,
-				"message":        customErr.Message,
-				"httpStatusCode": customErr.HTTPStatusCode,
-				"error":          http.StatusText(customErr.HTTPStatusCode),
-				"service":        customErr.Service,
-			}
-		case *mysql.MySQLError:
-			// Handle MySQL-specific errors
-			switch customErr.Number {
-			case 1062:
-				statusCode = http.StatusConflict
-				response = gin.H{
-					"success":        false,
-					"message":        "Duplicate value entered",
-					"httpStatusCode": statusCode,
-					"error":          "DUPLICATE_ENTRY",
-					"service":        serviceName,
-				}
-			case 1451, 1452:
-				statusCode = http.StatusBadRequest
-				response = gin.H{
-					"success":        false,
-					"message":        "Foreign key constraint error",
-					"httpStatusCode": statusCode,
-					"error":          "FOREIGN_KEY_CONSTRAINT",
-					"service":        serviceName,
-				}
-			default:
-				statusCode = http.StatusInternalServerError
-				response = gin.H{
-					"success":        false,
-					"message":        strings.TrimSpace(customErr.Message),
-					"httpStatusCode": statusCode,
-					"error":          "DATABASE_ERROR",
-					"service":        serviceName,
-				}
-			}
-		default:
-			// Default to internal server error
-			statusCode = http.StatusInternalServerError
-			response = gin.H{
-				"success":        false,
-				"message":        "Internal Server Error",
-				"httpStatusCode": statusCode,
-				"error":          "INTERNAL_SERVER_ERROR",
-				"service":        serviceName,
-			}
-		}
+// toCustomError maps err to what the client is told. Errors services return
+// for the client are used as is; database errors are mapped by their
+// Postgres error code, whichever driver raised them; anything else is an
+// internal error whose message is not shown.
+func toCustomError(err error) *utils.CustomError {
+	var customErr *utils.CustomError
+	if errors.As(err, &customErr) {
+		return customErr
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, repository.ErrNotFound) {
+		return utils.NewNotFoundError("Record not found")
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return postgresError(string(pqErr.Code))
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return postgresError(pgErr.Code)
+	}
+
+	return utils.NewInternalServerError("Internal Server Error")
+}
 
-		// Send JSON response
-		c.JSON(statusCode, response)
+// postgresError maps a Postgres SQLSTATE to the client error, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+func postgresError(code string) *utils.CustomError {
+	switch code {
+	case "23505": // unique_violation
+		return utils.NewError(http.StatusConflict, utils.CodeDuplicateEntry, "Duplicate value entered")
+	case "23503": // foreign_key_violation
+		return utils.NewError(http.StatusBadRequest, utils.CodeForeignKey, "Foreign key constraint error")
+	case "23502", "23514": // not_null_violation, check_violation
+		return utils.NewError(http.StatusBadRequest, utils.CodeConstraintViolation, "Value violates a constraint")
+	case "22001", "22003", "22P02": // string_data_right_truncation, numeric_value_out_of_range, invalid_text_representation
+		return utils.NewBadRequestError("Invalid value entered")
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return utils.NewConflictError("The request conflicted with another one, please retry")
+	default:
+		return utils.NewInternalServerError("Internal Server Error")
 	}
 }
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/model"
+	"instashop/internal/utils"
+)
+
+// Role names stored on users and carried in access tokens.
+const (
+	RoleUser   = model.RoleUser
+	RoleEditor = model.RoleEditor
+	RoleAdmin  = model.RoleAdmin
+)
+
+// Permission is an action a role may perform.
+type Permission string
+
+// Enumeration of permissions.
+const (
+	PermProductCreate   Permission = "product:create"
+	PermProductRead     Permission = "product:read"
+	PermProductUpdate   Permission = "product:update"
+	PermProductDelete   Permission = "product:delete"
+	PermProductModerate Permission = "product:moderate"
+
+	PermOrderPlace   Permission = "order:place"
+	PermOrderRead    Permission = "order:read"
+	PermOrderCancel  Permission = "order:cancel"
+	PermOrderReadAll Permission = "order:read_all"
+	PermOrderApprove Permission = "order:approve"
+	PermOrderFulfil  Permission = "order:fulfil"
+
+	PermCartManage Permission = "cart:manage"
+
+	PermPaymentCreate Permission = "payment:create"
+	PermPaymentRefund Permission = "payment:refund"
+
+	PermUserManage Permission = "user:manage"
+
+	PermStoreManage Permission = "store:manage"
+	PermStoreOrders Permission = "store:orders"
+
+	PermCategoryManage Permission = "category:manage"
+
+	PermSystemHealth Permission = "system:health"
+)
+
+// customerPermissions are granted to every role. Any user can be made store
+// staff, so PermStoreOrders is here too; StoreService checks membership.
+var customerPermissions = []Permission{
+	PermOrderPlace, PermOrderRead, PermOrderCancel,
+	PermCartManage,
+	PermPaymentCreate,
+	PermStoreOrders,
+}
+
+// sellerPermissions are granted to editors and admins.
+var sellerPermissions = []Permission{
+	PermProductCreate, PermProductRead, PermProductUpdate, PermProductDelete,
+	PermStoreManage,
+}
+
+// adminPermissions are granted to admins only.
+var adminPermissions = []Permission{
+	PermProductModerate,
+	PermOrderReadAll, PermOrderApprove, PermOrderFulfil,
+	PermPaymentRefund,
+	PermUserManage,
+	PermCategoryManage,
+	PermSystemHealth,
+}
+
+// rolePermissions is the permission matrix. Roles not listed have no permissions.
+var rolePermissions = map[string]map[Permission]bool{
+	RoleUser:   permissionSet(customerPermissions),
+	RoleEditor: permissionSet(customerPermissions, sellerPermissions),
+	RoleAdmin:  permissionSet(customerPermissions, sellerPermissions, adminPermissions),
+}
+
+func permissionSet(groups ...[]Permission) map[Permission]bool {
+	set := make(map[Permission]bool)
+	for _, group := range groups {
+		for _, permission := range group {
+			set[permission] = true
+		}
+	}
+	return set
+}
+
+// HasPermission reports whether role grants permission.
+func HasPermission(role string, permission Permission) bool {
+	return rolePermissions[role][permission]
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID uint
+	Role   string
+	Claims *utils.Claims
+}
+
+// Can reports whether the principal's role grants permission.
+func (p *Principal) Can(permission Permission) bool {
+	return HasPermission(p.Role, permission)
+}
+
+const principalKey = "principal"
+
+// SetPrincipal stores the authenticated caller on the request context.
+func SetPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+}
+
+// GetPrincipal returns the authenticated caller set by VerifyToken.
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	value, exists := c.Get(principalKey)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+// RequireRole aborts the request with 403 unless the caller has one of roles.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			AbortWithError(c, utils.NewUnauthorizedError("Unauthorized"))
+			return
+		}
+
+		for _, role := range roles {
+			if principal.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		AbortWithError(c, utils.NewForbiddenError("You do not have permission to perform this action"))
+	}
+}
+
+// RequirePermission aborts the request with 403 unless the caller's role
+// grants every one of permissions.
+func RequirePermission(permissions ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			AbortWithError(c, utils.NewUnauthorizedError("Unauthorized"))
+			return
+		}
+
+		for _, permission := range permissions {
+			if !principal.Can(permission) {
+				AbortWithError(c, utils.NewForbiddenError("You do not have permission to perform this action"))
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
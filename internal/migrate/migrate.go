@@ -0,0 +1,336 @@
+// Package migrate applies the SQL migrations embedded in the binary. Applied
+// versions are recorded in the schema_versions table, and a Postgres advisory
+// lock keeps concurrent instances from migrating at the same time.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lockKey names the advisory lock held while migrating.
+const lockKey = "schema_versions"
+
+// migrationFile matches names like 20261017160000_create_things.up.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is the state of one migration. Known is false for versions recorded
+// in the database that have no migration file.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Known     bool       `json:"known"`
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New loads the migrations in fsys and returns a Migrator for db.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Load reads the migration files at the root of fsys, sorted by version.
+// Every migration needs an up and a down file, and versions must be unique.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		if migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied. Each migration runs in its own transaction.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := run(ctx, conn, migration, migration.Up,
+				"INSERT INTO schema_versions (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns the ones it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, errors.New("steps must be at least 1")
+	}
+
+	byVersion := make(map[int64]Migration, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var rolledBack []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		newest := make([]int64, 0, len(versions))
+		for version := range versions {
+			newest = append(newest, version)
+		}
+		sort.Slice(newest, func(i, j int) bool { return newest[i] > newest[j] })
+		if len(newest) > steps {
+			newest = newest[:steps]
+		}
+
+		for _, version := range newest {
+			migration, ok := byVersion[version]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but this binary has no file for it", version)
+			}
+			if err := run(ctx, conn, migration, migration.Down,
+				"DELETE FROM schema_versions WHERE version = $1", migration.Version); err != nil {
+				return err
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status reports every known migration and whether it is applied, followed
+// by any applied versions this binary does not know about.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			status := Status{Version: migration.Version, Name: migration.Name, Known: true}
+			if record, ok := versions[migration.Version]; ok {
+				appliedAt := record.AppliedAt
+				status.Applied, status.AppliedAt = true, &appliedAt
+				delete(versions, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+
+		var unknown []Status
+		for version, record := range versions {
+			appliedAt := record.AppliedAt
+			unknown = append(unknown, Status{Version: version, Name: record.Name, Applied: true, AppliedAt: &appliedAt})
+		}
+		sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+		statuses = append(statuses, unknown...)
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs fn on a single connection holding the migration lock, after
+// making sure the schema_versions table exists.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	// A session lock is held across the per-migration transactions and
+	// released below, or by Postgres if the process dies
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", lockKey)
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureTable creates schema_versions. A database previously managed by the
+// golang-migrate CLI has a schema_migrations table holding its current
+// version; those migrations are recorded as applied so they are not re-run.
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT to_regclass('schema_versions') IS NOT NULL").Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check schema_versions: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE schema_versions (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_versions: %w", err)
+	}
+
+	var legacy bool
+	if err := tx.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&legacy); err != nil {
+		return fmt.Errorf("failed to check schema_migrations: %w", err)
+	}
+	if legacy {
+		var version int64
+		var dirty bool
+		err := tx.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return fmt.Errorf("failed to read schema_migrations: %w", err)
+		case dirty:
+			return fmt.Errorf("schema_migrations marks version %d as dirty; fix the schema by hand and clear the flag before migrating", version)
+		default:
+			for _, migration := range m.Migrations {
+				if migration.Version > version {
+					break
+				}
+				if _, err := tx.ExecContext(ctx, "INSERT INTO schema_versions (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+					return fmt.Errorf("failed to import schema_migrations: %w", err)
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// appliedVersion is a row of schema_versions.
+type appliedVersion struct {
+	Name      string
+	AppliedAt time.Time
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]appliedVersion, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_versions")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]appliedVersion)
+	for rows.Next() {
+		var version int64
+		var record appliedVersion
+		if err := rows.Scan(&version, &record.Name, &record.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_versions: %w", err)
+		}
+		versions[version] = record
+	}
+	return versions, rows.Err()
+}
+
+// run executes a migration script and the bookkeeping statement in one
+// transaction, so a failed migration leaves no trace.
+func run(ctx context.Context, conn *sql.Conn, migration Migration, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
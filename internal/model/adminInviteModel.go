@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+)
+
+// AdminInvite lets the holder of an emailed link create an admin account, or
+// promote their existing account, until it expires. Only a hash of the token
+// is stored. InvitedBy is nil for the bootstrap invite that creates the first
+// admin.
+type AdminInvite struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Email      string     `gorm:"not null" json:"email"`
+	TokenHash  string     `gorm:"size:64;not null;unique" json:"-"`
+	InvitedBy  *uint      `json:"invited_by"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	AcceptedBy *uint      `json:"accepted_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RoleChange records who changed a user's role. ChangedBy is nil for the
+// bootstrap admin; InviteID is set when the change came from an invite.
+type RoleChange struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	FromRole  string    `gorm:"size:20" json:"from_role"`
+	ToRole    string    `gorm:"size:20;not null" json:"to_role"`
+	ChangedBy *uint     `json:"changed_by"`
+	InviteID  *uint     `json:"invite_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
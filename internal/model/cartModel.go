@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// Cart is a user's persistent shopping cart. Each user has at most one.
+type Cart struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null"`
+	Items     []CartItem `json:"items" gorm:"foreignKey:CartID"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CartItem is a product, or a variant of one, in a cart. UnitPrice is the
+// price when the item was added or last updated, so checkout can detect price
+// changes.
+type CartItem struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	CartID    uint            `json:"cart_id" gorm:"not null"`
+	ProductID uint            `json:"product_id" gorm:"not null"`
+	Product   *Product        `json:"product,omitempty"`
+	VariantID *uint           `json:"variant_id"`
+	Variant   *ProductVariant `json:"variant,omitempty"`
+	Quantity  int             `json:"quantity" gorm:"not null"`
+	UnitPrice float64         `json:"unit_price" gorm:"type:decimal(10,2);not null"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+// Category groups products. Categories form a tree: a category with a nil
+// ParentID is a top-level category.
+type Category struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	ParentID  *uint      `json:"parent_id"`
+	Name      string     `gorm:"size:100;not null" json:"name"`
+	Slug      string     `gorm:"size:100;not null;unique" json:"slug"`
+	Children  []Category `gorm:"foreignKey:ParentID" json:"children,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Tag is a free-form label on products. Tags are created the first time a
+// product uses them.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:50;not null" json:"name"`
+	Slug      string    `gorm:"size:50;not null;unique" json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
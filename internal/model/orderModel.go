@@ -8,16 +8,85 @@ type OrderStatusType string
 
 // Enumeration of order statuses.
 const (
-	OrderStatusPending  OrderStatusType = "pending"
-	OrderStatusDeclined OrderStatusType = "declined"
-	OrderStatusApproved OrderStatusType = "approved"
+	OrderStatusPending   OrderStatusType = "pending"
+	OrderStatusDeclined  OrderStatusType = "declined"
+	OrderStatusApproved  OrderStatusType = "approved"
+	OrderStatusCanceled  OrderStatusType = "canceled"
+	OrderStatusPaid      OrderStatusType = "paid"
+	OrderStatusShipped   OrderStatusType = "shipped"
+	OrderStatusDelivered OrderStatusType = "delivered"
 )
 
+// orderTransitions lists the statuses an order may move to from each status.
+// Declined, canceled and delivered are final. Only payments move orders to
+// paid, and paid orders can only be canceled by refunding the payment; the
+// services that set statuses by hand refuse both.
+var orderTransitions = map[OrderStatusType][]OrderStatusType{
+	OrderStatusPending:  {OrderStatusApproved, OrderStatusDeclined, OrderStatusCanceled, OrderStatusPaid},
+	OrderStatusApproved: {OrderStatusPaid, OrderStatusShipped, OrderStatusCanceled},
+	OrderStatusPaid:     {OrderStatusShipped, OrderStatusCanceled},
+	OrderStatusShipped:  {OrderStatusDelivered},
+}
+
+// IsValid reports whether s is one of the defined order statuses.
+func (s OrderStatusType) IsValid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusDeclined, OrderStatusApproved,
+		OrderStatusCanceled, OrderStatusPaid, OrderStatusShipped, OrderStatusDelivered:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatusType) CanTransitionTo(next OrderStatusType) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	ID        uint            `json:"id" gorm:"primaryKey"`
 	UserID    uint            `json:"user_id"`
-	Products  []Product       `json:"products" gorm:"many2many:order_products;"`
-	Status    OrderStatusType `json:"status" gorm:"type:varchar(10);default:'pending';not null"`
+	Items     []OrderItem     `json:"items" gorm:"foreignKey:OrderID"`
+	Total     float64         `json:"total" gorm:"type:decimal(10,2);not null;default:0"`
+	Status    OrderStatusType `json:"status" gorm:"type:varchar(20);default:'pending';not null"`
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
 }
+
+// OrderItem is a single line of an order. UnitPrice is a snapshot of the
+// product or variant price when the order was placed, so later product edits
+// do not change what the customer paid. VariantID is set for products sold in
+// variants.
+type OrderItem struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	OrderID   uint            `json:"order_id" gorm:"not null"`
+	ProductID uint            `json:"product_id" gorm:"not null"`
+	Product   *Product        `json:"product,omitempty"`
+	VariantID *uint           `json:"variant_id"`
+	Variant   *ProductVariant `json:"variant,omitempty"`
+	Quantity  int             `json:"quantity" gorm:"not null"`
+	UnitPrice float64         `json:"unit_price" gorm:"type:decimal(10,2);not null"`
+	LineTotal float64         `json:"line_total" gorm:"type:decimal(10,2);not null"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// OrderStatusHistory records a single status change of an order. FromStatus
+// is nil for the entry written when the order is placed, and ChangedBy is nil
+// for changes made by the system rather than a user.
+type OrderStatusHistory struct {
+	ID         uint             `json:"id" gorm:"primaryKey"`
+	OrderID    uint             `json:"order_id" gorm:"not null"`
+	FromStatus *OrderStatusType `json:"from_status" gorm:"type:varchar(20)"`
+	ToStatus   OrderStatusType  `json:"to_status" gorm:"type:varchar(20);not null"`
+	ChangedBy  *uint            `json:"changed_by"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+)
+
+// EmailStatus is the delivery state of a queued email.
+type EmailStatus string
+
+// Enumeration of email statuses.
+const (
+	EmailPending EmailStatus = "pending"
+	EmailSent    EmailStatus = "sent"
+	EmailFailed  EmailStatus = "failed"
+)
+
+// OutboxEmail is an email waiting to be sent by the mail workers. It is
+// rendered when queued, so sending needs nothing but the row. A pending email
+// is due once NextAttemptAt has passed. The bodies are cleared once the email
+// is sent or failed.
+type OutboxEmail struct {
+	ID            uint        `gorm:"primaryKey" json:"id"`
+	Recipient     string      `gorm:"size:255;not null" json:"recipient"`
+	Template      string      `gorm:"size:50;not null" json:"template"`
+	Subject       string      `gorm:"size:255;not null" json:"subject"`
+	TextBody      string      `gorm:"type:text;not null" json:"-"`
+	HTMLBody      string      `gorm:"type:text;not null" json:"-"`
+	Status        EmailStatus `gorm:"type:varchar(20);default:'pending';not null" json:"status"`
+	Attempts      int         `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time   `gorm:"not null" json:"next_attempt_at"`
+	LastError     string      `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt        *time.Time  `json:"sent_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+type PaymentStatusType string
+
+// Enumeration of payment statuses.
+const (
+	PaymentStatusPending   PaymentStatusType = "pending"
+	PaymentStatusSucceeded PaymentStatusType = "succeeded"
+	PaymentStatusFailed    PaymentStatusType = "failed"
+	PaymentStatusRefunded  PaymentStatusType = "refunded"
+)
+
+// Payment is an attempt to pay for an order through a payment provider.
+// Reference is the identifier shared with the provider.
+type Payment struct {
+	ID               uint              `json:"id" gorm:"primaryKey"`
+	OrderID          uint              `json:"order_id" gorm:"not null"`
+	Provider         string            `json:"provider" gorm:"size:50;not null"`
+	Reference        string            `json:"reference" gorm:"size:100;not null;unique"`
+	Amount           float64           `json:"amount" gorm:"type:decimal(10,2);not null"`
+	Currency         string            `json:"currency" gorm:"size:3;not null"`
+	Status           PaymentStatusType `json:"status" gorm:"type:varchar(20);default:'pending';not null"`
+	AuthorizationURL string            `json:"authorization_url,omitempty" gorm:"type:text"`
+	PaidAt           *time.Time        `json:"paid_at,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
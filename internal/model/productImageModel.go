@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// ProductImage is an image of a product. Images are shown in Position order
+// and at most one per product is the primary image.
+type ProductImage struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ProductID   uint      `gorm:"not null" json:"product_id"`
+	URL         string    `gorm:"size:500;not null" json:"url"`
+	StorageKey  string    `gorm:"size:255;not null" json:"-"`
+	ContentType string    `gorm:"size:50;not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	Position    int       `gorm:"not null;default:0" json:"position"`
+	IsPrimary   bool      `gorm:"not null;default:false" json:"is_primary"`
+	CreatedAt   time.Time `json:"created_at"`
+}
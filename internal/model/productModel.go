@@ -16,13 +16,23 @@ const (
 
 // Product represents a product in the system.
 type Product struct {
-	ID          uint       `gorm:"primaryKey" json:"id"`
-	UserID      uint       `gorm:"not null" json:"user_id"`
-	Name        string     `gorm:"size:255;not null" json:"name"`
-	Description string     `gorm:"type:text;not null" json:"description"`
-	Price       float64    `gorm:"type:decimal(10,2);not null" json:"price"`
-	Status      StatusType `gorm:"type:varchar(10);default:'pending';not null" json:"status"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
- }
- 
+	ID            uint             `gorm:"primaryKey" json:"id"`
+	UserID        uint             `gorm:"not null" json:"user_id"`
+	StoreID       uint             `gorm:"not null" json:"store_id"`
+	Name          string           `gorm:"size:255;not null" json:"name"`
+	Description   string           `gorm:"type:text;not null" json:"description"`
+	Price         float64          `gorm:"type:decimal(10,2);not null" json:"price"`
+	Stock         int              `gorm:"not null;default:0" json:"stock"`
+	CategoryID    *uint            `json:"category_id"`
+	Category      *Category        `json:"category,omitempty"`
+	Tags          []Tag            `gorm:"many2many:product_tags" json:"tags,omitempty"`
+	Status        StatusType       `gorm:"type:varchar(20);default:'pending';not null" json:"status"`
+	DeclineReason string           `gorm:"type:text" json:"decline_reason,omitempty"`
+	ReviewedBy    *uint            `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time       `json:"reviewed_at,omitempty"`
+	Images        []ProductImage   `gorm:"foreignKey:ProductID" json:"images,omitempty"`
+	Options       []ProductOption  `gorm:"foreignKey:ProductID" json:"options,omitempty"`
+	Variants      []ProductVariant `gorm:"foreignKey:ProductID" json:"variants,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
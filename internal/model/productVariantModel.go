@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+)
+
+// ProductOption is an option type of a product, such as size or colour, and
+// the values a buyer can choose from.
+type ProductOption struct {
+	ID        uint                 `gorm:"primaryKey" json:"id"`
+	ProductID uint                 `gorm:"not null" json:"product_id"`
+	Name      string               `gorm:"size:50;not null" json:"name"`
+	Position  int                  `gorm:"not null;default:0" json:"position"`
+	Values    []ProductOptionValue `gorm:"foreignKey:OptionID" json:"values"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// ProductOptionValue is one choice of an option, such as "M" or "Red".
+type ProductOptionValue struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	OptionID uint   `gorm:"not null" json:"option_id"`
+	Value    string `gorm:"size:50;not null" json:"value"`
+	Position int    `gorm:"not null;default:0" json:"position"`
+}
+
+// ProductVariant is a purchasable combination of option values with its own
+// SKU and stock. Price overrides the product price when set.
+type ProductVariant struct {
+	ID        uint                 `gorm:"primaryKey" json:"id"`
+	ProductID uint                 `gorm:"not null" json:"product_id"`
+	SKU       string               `gorm:"column:sku;size:64;not null" json:"sku"`
+	Price     *float64             `gorm:"type:decimal(10,2)" json:"price"`
+	Stock     int                  `gorm:"not null;default:0" json:"stock"`
+	Values    []ProductOptionValue `gorm:"many2many:product_variant_values;joinForeignKey:VariantID;joinReferences:OptionValueID" json:"values"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// UnitPrice is the price of the variant: its own price, or else the price of
+// its product.
+func (v *ProductVariant) UnitPrice(product *Product) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return product.Price
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+// Store is a seller's storefront. Products belong to a store, and the owner
+// and staff members manage its orders.
+type Store struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	OwnerID     uint         `gorm:"not null" json:"owner_id"`
+	Name        string       `gorm:"size:100;not null" json:"name"`
+	Slug        string       `gorm:"size:100;not null;unique" json:"slug"`
+	Description string       `gorm:"type:text;not null;default:''" json:"description"`
+	LogoURL     string       `gorm:"size:500;not null;default:''" json:"logo_url"`
+	Staff       []StoreStaff `gorm:"foreignKey:StoreID" json:"staff,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// StoreStaff is a user who helps run a store.
+type StoreStaff struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	StoreID   uint      `gorm:"not null" json:"store_id"`
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (StoreStaff) TableName() string {
+	return "store_staff"
+}
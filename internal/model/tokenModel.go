@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+// RefreshToken is a single-use token exchanged for a new access token. Only a
+// hash of the token is stored. Tokens rotated from the same login share a
+// FamilyID so the whole chain can be revoked when reuse is detected.
+type RefreshToken struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"not null"`
+	FamilyID   string    `gorm:"size:64;not null"`
+	TokenHash  string    `gorm:"size:64;not null;unique"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+	ReplacedBy *uint
+	CreatedAt  time.Time
+}
+
+// RevokedToken denylists an access token by its jti until it expires.
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti;primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
@@ -6,16 +6,38 @@ import (
 	"gorm.io/gorm"
 )
 
+// Roles a user can hold.
+const (
+	RoleUser   = "user"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleEditor, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username      string         `gorm:"unique;not null" json:"username"`
-	Email         string         `gorm:"unique;not null" json:"email"`
-	Password      string         `gorm:"not null" json:"password"`
-	VerifiedEmail bool           `gorm:"verifiedEmail" json:"verifiedEmail default:false"`
-	OtpToken      string         `gorm:"otpToken"`
-	ExpiredAt     time.Time      `gorm:"expired_at" json:"expiredAt"`
-	Role          string         `gorm:"not null;default:'user'" json:"role"`
-	CreatedAt     time.Time      `json:"createdAt"`
-	UpdatedAt     time.Time      `json:"updatedAt"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                       uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username                 string         `gorm:"unique;not null" json:"username"`
+	Email                    string         `gorm:"unique;not null" json:"email"`
+	Password                 string         `gorm:"not null" json:"password"`
+	VerifiedEmail            bool           `gorm:"verifiedEmail" json:"verifiedEmail default:false"`
+	OtpToken                 string         `gorm:"otpToken"`
+	ExpiredAt                time.Time      `gorm:"expired_at" json:"expiredAt"`
+	Role                     string         `gorm:"not null;default:'user'" json:"role"`
+	PasswordResetToken       string         `json:"-"`
+	PasswordResetExpiresAt   *time.Time     `json:"-"`
+	PasswordResetAttempts    int            `gorm:"not null;default:0" json:"-"`
+	PasswordResetLockedUntil *time.Time     `json:"-"`
+	PasswordChangedAt        *time.Time     `json:"-"`
+	TokensValidAfter         *time.Time     `json:"-"`
+	CreatedAt                time.Time      `json:"createdAt"`
+	UpdatedAt                time.Time      `json:"updatedAt"`
+	DeletedAt                gorm.DeletedAt `gorm:"index" json:"-"`
 }
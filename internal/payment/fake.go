@@ -0,0 +1,128 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// FakeSignatureHeader carries the signature of FakeProvider webhooks.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakeProvider is an in-memory provider for local development and tests.
+// Charges stay pending until Complete or Fail is called.
+type FakeProvider struct {
+	secret string
+
+	mu      sync.Mutex
+	charges map[string]*ChargeResult
+}
+
+// NewFakeProvider returns a provider that signs webhooks with secret.
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{
+		secret:  secret,
+		charges: make(map[string]*ChargeResult),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) InitializeCharge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.charges[req.Reference] = &ChargeResult{
+		Reference: req.Reference,
+		Status:    ChargeStatusPending,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+	}
+	return &Charge{
+		Reference:        req.Reference,
+		AuthorizationURL: "https://payments.example.test/pay/" + req.Reference,
+	}, nil
+}
+
+func (p *FakeProvider) VerifyCharge(ctx context.Context, reference string) (*ChargeResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[reference]
+	if !ok {
+		return nil, fmt.Errorf("unknown charge %q", reference)
+	}
+	result := *charge
+	return &result, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, reference string, amount int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[reference]
+	if !ok || charge.Status != ChargeStatusSuccess {
+		return fmt.Errorf("charge %q cannot be refunded", reference)
+	}
+	if amount > charge.Amount {
+		return fmt.Errorf("refund exceeds charge amount")
+	}
+	return nil
+}
+
+// ParseWebhook checks the FakeSignatureHeader header, an HMAC-SHA256 of the
+// payload keyed with the secret. The payload is a JSON ChargeResult.
+func (p *FakeProvider) ParseWebhook(payload []byte, header http.Header) (*ChargeResult, error) {
+	if !validSignature(sha256.New, p.secret, payload, header.Get(FakeSignatureHeader)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var result ChargeResult
+	if err := json.Unmarshal(payload, &result); err != nil {
+		return nil, fmt.Errorf("invalid fake webhook: %w", err)
+	}
+	return &result, nil
+}
+
+// Complete marks a charge as paid and returns the signed webhook the provider
+// would send for it.
+func (p *FakeProvider) Complete(reference string) (payload []byte, signature string, err error) {
+	return p.settle(reference, ChargeStatusSuccess)
+}
+
+// Fail marks a charge as failed and returns the signed webhook for it.
+func (p *FakeProvider) Fail(reference string) (payload []byte, signature string, err error) {
+	return p.settle(reference, ChargeStatusFailed)
+}
+
+func (p *FakeProvider) settle(reference string, status ChargeStatus) ([]byte, string, error) {
+	p.mu.Lock()
+	charge, ok := p.charges[reference]
+	if ok {
+		charge.Status = status
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unknown charge %q", reference)
+	}
+
+	payload, err := json.Marshal(charge)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, p.Sign(payload), nil
+}
+
+// Sign returns the signature the provider puts on a webhook payload.
+func (p *FakeProvider) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
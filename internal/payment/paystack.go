@@ -0,0 +1,172 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultPaystackBaseURL is the Paystack API endpoint.
+const DefaultPaystackBaseURL = "https://api.paystack.co"
+
+// PaystackProvider takes payments through the Paystack HTTP API.
+type PaystackProvider struct {
+	secretKey string
+	baseURL   string
+	client    *http.Client
+}
+
+// NewPaystackProvider returns a provider using secretKey. An empty baseURL
+// uses DefaultPaystackBaseURL.
+func NewPaystackProvider(secretKey, baseURL string) *PaystackProvider {
+	if baseURL == "" {
+		baseURL = DefaultPaystackBaseURL
+	}
+	return &PaystackProvider{
+		secretKey: secretKey,
+		baseURL:   baseURL,
+		client:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *PaystackProvider) Name() string {
+	return "paystack"
+}
+
+// paystackResponse is the envelope of every Paystack API response.
+type paystackResponse struct {
+	Status  bool            `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// paystackTransaction is the transaction object in verify responses and webhooks.
+type paystackTransaction struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+}
+
+func (t paystackTransaction) result() *ChargeResult {
+	status := ChargeStatusPending
+	switch t.Status {
+	case "success":
+		status = ChargeStatusSuccess
+	case "failed", "abandoned", "reversed":
+		status = ChargeStatusFailed
+	}
+	return &ChargeResult{
+		Reference: t.Reference,
+		Status:    status,
+		Amount:    t.Amount,
+		Currency:  t.Currency,
+	}
+}
+
+func (p *PaystackProvider) InitializeCharge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	body := map[string]interface{}{
+		"email":     req.Email,
+		"amount":    req.Amount,
+		"currency":  req.Currency,
+		"reference": req.Reference,
+	}
+	if req.CallbackURL != "" {
+		body["callback_url"] = req.CallbackURL
+	}
+
+	var data struct {
+		AuthorizationURL string `json:"authorization_url"`
+		Reference        string `json:"reference"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/transaction/initialize", body, &data); err != nil {
+		return nil, err
+	}
+
+	return &Charge{Reference: data.Reference, AuthorizationURL: data.AuthorizationURL}, nil
+}
+
+func (p *PaystackProvider) VerifyCharge(ctx context.Context, reference string) (*ChargeResult, error) {
+	var data paystackTransaction
+	if err := p.do(ctx, http.MethodGet, "/transaction/verify/"+url.PathEscape(reference), nil, &data); err != nil {
+		return nil, err
+	}
+	return data.result(), nil
+}
+
+func (p *PaystackProvider) Refund(ctx context.Context, reference string, amount int64) error {
+	body := map[string]interface{}{
+		"transaction": reference,
+		"amount":      amount,
+	}
+	return p.do(ctx, http.MethodPost, "/refund", body, nil)
+}
+
+// ParseWebhook checks the x-paystack-signature header, an HMAC-SHA512 of the
+// payload keyed with the secret key. Only charge.success and charge.failed
+// settle a charge; other events, such as refunds and disputes, carry a
+// transaction too but return a nil result.
+func (p *PaystackProvider) ParseWebhook(payload []byte, header http.Header) (*ChargeResult, error) {
+	if !validSignature(sha512.New, p.secretKey, payload, header.Get("x-paystack-signature")) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event struct {
+		Event string              `json:"event"`
+		Data  paystackTransaction `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid paystack webhook: %w", err)
+	}
+
+	switch event.Event {
+	case "charge.success", "charge.failed":
+		return event.Data.result(), nil
+	default:
+		return nil, nil
+	}
+}
+
+// do sends a request to the Paystack API and decodes the data field of the
+// response into out.
+func (p *PaystackProvider) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return fmt.Errorf("failed to encode paystack request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, &reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create paystack request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.secretKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("paystack request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope paystackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("invalid paystack response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 || !envelope.Status {
+		return fmt.Errorf("paystack error (HTTP %d): %s", resp.StatusCode, envelope.Message)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("invalid paystack response data: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package payment defines the interface the shop uses to take payments and
+// the providers implementing it.
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+)
+
+// ErrInvalidSignature is returned by ParseWebhook when the payload was not
+// signed by the provider.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// ChargeStatus is the state of a charge as reported by a provider.
+type ChargeStatus string
+
+// Enumeration of charge statuses.
+const (
+	ChargeStatusPending ChargeStatus = "pending"
+	ChargeStatusSuccess ChargeStatus = "success"
+	ChargeStatusFailed  ChargeStatus = "failed"
+)
+
+// ChargeRequest asks a provider to start collecting a payment. Amount is in
+// the currency's minor unit (kobo, cents).
+type ChargeRequest struct {
+	Reference   string
+	Email       string
+	Amount      int64
+	Currency    string
+	CallbackURL string
+}
+
+// Charge is a started payment. The customer completes it at AuthorizationURL.
+type Charge struct {
+	Reference        string
+	AuthorizationURL string
+}
+
+// ChargeResult is the outcome of a charge, from verification or a webhook.
+type ChargeResult struct {
+	Reference string       `json:"reference"`
+	Status    ChargeStatus `json:"status"`
+	Amount    int64        `json:"amount"`
+	Currency  string       `json:"currency"`
+}
+
+// PaymentProvider collects payments for orders.
+type PaymentProvider interface {
+	// Name identifies the provider in stored payments.
+	Name() string
+	// InitializeCharge starts a payment and returns where the customer pays.
+	InitializeCharge(ctx context.Context, req ChargeRequest) (*Charge, error)
+	// VerifyCharge asks the provider for the current state of a charge.
+	VerifyCharge(ctx context.Context, reference string) (*ChargeResult, error)
+	// Refund returns amount (in minor units) of a successful charge.
+	Refund(ctx context.Context, reference string, amount int64) error
+	// ParseWebhook checks the signature of a webhook request and decodes it.
+	// It returns ErrInvalidSignature if the signature does not match, and a
+	// nil result for events that do not settle a charge.
+	ParseWebhook(payload []byte, header http.Header) (*ChargeResult, error)
+}
+
+// validSignature compares a hex-encoded HMAC of payload against signature in
+// constant time.
+func validSignature(newHash func() hash.Hash, secret string, payload []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"instashop/internal/model"
+)
+
+type cartRepository struct {
+	db *gorm.DB
+}
+
+func (r *cartRepository) FindByUserForUpdate(ctx context.Context, userID uint) (*model.Cart, error) {
+	var cart model.Cart
+	if err := r.db.WithContext(ctx).Clauses(forUpdate).Where("user_id = ?", userID).First(&cart).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &cart, nil
+}
+
+func (r *cartRepository) FindWithItems(ctx context.Context, userID uint) (*model.Cart, error) {
+	var cart model.Cart
+	if err := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Product").
+		Preload("Items.Variant").
+		Where("user_id = ?", userID).
+		First(&cart).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &cart, nil
+}
+
+func (r *cartRepository) FindOrCreate(ctx context.Context, userID uint) (*model.Cart, error) {
+	db := r.db.WithContext(ctx)
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoNothing: true,
+	}).Create(&model.Cart{UserID: userID}).Error; err != nil {
+		return nil, err
+	}
+
+	var cart model.Cart
+	if err := db.Where("user_id = ?", userID).First(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (r *cartRepository) ListItems(ctx context.Context, cartID uint) ([]model.CartItem, error) {
+	var items []model.CartItem
+	err := r.db.WithContext(ctx).Where("cart_id = ?", cartID).Order("id").Find(&items).Error
+	return items, err
+}
+
+func (r *cartRepository) AddItem(ctx context.Context, item *model.CartItem) error {
+	// Each product, or each variant of it, has one line in the cart
+	conflict := clause.OnConflict{
+		Columns:     []clause.Column{{Name: "cart_id"}, {Name: "product_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "variant_id IS NULL"}}},
+	}
+	if item.VariantID != nil {
+		conflict.Columns = []clause.Column{{Name: "cart_id"}, {Name: "variant_id"}}
+		conflict.TargetWhere = clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "variant_id IS NOT NULL"}}}
+	}
+	conflict.DoUpdates = clause.Set{
+		{Column: clause.Column{Name: "quantity"}, Value: gorm.Expr("cart_items.quantity + EXCLUDED.quantity")},
+		{Column: clause.Column{Name: "unit_price"}, Value: gorm.Expr("EXCLUDED.unit_price")},
+		{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("EXCLUDED.updated_at")},
+	}
+	return r.db.WithContext(ctx).Clauses(conflict).Omit(clause.Associations).Create(item).Error
+}
+
+func (r *cartRepository) UpdateItem(ctx context.Context, userID, productID, variantID uint, quantity int, price float64) (bool, error) {
+	result := cartItemWhere(r.db.WithContext(ctx).Model(&model.CartItem{}), userID, productID, variantID).
+		Updates(map[string]interface{}{"quantity": quantity, "unit_price": price})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *cartRepository) RemoveItem(ctx context.Context, userID, productID, variantID uint) (bool, error) {
+	result := cartItemWhere(r.db.WithContext(ctx), userID, productID, variantID).Delete(&model.CartItem{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// cartItemWhere narrows db to the user's cart item for a product, or for one
+// of its variants when variantID is set.
+func cartItemWhere(db *gorm.DB, userID, productID, variantID uint) *gorm.DB {
+	db = db.Where("product_id = ? AND cart_id = (SELECT id FROM carts WHERE user_id = ?)", productID, userID)
+	if variantID == 0 {
+		return db.Where("variant_id IS NULL")
+	}
+	return db.Where("variant_id = ?", variantID)
+}
+
+func (r *cartRepository) SetItemPrice(ctx context.Context, itemID uint, price float64) error {
+	return r.db.WithContext(ctx).Model(&model.CartItem{}).Where("id = ?", itemID).Update("unit_price", price).Error
+}
+
+func (r *cartRepository) Clear(ctx context.Context, cartID uint) error {
+	return r.db.WithContext(ctx).Where("cart_id = ?", cartID).Delete(&model.CartItem{}).Error
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/model"
+)
+
+// categorySubtree selects the ids of the category with the given slug and all
+// of its descendants.
+const categorySubtree = `WITH RECURSIVE subtree AS (
+	SELECT id FROM categories WHERE slug = ?
+	UNION ALL
+	SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+) SELECT id FROM subtree`
+
+type catalogRepository struct {
+	db *gorm.DB
+}
+
+func (r *catalogRepository) ListProducts(ctx context.Context, filter CatalogFilter) ([]model.Product, error) {
+	query := r.db.WithContext(ctx).Model(&model.Product{}).Where("status = ?", model.StatusApproved)
+
+	if filter.StoreID != 0 {
+		query = query.Where("store_id = ?", filter.StoreID)
+	}
+	if filter.Category != "" {
+		query = query.Where("category_id IN ("+categorySubtree+")", filter.Category)
+	}
+	if filter.Tag != "" {
+		query = query.Where("id IN (SELECT pt.product_id FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE t.slug = ?)", filter.Tag)
+	}
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		query = query.Where("search_vector @@ websearch_to_tsquery('english', ?)", search)
+	}
+	if filter.MinPrice != nil {
+		query = query.Where("price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("price <= ?", *filter.MaxPrice)
+	}
+
+	if after := filter.After; after != nil {
+		switch filter.Sort {
+		case CatalogSortNewest:
+			query = query.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+		case CatalogSortPriceAsc:
+			query = query.Where("(price, id) > (?, ?)", after.Price, after.ID)
+		case CatalogSortPriceDesc:
+			query = query.Where("(price, id) < (?, ?)", after.Price, after.ID)
+		}
+	}
+
+	switch filter.Sort {
+	case CatalogSortNewest:
+		query = query.Order("created_at DESC, id DESC")
+	case CatalogSortPriceAsc:
+		query = query.Order("price ASC, id ASC")
+	case CatalogSortPriceDesc:
+		query = query.Order("price DESC, id DESC")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var products []model.Product
+	if err := PreloadProductDetails(query).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
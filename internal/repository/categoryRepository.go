@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"instashop/internal/model"
+)
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+func (r *categoryRepository) List(ctx context.Context) ([]model.Category, error) {
+	var categories []model.Category
+	if err := r.db.WithContext(ctx).Order("name, id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *categoryRepository) FindByID(ctx context.Context, id uint) (*model.Category, error) {
+	var category model.Category
+	if err := r.db.WithContext(ctx).First(&category, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &category, nil
+}
+
+func (r *categoryRepository) SlugTaken(ctx context.Context, slug string, exceptID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.Category{}).
+		Where("slug = ? AND id <> ?", slug, exceptID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *categoryRepository) Create(ctx context.Context, category *model.Category) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(category).Error
+}
+
+func (r *categoryRepository) Save(ctx context.Context, category *model.Category) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(category).Error
+}
+
+func (r *categoryRepository) HasChildren(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.Category{}).
+		Where("parent_id = ?", id).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Delete relies on products.category_id being ON DELETE SET NULL.
+func (r *categoryRepository) Delete(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Delete(&model.Category{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *categoryRepository) Ancestors(ctx context.Context, id uint) ([]uint, error) {
+	var ancestors []uint
+	if err := r.db.WithContext(ctx).Raw(`WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM categories WHERE id = ?
+		UNION ALL
+		SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+	) SELECT id FROM ancestors`, id).Scan(&ancestors).Error; err != nil {
+		return nil, err
+	}
+	return ancestors, nil
+}
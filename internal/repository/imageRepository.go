@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/model"
+)
+
+type imageRepository struct {
+	db *gorm.DB
+}
+
+func (r *imageRepository) List(ctx context.Context, productID uint) ([]model.ProductImage, error) {
+	var images []model.ProductImage
+	if err := OrderImages(r.db.WithContext(ctx)).Where("product_id = ?", productID).Find(&images).Error; err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (r *imageRepository) Find(ctx context.Context, productID, imageID uint) (*model.ProductImage, error) {
+	var image model.ProductImage
+	if err := r.db.WithContext(ctx).
+		Where("id = ? AND product_id = ?", imageID, productID).
+		First(&image).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &image, nil
+}
+
+func (r *imageRepository) Create(ctx context.Context, images []model.ProductImage) error {
+	return r.db.WithContext(ctx).Create(&images).Error
+}
+
+func (r *imageRepository) SetPosition(ctx context.Context, imageID uint, position int) error {
+	return r.db.WithContext(ctx).Model(&model.ProductImage{}).
+		Where("id = ?", imageID).
+		Update("position", position).Error
+}
+
+func (r *imageRepository) SetPrimary(ctx context.Context, image *model.ProductImage) error {
+	db := r.db.WithContext(ctx)
+	// Clear the old primary first to keep the unique index satisfied
+	if err := db.Model(&model.ProductImage{}).
+		Where("product_id = ? AND is_primary", image.ProductID).
+		Update("is_primary", false).Error; err != nil {
+		return err
+	}
+	if err := db.Model(image).Update("is_primary", true).Error; err != nil {
+		return err
+	}
+	image.IsPrimary = true
+	return nil
+}
+
+func (r *imageRepository) Delete(ctx context.Context, imageID uint) error {
+	return r.db.WithContext(ctx).Delete(&model.ProductImage{}, imageID).Error
+}
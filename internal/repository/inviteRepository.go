@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/model"
+)
+
+type inviteRepository struct {
+	db *gorm.DB
+}
+
+func (r *inviteRepository) Create(ctx context.Context, invite *model.AdminInvite) error {
+	return r.db.WithContext(ctx).Create(invite).Error
+}
+
+func (r *inviteRepository) FindByTokenHashForUpdate(ctx context.Context, tokenHash string) (*model.AdminInvite, error) {
+	var invite model.AdminInvite
+	if err := r.db.WithContext(ctx).Clauses(forUpdate).
+		Where("token_hash = ?", tokenHash).
+		First(&invite).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &invite, nil
+}
+
+func (r *inviteRepository) ExpireBootstrapInvites(ctx context.Context) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&model.AdminInvite{}).
+		Where("invited_by IS NULL AND accepted_at IS NULL AND expires_at > ?", now).
+		Update("expires_at", now).Error
+}
+
+func (r *inviteRepository) Save(ctx context.Context, invite *model.AdminInvite) error {
+	return r.db.WithContext(ctx).Save(invite).Error
+}
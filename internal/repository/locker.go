@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// advisoryLocker takes Postgres transaction-level advisory locks, which are
+// released when the transaction ends.
+type advisoryLocker struct {
+	db *gorm.DB
+}
+
+func (l *advisoryLocker) Lock(ctx context.Context, name string) error {
+	return l.db.WithContext(ctx).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", name).Error
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type carts struct {
+	s *Store
+}
+
+func (r *carts) FindByUserForUpdate(ctx context.Context, userID uint) (*model.Cart, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, cart := range r.s.data.carts {
+		if cart.UserID == userID {
+			return &cart, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *carts) ListItems(ctx context.Context, cartID uint) ([]model.CartItem, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var items []model.CartItem
+	for _, item := range r.s.data.cartItems {
+		if item.CartID == cartID {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+func (r *carts) SetItemPrice(ctx context.Context, itemID uint, price float64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if item, ok := r.s.data.cartItems[itemID]; ok {
+		item.UnitPrice = price
+		item.UpdatedAt = time.Now()
+		r.s.data.cartItems[itemID] = item
+	}
+	return nil
+}
+
+func (r *carts) Clear(ctx context.Context, cartID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, item := range r.s.data.cartItems {
+		if item.CartID == cartID {
+			delete(r.s.data.cartItems, id)
+		}
+	}
+	return nil
+}
+
+func (r *carts) FindWithItems(ctx context.Context, userID uint) (*model.Cart, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	cart, ok := r.cartOf(userID)
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	for _, item := range r.s.data.cartItems {
+		if item.CartID != cart.ID {
+			continue
+		}
+		if product, ok := r.s.data.products[item.ProductID]; ok {
+			item.Product = &product
+		}
+		if item.VariantID != nil {
+			if variant, ok := r.s.data.variants[*item.VariantID]; ok {
+				item.Variant = &variant
+			}
+		}
+		cart.Items = append(cart.Items, item)
+	}
+	sort.Slice(cart.Items, func(i, j int) bool { return cart.Items[i].ID < cart.Items[j].ID })
+	return &cart, nil
+}
+
+func (r *carts) FindOrCreate(ctx context.Context, userID uint) (*model.Cart, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if cart, ok := r.cartOf(userID); ok {
+		return &cart, nil
+	}
+	cart := model.Cart{ID: r.s.data.newID(), UserID: userID, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	r.s.data.carts[cart.ID] = cart
+	return &cart, nil
+}
+
+// cartOf returns the user's cart without its items.
+func (r *carts) cartOf(userID uint) (model.Cart, bool) {
+	for _, cart := range r.s.data.carts {
+		if cart.UserID == userID {
+			return cart, true
+		}
+	}
+	return model.Cart{}, false
+}
+
+func (r *carts) AddItem(ctx context.Context, item *model.CartItem) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, line := range r.s.data.cartItems {
+		if line.CartID != item.CartID || !sameLine(line, item.ProductID, item.VariantID) {
+			continue
+		}
+		line.Quantity += item.Quantity
+		line.UnitPrice = item.UnitPrice
+		line.UpdatedAt = time.Now()
+		r.s.data.cartItems[id] = line
+		*item = line
+		return nil
+	}
+	item.ID = r.s.data.newID()
+	item.CreatedAt, item.UpdatedAt = time.Now(), time.Now()
+	stored := *item
+	stored.Product, stored.Variant = nil, nil
+	r.s.data.cartItems[item.ID] = stored
+	return nil
+}
+
+func (r *carts) UpdateItem(ctx context.Context, userID, productID, variantID uint, quantity int, price float64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	id, ok := r.findLine(userID, productID, variantID)
+	if !ok {
+		return false, nil
+	}
+	line := r.s.data.cartItems[id]
+	line.Quantity, line.UnitPrice, line.UpdatedAt = quantity, price, time.Now()
+	r.s.data.cartItems[id] = line
+	return true, nil
+}
+
+func (r *carts) RemoveItem(ctx context.Context, userID, productID, variantID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	id, ok := r.findLine(userID, productID, variantID)
+	if ok {
+		delete(r.s.data.cartItems, id)
+	}
+	return ok, nil
+}
+
+// findLine returns the id of the line in the user's cart for the product, or
+// for one of its variants when variantID is not 0.
+func (r *carts) findLine(userID, productID, variantID uint) (uint, bool) {
+	cart, ok := r.cartOf(userID)
+	if !ok {
+		return 0, false
+	}
+	var variant *uint
+	if variantID != 0 {
+		variant = &variantID
+	}
+	for id, line := range r.s.data.cartItems {
+		if line.CartID == cart.ID && line.ProductID == productID && sameLine(line, productID, variant) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// sameLine reports whether the cart line is for the product without a
+// variant, or for the variant, matching the unique indexes on cart_items.
+func sameLine(line model.CartItem, productID uint, variantID *uint) bool {
+	if variantID == nil {
+		return line.VariantID == nil && line.ProductID == productID
+	}
+	return line.VariantID != nil && *line.VariantID == *variantID
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+// catalog searches names and descriptions for the search text, ignoring
+// case, in place of Postgres full-text search.
+type catalog struct {
+	s *Store
+}
+
+func (r *catalog) ListProducts(ctx context.Context, filter repository.CatalogFilter) ([]model.Product, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var subtree map[uint]bool
+	if filter.Category != "" {
+		subtree = r.s.data.subtree(filter.Category)
+	}
+	search := strings.ToLower(strings.TrimSpace(filter.Search))
+
+	var list []model.Product
+	for _, product := range r.s.data.products {
+		if product.Status != model.StatusApproved ||
+			filter.StoreID != 0 && product.StoreID != filter.StoreID ||
+			subtree != nil && (product.CategoryID == nil || !subtree[*product.CategoryID]) ||
+			filter.Tag != "" && !hasTag(r.s.data.productTags[product.ID], filter.Tag) ||
+			search != "" && !strings.Contains(strings.ToLower(product.Name+" "+product.Description), search) ||
+			filter.MinPrice != nil && product.Price < *filter.MinPrice ||
+			filter.MaxPrice != nil && product.Price > *filter.MaxPrice {
+			continue
+		}
+		if filter.After != nil && !before(filter.Sort, *filter.After, product) {
+			continue
+		}
+		list = append(list, product)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		a := repository.CatalogPosition{CreatedAt: list[i].CreatedAt, Price: list[i].Price, ID: list[i].ID}
+		return before(filter.Sort, a, list[j])
+	})
+	if filter.Limit > 0 && len(list) > filter.Limit {
+		list = list[:filter.Limit]
+	}
+	for i := range list {
+		r.s.data.loadDetails(&list[i])
+	}
+	return list, nil
+}
+
+// before reports whether the position comes before the product in the
+// catalog order.
+func before(sortBy string, position repository.CatalogPosition, product model.Product) bool {
+	switch sortBy {
+	case repository.CatalogSortPriceAsc:
+		if position.Price != product.Price {
+			return position.Price < product.Price
+		}
+		return position.ID < product.ID
+	case repository.CatalogSortPriceDesc:
+		if position.Price != product.Price {
+			return position.Price > product.Price
+		}
+		return position.ID > product.ID
+	}
+	if !position.CreatedAt.Equal(product.CreatedAt) {
+		return position.CreatedAt.After(product.CreatedAt)
+	}
+	return position.ID > product.ID
+}
+
+func hasTag(slugs []string, slug string) bool {
+	for _, s := range slugs {
+		if s == slug {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type categories struct {
+	s *Store
+}
+
+func (r *categories) List(ctx context.Context) ([]model.Category, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []model.Category
+	for _, category := range r.s.data.categories {
+		list = append(list, category)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (r *categories) FindByID(ctx context.Context, id uint) (*model.Category, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	category, ok := r.s.data.categories[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &category, nil
+}
+
+func (r *categories) SlugTaken(ctx context.Context, slug string, exceptID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, category := range r.s.data.categories {
+		if category.Slug == slug && category.ID != exceptID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *categories) Create(ctx context.Context, category *model.Category) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.checkUnique(category); err != nil {
+		return err
+	}
+	category.ID = r.s.data.newID()
+	category.CreatedAt, category.UpdatedAt = time.Now(), time.Now()
+	stored := *category
+	stored.Children = nil
+	r.s.data.categories[category.ID] = stored
+	return nil
+}
+
+func (r *categories) Save(ctx context.Context, category *model.Category) error {
+	if category.ID == 0 {
+		return r.Create(ctx, category)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.checkUnique(category); err != nil {
+		return err
+	}
+	category.UpdatedAt = time.Now()
+	stored := *category
+	stored.Children = nil
+	r.s.data.categories[category.ID] = stored
+	return nil
+}
+
+// checkUnique enforces the unique slug of categories.
+func (r *categories) checkUnique(category *model.Category) error {
+	for _, other := range r.s.data.categories {
+		if other.ID != category.ID && other.Slug == category.Slug {
+			return fmt.Errorf("%w: category %q", ErrDuplicate, category.Slug)
+		}
+	}
+	return nil
+}
+
+func (r *categories) HasChildren(ctx context.Context, id uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, category := range r.s.data.categories {
+		if category.ParentID != nil && *category.ParentID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *categories) Delete(ctx context.Context, id uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.categories[id]; !ok {
+		return false, nil
+	}
+	delete(r.s.data.categories, id)
+	for productID, product := range r.s.data.products {
+		if product.CategoryID != nil && *product.CategoryID == id {
+			product.CategoryID = nil
+			r.s.data.products[productID] = product
+		}
+	}
+	return true, nil
+}
+
+func (r *categories) Ancestors(ctx context.Context, id uint) ([]uint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var ancestors []uint
+	for {
+		category, ok := r.s.data.categories[id]
+		if !ok {
+			return ancestors, nil
+		}
+		ancestors = append(ancestors, category.ID)
+		if category.ParentID == nil {
+			return ancestors, nil
+		}
+		id = *category.ParentID
+	}
+}
+
+// subtree returns the ids of the category with the slug and all of its
+// descendants.
+func (d *data) subtree(slug string) map[uint]bool {
+	ids := make(map[uint]bool)
+	for _, category := range d.categories {
+		if category.Slug == slug {
+			ids[category.ID] = true
+		}
+	}
+	for grown := true; grown; {
+		grown = false
+		for _, category := range d.categories {
+			if category.ParentID != nil && ids[*category.ParentID] && !ids[category.ID] {
+				ids[category.ID] = true
+				grown = true
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type images struct {
+	s *Store
+}
+
+func (r *images) List(ctx context.Context, productID uint) ([]model.ProductImage, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.data.imagesOf(productID), nil
+}
+
+func (r *images) Find(ctx context.Context, productID, imageID uint) (*model.ProductImage, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	image, ok := r.s.data.images[imageID]
+	if !ok || image.ProductID != productID {
+		return nil, repository.ErrNotFound
+	}
+	return &image, nil
+}
+
+func (r *images) Create(ctx context.Context, list []model.ProductImage) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i := range list {
+		list[i].ID = r.s.data.newID()
+		list[i].CreatedAt = time.Now()
+		r.s.data.images[list[i].ID] = list[i]
+	}
+	return nil
+}
+
+func (r *images) SetPosition(ctx context.Context, imageID uint, position int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if image, ok := r.s.data.images[imageID]; ok {
+		image.Position = position
+		r.s.data.images[imageID] = image
+	}
+	return nil
+}
+
+func (r *images) SetPrimary(ctx context.Context, image *model.ProductImage) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, other := range r.s.data.images {
+		if other.ProductID == image.ProductID {
+			other.IsPrimary = id == image.ID
+			r.s.data.images[id] = other
+		}
+	}
+	image.IsPrimary = true
+	return nil
+}
+
+func (r *images) Delete(ctx context.Context, imageID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.data.images, imageID)
+	return nil
+}
+
+// imagesOf returns the images of the product in display order.
+func (d *data) imagesOf(productID uint) []model.ProductImage {
+	var list []model.ProductImage
+	for _, image := range d.images {
+		if image.ProductID == productID {
+			list = append(list, image)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Position != list[j].Position {
+			return list[i].Position < list[j].Position
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
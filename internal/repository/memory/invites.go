@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type invites struct {
+	s *Store
+}
+
+func (r *invites) Create(ctx context.Context, invite *model.AdminInvite) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, other := range r.s.data.invites {
+		if other.TokenHash == invite.TokenHash {
+			return fmt.Errorf("%w: admin invite", ErrDuplicate)
+		}
+	}
+	invite.ID = r.s.data.newID()
+	invite.CreatedAt = time.Now()
+	r.s.data.invites[invite.ID] = *invite
+	return nil
+}
+
+func (r *invites) FindByTokenHashForUpdate(ctx context.Context, tokenHash string) (*model.AdminInvite, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, invite := range r.s.data.invites {
+		if invite.TokenHash == tokenHash {
+			return &invite, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *invites) ExpireBootstrapInvites(ctx context.Context) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	now := time.Now()
+	for id, invite := range r.s.data.invites {
+		if invite.InvitedBy == nil && invite.AcceptedAt == nil && invite.ExpiresAt.After(now) {
+			invite.ExpiresAt = now
+			r.s.data.invites[id] = invite
+		}
+	}
+	return nil
+}
+
+func (r *invites) Save(ctx context.Context, invite *model.AdminInvite) error {
+	if invite.ID == 0 {
+		return r.Create(ctx, invite)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.data.invites[invite.ID] = *invite
+	return nil
+}
+
+// locker needs no locks: units of work already run one at a time.
+type locker struct{}
+
+func (locker) Lock(ctx context.Context, name string) error {
+	return nil
+}
@@ -0,0 +1,282 @@
+// Package memory implements the repositories in memory, for unit tests of
+// the services that need no database. Units of work run one at a time, which
+// stands in for row locks, and roll every change back when they fail.
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+// ErrDuplicate is returned when a record would break a unique constraint.
+var ErrDuplicate = errors.New("memory: duplicate key")
+
+// Store holds the records of every repository.
+type Store struct {
+	// work serializes units of work; mu guards data
+	work   sync.Mutex
+	mu     sync.Mutex
+	data   data
+	emails mailer.Mailer
+}
+
+type data struct {
+	nextID        uint
+	users         map[uint]model.User
+	roleChanges   []model.RoleChange
+	refreshTokens map[uint]model.RefreshToken
+	revokedTokens map[string]model.RevokedToken // by jti
+	invites       map[uint]model.AdminInvite
+	stores        map[uint]model.Store
+	staff         []model.StoreStaff
+	categories    map[uint]model.Category
+	tags          map[string]model.Tag // by slug
+	productTags   map[uint][]string    // tag slugs by product
+	products      map[uint]model.Product
+	images        map[uint]model.ProductImage
+	options       map[uint]model.ProductOption
+	optionValues  map[uint]model.ProductOptionValue
+	variants      map[uint]model.ProductVariant
+	variantValues map[uint][]uint // option value ids by variant
+	orders        map[uint]model.Order
+	items         map[uint]model.OrderItem
+	history       []model.OrderStatusHistory
+	payments      map[uint]model.Payment
+	carts         map[uint]model.Cart
+	cartItems     map[uint]model.CartItem
+}
+
+// New returns an empty Store. emails receives the email the repositories
+// queue, once the unit of work queueing it succeeds; it may be nil.
+func New(emails mailer.Mailer) *Store {
+	return &Store{
+		emails: emails,
+		data: data{
+			users:         make(map[uint]model.User),
+			refreshTokens: make(map[uint]model.RefreshToken),
+			revokedTokens: make(map[string]model.RevokedToken),
+			invites:       make(map[uint]model.AdminInvite),
+			stores:        make(map[uint]model.Store),
+			categories:    make(map[uint]model.Category),
+			tags:          make(map[string]model.Tag),
+			productTags:   make(map[uint][]string),
+			products:      make(map[uint]model.Product),
+			images:        make(map[uint]model.ProductImage),
+			options:       make(map[uint]model.ProductOption),
+			optionValues:  make(map[uint]model.ProductOptionValue),
+			variants:      make(map[uint]model.ProductVariant),
+			variantValues: make(map[uint][]uint),
+			orders:        make(map[uint]model.Order),
+			items:         make(map[uint]model.OrderItem),
+			payments:      make(map[uint]model.Payment),
+			carts:         make(map[uint]model.Cart),
+			cartItems:     make(map[uint]model.CartItem),
+		},
+	}
+}
+
+// Repositories returns repositories over the store outside a unit of work.
+func (s *Store) Repositories() repository.Repositories {
+	return s.repositories(s.emails)
+}
+
+func (s *Store) repositories(emails mailer.Mailer) repository.Repositories {
+	return repository.Repositories{
+		Users:      &users{s},
+		Tokens:     &tokens{s},
+		Invites:    &invites{s},
+		Stores:     &stores{s},
+		Products:   &products{s},
+		Catalog:    &catalog{s},
+		Categories: &categories{s},
+		Images:     &images{s},
+		Variants:   &variants{s},
+		Orders:     &orders{s},
+		Payments:   &payments{s},
+		Carts:      &carts{s},
+		Locks:      locker{},
+		Emails:     emails,
+	}
+}
+
+// Do runs fn as a unit of work. Email fn queues is sent when fn succeeds, and
+// every change fn made is undone when it fails. Changes made outside units of
+// work while fn runs are undone with them.
+func (s *Store) Do(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	s.work.Lock()
+	defer s.work.Unlock()
+
+	s.mu.Lock()
+	snapshot := s.data.clone()
+	s.mu.Unlock()
+
+	queued := &pendingEmails{}
+	if err := fn(s.repositories(queued)); err != nil {
+		s.mu.Lock()
+		s.data = snapshot
+		s.mu.Unlock()
+		return err
+	}
+
+	for _, email := range queued.emails {
+		if s.emails == nil {
+			return errors.New("memory: no mailer to send queued email")
+		}
+		if err := s.emails.Send(ctx, email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateStore adds a store, for tests to set up products and orders.
+func (s *Store) CreateStore(store *model.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	store.ID = s.data.newID()
+	store.CreatedAt, store.UpdatedAt = time.Now(), time.Now()
+	stored := *store
+	stored.Staff = nil
+	s.data.stores[store.ID] = stored
+}
+
+// AddStaff puts a user on the staff of a store.
+func (s *Store) AddStaff(storeID, userID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.staff = append(s.data.staff, model.StoreStaff{ID: s.data.newID(), StoreID: storeID, UserID: userID, CreatedAt: time.Now()})
+}
+
+// CreateCategory adds a category products can be filed under.
+func (s *Store) CreateCategory(category *model.Category) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	category.ID = s.data.newID()
+	category.CreatedAt, category.UpdatedAt = time.Now(), time.Now()
+	stored := *category
+	stored.Children = nil
+	s.data.categories[category.ID] = stored
+}
+
+// CreateVariant adds a variant to a product, linked to its Values, which
+// must already exist.
+func (s *Store) CreateVariant(variant *model.ProductVariant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.createVariant(variant)
+}
+
+// AddCartItem puts an item in the user's cart, creating the cart if needed.
+func (s *Store) AddCartItem(userID uint, item *model.CartItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cart := range s.data.carts {
+		if cart.UserID == userID {
+			item.CartID = cart.ID
+		}
+	}
+	if item.CartID == 0 {
+		cart := model.Cart{ID: s.data.newID(), UserID: userID, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		s.data.carts[cart.ID] = cart
+		item.CartID = cart.ID
+	}
+	item.ID = s.data.newID()
+	item.CreatedAt, item.UpdatedAt = time.Now(), time.Now()
+	stored := *item
+	stored.Product, stored.Variant = nil, nil
+	s.data.cartItems[item.ID] = stored
+}
+
+// CartItems returns the items in the user's cart.
+func (s *Store) CartItems(userID uint) []model.CartItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []model.CartItem
+	for _, item := range s.data.cartItems {
+		if cart := s.data.carts[item.CartID]; cart.UserID == userID {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+// RoleChanges returns the role change audit log.
+func (s *Store) RoleChanges() []model.RoleChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]model.RoleChange(nil), s.data.roleChanges...)
+}
+
+// newID returns the next id. Every table shares one sequence.
+func (d *data) newID() uint {
+	d.nextID++
+	return d.nextID
+}
+
+func (d data) clone() data {
+	c := d
+	c.users = cloneMap(d.users)
+	c.roleChanges = append([]model.RoleChange(nil), d.roleChanges...)
+	c.refreshTokens = cloneMap(d.refreshTokens)
+	c.revokedTokens = cloneMap(d.revokedTokens)
+	c.invites = cloneMap(d.invites)
+	c.stores = cloneMap(d.stores)
+	c.staff = append([]model.StoreStaff(nil), d.staff...)
+	c.categories = cloneMap(d.categories)
+	c.tags = cloneMap(d.tags)
+	c.productTags = make(map[uint][]string, len(d.productTags))
+	for id, slugs := range d.productTags {
+		c.productTags[id] = append([]string(nil), slugs...)
+	}
+	c.products = cloneMap(d.products)
+	c.images = cloneMap(d.images)
+	c.options = cloneMap(d.options)
+	c.optionValues = cloneMap(d.optionValues)
+	c.variants = cloneMap(d.variants)
+	c.variantValues = make(map[uint][]uint, len(d.variantValues))
+	for id, values := range d.variantValues {
+		c.variantValues[id] = append([]uint(nil), values...)
+	}
+	c.orders = cloneMap(d.orders)
+	c.items = cloneMap(d.items)
+	c.history = append([]model.OrderStatusHistory(nil), d.history...)
+	c.payments = cloneMap(d.payments)
+	c.carts = cloneMap(d.carts)
+	c.cartItems = cloneMap(d.cartItems)
+	return c
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// pendingEmails holds the email of a unit of work until it succeeds.
+type pendingEmails struct {
+	emails []mailer.Email
+}
+
+func (p *pendingEmails) Send(ctx context.Context, email mailer.Email) error {
+	if _, err := mailer.Render(email); err != nil {
+		return err
+	}
+	p.emails = append(p.emails, email)
+	return nil
+}
+
+func (p *pendingEmails) WithTx(*gorm.DB) mailer.Mailer {
+	return p
+}
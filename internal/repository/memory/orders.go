@@ -0,0 +1,211 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type orders struct {
+	s *Store
+}
+
+func (r *orders) Create(ctx context.Context, order *model.Order) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	order.ID = r.s.data.newID()
+	order.CreatedAt, order.UpdatedAt = time.Now(), time.Now()
+	if order.Status == "" {
+		order.Status = model.OrderStatusPending
+	}
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.ID = r.s.data.newID()
+		item.OrderID = order.ID
+		item.CreatedAt = time.Now()
+		stored := *item
+		stored.Product, stored.Variant = nil, nil
+		r.s.data.items[item.ID] = stored
+	}
+	r.s.data.orders[order.ID] = withoutItems(*order)
+	return nil
+}
+
+func withoutItems(order model.Order) model.Order {
+	order.Items = nil
+	return order
+}
+
+func (r *orders) FindByID(ctx context.Context, id uint) (*model.Order, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	order, ok := r.s.data.orders[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &order, nil
+}
+
+func (r *orders) FindByIDForUpdate(ctx context.Context, id uint) (*model.Order, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *orders) FindWithItems(ctx context.Context, id uint) (*model.Order, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	order, ok := r.s.data.orders[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	order.Items = r.itemsOf(order.ID)
+	return &order, nil
+}
+
+func (r *orders) List(ctx context.Context, filter repository.OrderFilter) ([]model.Order, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []model.Order
+	for _, order := range r.s.data.orders {
+		if filter.Status != "" && order.Status != filter.Status ||
+			filter.UserID != 0 && order.UserID != filter.UserID ||
+			!filter.From.IsZero() && order.CreatedAt.Before(filter.From) ||
+			!filter.To.IsZero() && !order.CreatedAt.Before(filter.To) {
+			continue
+		}
+		order.Items = r.itemsOf(order.ID)
+		list = append(list, order)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+// itemsOf returns the items of the order in id order, with their products
+// and variants.
+func (r *orders) itemsOf(orderID uint) []model.OrderItem {
+	var items []model.OrderItem
+	for _, item := range r.s.data.items {
+		if item.OrderID != orderID {
+			continue
+		}
+		if product, ok := r.s.data.products[item.ProductID]; ok {
+			item.Product = &product
+		}
+		if item.VariantID != nil {
+			if variant, ok := r.s.data.variants[*item.VariantID]; ok {
+				item.Variant = &variant
+			}
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func (r *orders) ListItems(ctx context.Context, orderID uint) ([]model.OrderItem, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var items []model.OrderItem
+	for _, item := range r.s.data.items {
+		if item.OrderID == orderID {
+			items = append(items, item)
+		}
+	}
+	// By product, then variant with products without a variant last, as
+	// Postgres sorts NULLs
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		if a.VariantID == nil || b.VariantID == nil {
+			return b.VariantID == nil && a.VariantID != nil
+		}
+		return *a.VariantID < *b.VariantID
+	})
+	return items, nil
+}
+
+func (r *orders) Save(ctx context.Context, order *model.Order) error {
+	if order.ID == 0 {
+		return r.Create(ctx, order)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	order.UpdatedAt = time.Now()
+	r.s.data.orders[order.ID] = withoutItems(*order)
+	return nil
+}
+
+func (r *orders) AddHistory(ctx context.Context, entry *model.OrderStatusHistory) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	entry.ID = r.s.data.newID()
+	entry.CreatedAt = time.Now()
+	r.s.data.history = append(r.s.data.history, *entry)
+	return nil
+}
+
+func (r *orders) History(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var history []model.OrderStatusHistory
+	for _, entry := range r.s.data.history {
+		if entry.OrderID == orderID {
+			history = append(history, entry)
+		}
+	}
+	return history, nil
+}
+
+func (r *orders) ListForStore(ctx context.Context, storeID uint, status model.OrderStatusType) ([]model.Order, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []model.Order
+	for _, order := range r.s.data.orders {
+		if status != "" && order.Status != status {
+			continue
+		}
+		for _, item := range r.itemsOf(order.ID) {
+			if item.Product != nil && item.Product.StoreID == storeID {
+				order.Items = append(order.Items, item)
+			}
+		}
+		if len(order.Items) > 0 {
+			list = append(list, order)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+func (r *orders) CountStoreItems(ctx context.Context, orderID, storeID uint) (own, other int, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, item := range r.s.data.items {
+		if item.OrderID != orderID {
+			continue
+		}
+		product, ok := r.s.data.products[item.ProductID]
+		switch {
+		case !ok:
+		case product.StoreID == storeID:
+			own++
+		default:
+			other++
+		}
+	}
+	return own, other, nil
+}
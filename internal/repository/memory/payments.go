@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type payments struct {
+	s *Store
+}
+
+func (r *payments) Create(ctx context.Context, payment *model.Payment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, other := range r.s.data.payments {
+		if other.Reference == payment.Reference {
+			return fmt.Errorf("%w: payment %q", ErrDuplicate, payment.Reference)
+		}
+	}
+	payment.ID = r.s.data.newID()
+	payment.CreatedAt, payment.UpdatedAt = time.Now(), time.Now()
+	if payment.Status == "" {
+		payment.Status = model.PaymentStatusPending
+	}
+	r.s.data.payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *payments) Save(ctx context.Context, payment *model.Payment) error {
+	if payment.ID == 0 {
+		return r.Create(ctx, payment)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	payment.UpdatedAt = time.Now()
+	r.s.data.payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *payments) FindPending(ctx context.Context, orderID uint, amount float64) (*model.Payment, error) {
+	return r.find(func(p model.Payment) bool {
+		return p.OrderID == orderID && p.Status == model.PaymentStatusPending && p.Amount == amount
+	})
+}
+
+func (r *payments) FindByReferenceForUser(ctx context.Context, reference string, userID uint) (*model.Payment, error) {
+	return r.find(func(p model.Payment) bool {
+		return p.Reference == reference && r.s.data.orders[p.OrderID].UserID == userID
+	})
+}
+
+func (r *payments) FindByReferenceForUpdate(ctx context.Context, reference string) (*model.Payment, error) {
+	return r.find(func(p model.Payment) bool { return p.Reference == reference })
+}
+
+// find returns the matching payment with the lowest id.
+func (r *payments) find(match func(model.Payment) bool) (*model.Payment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found *model.Payment
+	for _, payment := range r.s.data.payments {
+		if match(payment) && (found == nil || payment.ID < found.ID) {
+			payment := payment
+			found = &payment
+		}
+	}
+	if found == nil {
+		return nil, repository.ErrNotFound
+	}
+	return found, nil
+}
@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type products struct {
+	s *Store
+}
+
+// columns is the product as stored: its columns without associations.
+func columns(product model.Product) model.Product {
+	product.Category = nil
+	product.Tags = nil
+	product.Images = nil
+	product.Options = nil
+	product.Variants = nil
+	return product
+}
+
+func (r *products) FindByID(ctx context.Context, id uint) (*model.Product, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	product, ok := r.s.data.products[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &product, nil
+}
+
+func (r *products) FindByIDForUpdate(ctx context.Context, id uint) (*model.Product, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *products) FindWithDetails(ctx context.Context, id uint) (*model.Product, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	product, ok := r.s.data.products[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	r.s.data.loadDetails(&product)
+	return &product, nil
+}
+
+// loadDetails loads the category, tags, images, options and variants of the
+// product, each in display order.
+func (d *data) loadDetails(product *model.Product) {
+	if product.CategoryID != nil {
+		if category, ok := d.categories[*product.CategoryID]; ok {
+			product.Category = &category
+		}
+	}
+	product.Tags = d.tagsOf(product.ID)
+	product.Images = d.imagesOf(product.ID)
+	product.Options = d.optionsOf(product.ID)
+	product.Variants = d.variantsOf(product.ID)
+}
+
+func (r *products) ListByUser(ctx context.Context, userID uint) ([]model.Product, error) {
+	return r.list(func(p model.Product) bool { return p.UserID == userID }), nil
+}
+
+func (r *products) ListByStatus(ctx context.Context, status model.StatusType) ([]model.Product, error) {
+	list := r.list(func(p model.Product) bool { return p.Status == status })
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+// list returns the matching products in id order.
+func (r *products) list(match func(model.Product) bool) []model.Product {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []model.Product
+	for _, product := range r.s.data.products {
+		if match(product) {
+			list = append(list, product)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (r *products) Create(ctx context.Context, product *model.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	product.ID = r.s.data.newID()
+	product.CreatedAt, product.UpdatedAt = time.Now(), time.Now()
+	if product.Status == "" {
+		product.Status = model.StatusPending
+	}
+	r.s.data.products[product.ID] = columns(*product)
+	return nil
+}
+
+func (r *products) Save(ctx context.Context, product *model.Product) error {
+	if product.ID == 0 {
+		return r.Create(ctx, product)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	product.UpdatedAt = time.Now()
+	r.s.data.products[product.ID] = columns(*product)
+	return nil
+}
+
+func (r *products) DeletePending(ctx context.Context, id uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	product, ok := r.s.data.products[id]
+	if !ok || product.Status != model.StatusPending {
+		return false, nil
+	}
+	delete(r.s.data.products, id)
+	delete(r.s.data.productTags, id)
+	for imageID, image := range r.s.data.images {
+		if image.ProductID == id {
+			delete(r.s.data.images, imageID)
+		}
+	}
+	for optionID, option := range r.s.data.options {
+		if option.ProductID == id {
+			r.s.data.deleteOption(optionID)
+		}
+	}
+	for variantID, variant := range r.s.data.variants {
+		if variant.ProductID == id {
+			delete(r.s.data.variants, variantID)
+			delete(r.s.data.variantValues, variantID)
+		}
+	}
+	return true, nil
+}
+
+func (r *products) SetCategory(ctx context.Context, product *model.Product, categoryID *uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var category *model.Category
+	if categoryID != nil {
+		stored, ok := r.s.data.categories[*categoryID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		category = &stored
+		categoryID = &stored.ID
+	}
+
+	if stored, ok := r.s.data.products[product.ID]; ok {
+		stored.CategoryID = categoryID
+		r.s.data.products[product.ID] = stored
+	}
+	product.CategoryID = categoryID
+	product.Category = category
+	return nil
+}
+
+func (r *products) SetTags(ctx context.Context, product *model.Product, tags []model.Tag) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	slugs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if _, ok := r.s.data.tags[tag.Slug]; !ok {
+			tag.ID = r.s.data.newID()
+			tag.CreatedAt = time.Now()
+			r.s.data.tags[tag.Slug] = tag
+		}
+		slugs = append(slugs, tag.Slug)
+	}
+	r.s.data.productTags[product.ID] = slugs
+	product.Tags = r.s.data.tagsOf(product.ID)
+	return nil
+}
+
+// tagsOf returns the tags of the product in slug order.
+func (d *data) tagsOf(productID uint) []model.Tag {
+	tags := []model.Tag{}
+	for _, slug := range d.productTags[productID] {
+		tags = append(tags, d.tags[slug])
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Slug < tags[j].Slug })
+	return tags
+}
+
+func (r *products) LockProducts(ctx context.Context, ids []uint) ([]model.Product, error) {
+	wanted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return r.list(func(p model.Product) bool { return wanted[p.ID] }), nil
+}
+
+func (r *products) LockVariants(ctx context.Context, ids []uint) ([]model.ProductVariant, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var variants []model.ProductVariant
+	for _, id := range ids {
+		if variant, ok := r.s.data.variants[id]; ok {
+			variants = append(variants, variant)
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].ID < variants[j].ID })
+	return variants, nil
+}
+
+func (r *products) ProductIDsWithVariants(ctx context.Context, productIDs []uint) ([]uint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var ids []uint
+	for _, id := range productIDs {
+		for _, variant := range r.s.data.variants {
+			if variant.ProductID == id {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (r *products) AdjustStock(ctx context.Context, productID uint, variantID *uint, delta int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if variantID != nil {
+		if variant, ok := r.s.data.variants[*variantID]; ok {
+			variant.Stock += delta
+			r.s.data.variants[variant.ID] = variant
+		}
+		return nil
+	}
+	if product, ok := r.s.data.products[productID]; ok {
+		product.Stock += delta
+		r.s.data.products[product.ID] = product
+	}
+	return nil
+}
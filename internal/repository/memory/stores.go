@@ -0,0 +1,178 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type stores struct {
+	s *Store
+}
+
+func (r *stores) FindByID(ctx context.Context, id uint) (*model.Store, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	store, ok := r.s.data.stores[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &store, nil
+}
+
+func (r *stores) FindByIDForUpdate(ctx context.Context, id uint) (*model.Store, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *stores) FindBySlug(ctx context.Context, slug string) (*model.Store, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, store := range r.s.data.stores {
+		if store.Slug == slug {
+			return &store, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *stores) FindWithStaff(ctx context.Context, id uint) (*model.Store, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	store, ok := r.s.data.stores[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	for _, staff := range r.s.data.staff {
+		if staff.StoreID == id {
+			store.Staff = append(store.Staff, staff)
+		}
+	}
+	return &store, nil
+}
+
+func (r *stores) FirstOwnedBy(ctx context.Context, ownerID uint) (*model.Store, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var first *model.Store
+	for _, store := range r.s.data.stores {
+		if store.OwnerID == ownerID && (first == nil || store.ID < first.ID) {
+			store := store
+			first = &store
+		}
+	}
+	if first == nil {
+		return nil, repository.ErrNotFound
+	}
+	return first, nil
+}
+
+func (r *stores) IsStaff(ctx context.Context, storeID, userID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, staff := range r.s.data.staff {
+		if staff.StoreID == storeID && staff.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *stores) ListForUser(ctx context.Context, userID uint) ([]model.Store, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	member := make(map[uint]bool)
+	for _, staff := range r.s.data.staff {
+		if staff.UserID == userID {
+			member[staff.StoreID] = true
+		}
+	}
+	var list []model.Store
+	for _, store := range r.s.data.stores {
+		if store.OwnerID == userID || member[store.ID] {
+			list = append(list, store)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (r *stores) SlugTaken(ctx context.Context, slug string, exceptID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, store := range r.s.data.stores {
+		if store.Slug == slug && store.ID != exceptID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *stores) Create(ctx context.Context, store *model.Store) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.checkUnique(store); err != nil {
+		return err
+	}
+	store.ID = r.s.data.newID()
+	store.CreatedAt, store.UpdatedAt = time.Now(), time.Now()
+	stored := *store
+	stored.Staff = nil
+	r.s.data.stores[store.ID] = stored
+	return nil
+}
+
+func (r *stores) Save(ctx context.Context, store *model.Store) error {
+	if store.ID == 0 {
+		return r.Create(ctx, store)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.checkUnique(store); err != nil {
+		return err
+	}
+	store.UpdatedAt = time.Now()
+	stored := *store
+	stored.Staff = nil
+	r.s.data.stores[store.ID] = stored
+	return nil
+}
+
+// checkUnique enforces the unique slug of stores.
+func (r *stores) checkUnique(store *model.Store) error {
+	for _, other := range r.s.data.stores {
+		if other.ID != store.ID && other.Slug == store.Slug {
+			return fmt.Errorf("%w: store %q", ErrDuplicate, store.Slug)
+		}
+	}
+	return nil
+}
+
+func (r *stores) AddStaff(ctx context.Context, staff *model.StoreStaff) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, other := range r.s.data.staff {
+		if other.StoreID == staff.StoreID && other.UserID == staff.UserID {
+			return false, nil
+		}
+	}
+	staff.ID = r.s.data.newID()
+	staff.CreatedAt = time.Now()
+	r.s.data.staff = append(r.s.data.staff, *staff)
+	return true, nil
+}
+
+func (r *stores) RemoveStaff(ctx context.Context, storeID, userID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, staff := range r.s.data.staff {
+		if staff.StoreID == storeID && staff.UserID == userID {
+			r.s.data.staff = append(r.s.data.staff[:i:i], r.s.data.staff[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type tokens struct {
+	s *Store
+}
+
+func (r *tokens) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, other := range r.s.data.refreshTokens {
+		if other.TokenHash == token.TokenHash {
+			return fmt.Errorf("%w: refresh token", ErrDuplicate)
+		}
+	}
+	token.ID = r.s.data.newID()
+	token.CreatedAt = time.Now()
+	r.s.data.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (r *tokens) FindRefreshTokenForUpdate(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	return r.find(func(t model.RefreshToken) bool { return t.TokenHash == tokenHash })
+}
+
+func (r *tokens) FindUserRefreshToken(ctx context.Context, tokenHash string, userID uint) (*model.RefreshToken, error) {
+	return r.find(func(t model.RefreshToken) bool { return t.TokenHash == tokenHash && t.UserID == userID })
+}
+
+func (r *tokens) find(match func(model.RefreshToken) bool) (*model.RefreshToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, token := range r.s.data.refreshTokens {
+		if match(token) {
+			return &token, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *tokens) ReplaceRefreshToken(ctx context.Context, id, replacedBy uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if token, ok := r.s.data.refreshTokens[id]; ok {
+		now := time.Now()
+		token.RevokedAt = &now
+		token.ReplacedBy = &replacedBy
+		r.s.data.refreshTokens[id] = token
+	}
+	return nil
+}
+
+func (r *tokens) RevokeFamily(ctx context.Context, familyID string) error {
+	r.revoke(func(t model.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *tokens) RevokeUser(ctx context.Context, userID uint) error {
+	r.revoke(func(t model.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+// revoke revokes the matching refresh tokens that are not revoked yet.
+func (r *tokens) revoke(match func(model.RefreshToken) bool) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	now := time.Now()
+	for id, token := range r.s.data.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+			r.s.data.refreshTokens[id] = token
+		}
+	}
+}
+
+func (r *tokens) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.revokedTokens[jti]; !ok {
+		r.s.data.revokedTokens[jti] = model.RevokedToken{JTI: jti, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	}
+	for id, revoked := range r.s.data.revokedTokens {
+		if revoked.ExpiresAt.Before(time.Now()) {
+			delete(r.s.data.revokedTokens, id)
+		}
+	}
+	return nil
+}
+
+func (r *tokens) IsAccessTokenRevoked(ctx context.Context, jti string, userID uint, issuedAt time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.revokedTokens[jti]; ok {
+		return true, nil
+	}
+	user, ok := r.s.data.users[userID]
+	if !ok {
+		return false, nil
+	}
+	return user.PasswordChangedAt != nil && user.PasswordChangedAt.After(issuedAt) ||
+		user.TokensValidAfter != nil && user.TokensValidAfter.After(issuedAt), nil
+}
@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type users struct {
+	s *Store
+}
+
+func (r *users) FindByID(ctx context.Context, id uint) (*model.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	user, ok := r.s.data.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *users) FindByIDForUpdate(ctx context.Context, id uint) (*model.User, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *users) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, user := range r.s.data.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *users) FindByEmailForUpdate(ctx context.Context, email string) (*model.User, error) {
+	return r.FindByEmail(ctx, email)
+}
+
+func (r *users) ExistsByEmailOrUsername(ctx context.Context, email, username string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, user := range r.s.data.users {
+		if user.Email == email || user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *users) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, user := range r.s.data.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *users) LockAdmins(ctx context.Context) ([]model.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var admins []model.User
+	for _, user := range r.s.data.users {
+		if user.Role == model.RoleAdmin {
+			admins = append(admins, user)
+		}
+	}
+	sort.Slice(admins, func(i, j int) bool { return admins[i].ID < admins[j].ID })
+	return admins, nil
+}
+
+func (r *users) AdminExists(ctx context.Context) (bool, error) {
+	admins, err := r.LockAdmins(ctx)
+	return len(admins) > 0, err
+}
+
+func (r *users) Create(ctx context.Context, user *model.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.checkUnique(user); err != nil {
+		return err
+	}
+	user.ID = r.s.data.newID()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	user.UpdatedAt = time.Now()
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
+	r.s.data.users[user.ID] = *user
+	return nil
+}
+
+func (r *users) Save(ctx context.Context, user *model.User) error {
+	if user.ID == 0 {
+		return r.Create(ctx, user)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.checkUnique(user); err != nil {
+		return err
+	}
+	user.UpdatedAt = time.Now()
+	r.s.data.users[user.ID] = *user
+	return nil
+}
+
+// checkUnique enforces the unique email and username of users.
+func (r *users) checkUnique(user *model.User) error {
+	for _, other := range r.s.data.users {
+		if other.ID == user.ID {
+			continue
+		}
+		if other.Email == user.Email || other.Username == user.Username {
+			return fmt.Errorf("%w: user %q", ErrDuplicate, user.Email)
+		}
+	}
+	return nil
+}
+
+func (r *users) RecordRoleChange(ctx context.Context, change *model.RoleChange) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	change.ID = r.s.data.newID()
+	change.CreatedAt = time.Now()
+	r.s.data.roleChanges = append(r.s.data.roleChanges, *change)
+	return nil
+}
+
+func (r *users) ListRoleChanges(ctx context.Context, userID uint) ([]model.RoleChange, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var changes []model.RoleChange
+	for _, change := range r.s.data.roleChanges {
+		if change.UserID == userID {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
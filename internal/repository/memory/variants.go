@@ -0,0 +1,261 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+)
+
+type variants struct {
+	s *Store
+}
+
+func (r *variants) ListOptions(ctx context.Context, productID uint) ([]model.ProductOption, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.data.optionsOf(productID), nil
+}
+
+func (r *variants) FindOption(ctx context.Context, productID, optionID uint) (*model.ProductOption, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	option, ok := r.s.data.options[optionID]
+	if !ok || option.ProductID != productID {
+		return nil, repository.ErrNotFound
+	}
+	option.Values = r.s.data.valuesOf(optionID)
+	return &option, nil
+}
+
+func (r *variants) CreateOption(ctx context.Context, option *model.ProductOption) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	option.ID = r.s.data.newID()
+	option.CreatedAt = time.Now()
+	for i := range option.Values {
+		option.Values[i].ID = r.s.data.newID()
+		option.Values[i].OptionID = option.ID
+		r.s.data.optionValues[option.Values[i].ID] = option.Values[i]
+	}
+	stored := *option
+	stored.Values = nil
+	r.s.data.options[option.ID] = stored
+	return nil
+}
+
+func (r *variants) CreateOptionValue(ctx context.Context, value *model.ProductOptionValue) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	value.ID = r.s.data.newID()
+	r.s.data.optionValues[value.ID] = *value
+	return nil
+}
+
+func (r *variants) DeleteOption(ctx context.Context, productID, optionID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	option, ok := r.s.data.options[optionID]
+	if !ok || option.ProductID != productID {
+		return false, nil
+	}
+	r.s.data.deleteOption(optionID)
+	return true, nil
+}
+
+func (r *variants) List(ctx context.Context, productID uint) ([]model.ProductVariant, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.data.variantsOf(productID), nil
+}
+
+func (r *variants) Find(ctx context.Context, productID, variantID uint) (*model.ProductVariant, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	variant, ok := r.s.data.variants[variantID]
+	if !ok || variant.ProductID != productID {
+		return nil, repository.ErrNotFound
+	}
+	return &variant, nil
+}
+
+func (r *variants) FindForUpdate(ctx context.Context, productID, variantID uint) (*model.ProductVariant, error) {
+	return r.Find(ctx, productID, variantID)
+}
+
+func (r *variants) Values(ctx context.Context, variantID uint) ([]model.ProductOptionValue, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.data.linkedValues(variantID), nil
+}
+
+func (r *variants) SKUTaken(ctx context.Context, sku string, exceptID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, variant := range r.s.data.variants {
+		if variant.SKU == sku && variant.ID != exceptID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *variants) Create(ctx context.Context, variant *model.ProductVariant) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.checkUnique(variant); err != nil {
+		return err
+	}
+	r.s.data.createVariant(variant)
+	return nil
+}
+
+func (r *variants) Save(ctx context.Context, variant *model.ProductVariant) error {
+	if variant.ID == 0 {
+		return r.Create(ctx, variant)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.checkUnique(variant); err != nil {
+		return err
+	}
+	variant.UpdatedAt = time.Now()
+	stored := *variant
+	stored.Values = nil
+	r.s.data.variants[variant.ID] = stored
+	return nil
+}
+
+// checkUnique enforces the unique SKU of variants.
+func (r *variants) checkUnique(variant *model.ProductVariant) error {
+	for _, other := range r.s.data.variants {
+		if other.ID != variant.ID && other.SKU == variant.SKU {
+			return fmt.Errorf("%w: variant %q", ErrDuplicate, variant.SKU)
+		}
+	}
+	return nil
+}
+
+func (r *variants) Ordered(ctx context.Context, variantID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, item := range r.s.data.items {
+		if item.VariantID != nil && *item.VariantID == variantID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *variants) Delete(ctx context.Context, variantID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.data.variants, variantID)
+	delete(r.s.data.variantValues, variantID)
+	for id, item := range r.s.data.cartItems {
+		if item.VariantID != nil && *item.VariantID == variantID {
+			delete(r.s.data.cartItems, id)
+		}
+	}
+	return nil
+}
+
+// createVariant stores the variant and links it to its Values.
+func (d *data) createVariant(variant *model.ProductVariant) {
+	variant.ID = d.newID()
+	variant.CreatedAt, variant.UpdatedAt = time.Now(), time.Now()
+	stored := *variant
+	stored.Values = nil
+	d.variants[variant.ID] = stored
+	ids := make([]uint, 0, len(variant.Values))
+	for _, value := range variant.Values {
+		ids = append(ids, value.ID)
+	}
+	d.variantValues[variant.ID] = ids
+}
+
+// optionsOf returns the options of the product with their values, in display
+// order.
+func (d *data) optionsOf(productID uint) []model.ProductOption {
+	var options []model.ProductOption
+	for _, option := range d.options {
+		if option.ProductID == productID {
+			option.Values = d.valuesOf(option.ID)
+			options = append(options, option)
+		}
+	}
+	sort.Slice(options, func(i, j int) bool {
+		if options[i].Position != options[j].Position {
+			return options[i].Position < options[j].Position
+		}
+		return options[i].ID < options[j].ID
+	})
+	return options
+}
+
+// valuesOf returns the values of the option in display order.
+func (d *data) valuesOf(optionID uint) []model.ProductOptionValue {
+	var values []model.ProductOptionValue
+	for _, value := range d.optionValues {
+		if value.OptionID == optionID {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Position != values[j].Position {
+			return values[i].Position < values[j].Position
+		}
+		return values[i].ID < values[j].ID
+	})
+	return values
+}
+
+// variantsOf returns the variants of the product with their option values,
+// in id order.
+func (d *data) variantsOf(productID uint) []model.ProductVariant {
+	var list []model.ProductVariant
+	for _, variant := range d.variants {
+		if variant.ProductID == productID {
+			variant.Values = d.linkedValues(variant.ID)
+			list = append(list, variant)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// linkedValues returns the option values of the variant in id order.
+func (d *data) linkedValues(variantID uint) []model.ProductOptionValue {
+	var values []model.ProductOptionValue
+	for _, id := range d.variantValues[variantID] {
+		if value, ok := d.optionValues[id]; ok {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].ID < values[j].ID })
+	return values
+}
+
+// deleteOption deletes the option and its values, and unlinks the values
+// from variants.
+func (d *data) deleteOption(optionID uint) {
+	delete(d.options, optionID)
+	for id, value := range d.optionValues {
+		if value.OptionID != optionID {
+			continue
+		}
+		delete(d.optionValues, id)
+		for variantID, linked := range d.variantValues {
+			kept := linked[:0:0]
+			for _, valueID := range linked {
+				if valueID != id {
+					kept = append(kept, valueID)
+				}
+			}
+			d.variantValues[variantID] = kept
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"instashop/internal/model"
+)
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+// preloadOrderItems loads the order lines with their products and variants.
+func preloadOrderItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items.Product").Preload("Items.Variant.Values")
+}
+
+func (r *orderRepository) Create(ctx context.Context, order *model.Order) error {
+	return r.db.WithContext(ctx).Create(order).Error
+}
+
+func (r *orderRepository) FindByID(ctx context.Context, id uint) (*model.Order, error) {
+	return r.find(r.db.WithContext(ctx), id)
+}
+
+func (r *orderRepository) FindByIDForUpdate(ctx context.Context, id uint) (*model.Order, error) {
+	return r.find(r.db.WithContext(ctx).Clauses(forUpdate), id)
+}
+
+func (r *orderRepository) FindWithItems(ctx context.Context, id uint) (*model.Order, error) {
+	return r.find(preloadOrderItems(r.db.WithContext(ctx)), id)
+}
+
+func (r *orderRepository) find(query *gorm.DB, id uint) (*model.Order, error) {
+	var order model.Order
+	if err := query.First(&order, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &order, nil
+}
+
+func (r *orderRepository) List(ctx context.Context, filter OrderFilter) ([]model.Order, error) {
+	query := preloadOrderItems(r.db.WithContext(ctx))
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var orders []model.Order
+	if err := query.Order("created_at DESC, id DESC").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *orderRepository) ListItems(ctx context.Context, orderID uint) ([]model.OrderItem, error) {
+	var items []model.OrderItem
+	if err := r.db.WithContext(ctx).
+		Where("order_id = ?", orderID).
+		Order("product_id, variant_id").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *orderRepository) Save(ctx context.Context, order *model.Order) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(order).Error
+}
+
+func (r *orderRepository) AddHistory(ctx context.Context, entry *model.OrderStatusHistory) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+func (r *orderRepository) History(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error) {
+	var history []model.OrderStatusHistory
+	if err := r.db.WithContext(ctx).
+		Where("order_id = ?", orderID).
+		Order("created_at, id").
+		Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// storeItems matches the order items of the store's products.
+const storeItems = "product_id IN (SELECT id FROM products WHERE store_id = ?)"
+
+func (r *orderRepository) ListForStore(ctx context.Context, storeID uint, status model.OrderStatusType) ([]model.Order, error) {
+	query := r.db.WithContext(ctx).
+		Preload("Items", storeItems, storeID).
+		Preload("Items.Product").
+		Preload("Items.Variant.Values").
+		Where("id IN (SELECT order_id FROM order_items WHERE "+storeItems+")", storeID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []model.Order
+	if err := query.Order("created_at DESC, id DESC").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *orderRepository) CountStoreItems(ctx context.Context, orderID, storeID uint) (own, other int, err error) {
+	var counts struct {
+		Own   int
+		Other int
+	}
+	err = r.db.WithContext(ctx).Table("order_items").
+		Select("COUNT(*) FILTER (WHERE products.store_id = ?) AS own, COUNT(*) FILTER (WHERE products.store_id <> ?) AS other", storeID, storeID).
+		Joins("JOIN products ON products.id = order_items.product_id").
+		Where("order_items.order_id = ?", orderID).
+		Scan(&counts).Error
+	return counts.Own, counts.Other, err
+}
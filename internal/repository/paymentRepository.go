@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/model"
+)
+
+type paymentRepository struct {
+	db *gorm.DB
+}
+
+func (r *paymentRepository) Create(ctx context.Context, payment *model.Payment) error {
+	return r.db.WithContext(ctx).Create(payment).Error
+}
+
+func (r *paymentRepository) Save(ctx context.Context, payment *model.Payment) error {
+	return r.db.WithContext(ctx).Save(payment).Error
+}
+
+func (r *paymentRepository) FindPending(ctx context.Context, orderID uint, amount float64) (*model.Payment, error) {
+	return r.find(r.db.WithContext(ctx).
+		Where("order_id = ? AND status = ? AND amount = ?", orderID, model.PaymentStatusPending, amount))
+}
+
+func (r *paymentRepository) FindByReferenceForUser(ctx context.Context, reference string, userID uint) (*model.Payment, error) {
+	return r.find(r.db.WithContext(ctx).
+		Joins("JOIN orders ON orders.id = payments.order_id").
+		Where("payments.reference = ? AND orders.user_id = ?", reference, userID))
+}
+
+func (r *paymentRepository) FindByReferenceForUpdate(ctx context.Context, reference string) (*model.Payment, error) {
+	return r.find(r.db.WithContext(ctx).Clauses(forUpdate).Where("reference = ?", reference))
+}
+
+func (r *paymentRepository) find(query *gorm.DB) (*model.Payment, error) {
+	var payment model.Payment
+	if err := query.First(&payment).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &payment, nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"instashop/internal/model"
+)
+
+type productRepository struct {
+	db *gorm.DB
+}
+
+// PreloadProductDetails loads the category, tags, images, options and
+// variants shown with a product, each in display order.
+func PreloadProductDetails(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Category").
+		Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("slug") }).
+		Preload("Images", OrderImages).
+		Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Options.Values", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Variants.Values")
+}
+
+// OrderImages is the Preload condition that loads images in display order
+func OrderImages(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+func (r *productRepository) FindByID(ctx context.Context, id uint) (*model.Product, error) {
+	return r.find(r.db.WithContext(ctx), id)
+}
+
+func (r *productRepository) FindByIDForUpdate(ctx context.Context, id uint) (*model.Product, error) {
+	return r.find(r.db.WithContext(ctx).Clauses(forUpdate), id)
+}
+
+func (r *productRepository) find(query *gorm.DB, id uint) (*model.Product, error) {
+	var product model.Product
+	if err := query.First(&product, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &product, nil
+}
+
+func (r *productRepository) FindWithDetails(ctx context.Context, id uint) (*model.Product, error) {
+	var product model.Product
+	if err := PreloadProductDetails(r.db.WithContext(ctx)).First(&product, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &product, nil
+}
+
+func (r *productRepository) ListByUser(ctx context.Context, userID uint) ([]model.Product, error) {
+	var products []model.Product
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *productRepository) ListByStatus(ctx context.Context, status model.StatusType) ([]model.Product, error) {
+	var products []model.Product
+	if err := r.db.WithContext(ctx).
+		Where("status = ?", status).
+		Order("created_at, id").
+		Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *productRepository) Create(ctx context.Context, product *model.Product) error {
+	return r.db.WithContext(ctx).Create(product).Error
+}
+
+func (r *productRepository) Save(ctx context.Context, product *model.Product) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(product).Error
+}
+
+func (r *productRepository) DeletePending(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND status = ?", id, model.StatusPending).
+		Delete(&model.Product{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *productRepository) SetCategory(ctx context.Context, product *model.Product, categoryID *uint) error {
+	db := r.db.WithContext(ctx)
+	var category *model.Category
+	if categoryID != nil {
+		category = &model.Category{}
+		if err := db.First(category, *categoryID).Error; err != nil {
+			return notFound(err)
+		}
+		categoryID = &category.ID
+	}
+	if err := db.Model(product).Update("category_id", categoryID).Error; err != nil {
+		return err
+	}
+	product.CategoryID = categoryID
+	product.Category = category
+	return nil
+}
+
+func (r *productRepository) SetTags(ctx context.Context, product *model.Product, tags []model.Tag) error {
+	db := r.db.WithContext(ctx)
+	stored := []model.Tag{}
+	if len(tags) > 0 {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "slug"}},
+			DoNothing: true,
+		}).Create(&tags).Error; err != nil {
+			return err
+		}
+
+		// Existing tags were skipped above, so load all of them by slug
+		slugs := make([]string, len(tags))
+		for i, tag := range tags {
+			slugs[i] = tag.Slug
+		}
+		if err := db.Where("slug IN ?", slugs).Order("slug").Find(&stored).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := db.Model(product).Association("Tags").Replace(stored); err != nil {
+		return err
+	}
+	product.Tags = stored
+	return nil
+}
+
+func (r *productRepository) LockProducts(ctx context.Context, ids []uint) ([]model.Product, error) {
+	var products []model.Product
+	if err := r.db.WithContext(ctx).Clauses(forUpdate).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *productRepository) LockVariants(ctx context.Context, ids []uint) ([]model.ProductVariant, error) {
+	var variants []model.ProductVariant
+	if err := r.db.WithContext(ctx).Clauses(forUpdate).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (r *productRepository) ProductIDsWithVariants(ctx context.Context, productIDs []uint) ([]uint, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).Model(&model.ProductVariant{}).
+		Distinct("product_id").
+		Where("product_id IN ?", productIDs).
+		Pluck("product_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *productRepository) AdjustStock(ctx context.Context, productID uint, variantID *uint, delta int) error {
+	db := r.db.WithContext(ctx)
+	if variantID != nil {
+		return db.Model(&model.ProductVariant{}).
+			Where("id = ?", *variantID).
+			UpdateColumn("stock", gorm.Expr("stock + ?", delta)).Error
+	}
+	return db.Model(&model.Product{}).
+		Where("id = ?", productID).
+		UpdateColumn("stock", gorm.Expr("stock + ?", delta)).Error
+}
@@ -0,0 +1,391 @@
+// Package repository is the storage the services depend on. Services use the
+// interfaces here rather than *gorm.DB, so their rules can be tested against
+// the in-memory implementation in repository/memory as well as against
+// Postgres through the GORM implementation.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+)
+
+// ErrNotFound is returned when no record matches a lookup.
+var ErrNotFound = errors.New("record not found")
+
+// UserRepository stores user accounts.
+type UserRepository interface {
+	// FindByID returns the user with the id, or ErrNotFound.
+	FindByID(ctx context.Context, id uint) (*model.User, error)
+	// FindByEmail returns the user with the email, or ErrNotFound.
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	// FindByEmailForUpdate is FindByEmail holding a lock on the user until
+	// the unit of work ends.
+	FindByEmailForUpdate(ctx context.Context, email string) (*model.User, error)
+	// ExistsByEmailOrUsername reports whether a user has the email or the
+	// username.
+	ExistsByEmailOrUsername(ctx context.Context, email, username string) (bool, error)
+	Create(ctx context.Context, user *model.User) error
+	// Save writes every column of the user.
+	Save(ctx context.Context, user *model.User) error
+	// FindByIDForUpdate is FindByID holding a lock on the user until the unit
+	// of work ends.
+	FindByIDForUpdate(ctx context.Context, id uint) (*model.User, error)
+	// ExistsByUsername reports whether a user has the username.
+	ExistsByUsername(ctx context.Context, username string) (bool, error)
+	// LockAdmins returns the admins in id order, holding a lock on them until
+	// the unit of work ends.
+	LockAdmins(ctx context.Context) ([]model.User, error)
+	// AdminExists reports whether any user is an admin.
+	AdminExists(ctx context.Context) (bool, error)
+	// RecordRoleChange adds an entry to the role change audit log.
+	RecordRoleChange(ctx context.Context, change *model.RoleChange) error
+	// ListRoleChanges returns the role changes of the user, oldest first.
+	ListRoleChanges(ctx context.Context, userID uint) ([]model.RoleChange, error)
+}
+
+// TokenRepository stores refresh tokens and the denylist of revoked access
+// tokens.
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	// FindRefreshTokenForUpdate returns the refresh token with the hash,
+	// holding a lock on it until the unit of work ends, or ErrNotFound.
+	FindRefreshTokenForUpdate(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	// FindUserRefreshToken returns the user's refresh token with the hash, or
+	// ErrNotFound.
+	FindUserRefreshToken(ctx context.Context, tokenHash string, userID uint) (*model.RefreshToken, error)
+	// ReplaceRefreshToken revokes a refresh token that was rotated and
+	// records the token that replaced it.
+	ReplaceRefreshToken(ctx context.Context, id, replacedBy uint) error
+	// RevokeFamily revokes every unrevoked token in a refresh token family.
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeUser revokes every unrevoked refresh token of the user.
+	RevokeUser(ctx context.Context, userID uint) error
+	// RevokeAccessToken denylists an access token until it expires, and
+	// drops denylisted tokens that have expired.
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsAccessTokenRevoked reports whether the access token is denylisted,
+	// or the user's password changed or tokens were invalidated after it was
+	// issued.
+	IsAccessTokenRevoked(ctx context.Context, jti string, userID uint, issuedAt time.Time) (bool, error)
+}
+
+// StoreRepository looks up stores and their staff.
+type StoreRepository interface {
+	// FindByID returns the store with the id, or ErrNotFound.
+	FindByID(ctx context.Context, id uint) (*model.Store, error)
+	// FindByIDForUpdate is FindByID holding a lock on the store until the
+	// unit of work ends.
+	FindByIDForUpdate(ctx context.Context, id uint) (*model.Store, error)
+	// FindBySlug returns the store with the slug, or ErrNotFound.
+	FindBySlug(ctx context.Context, slug string) (*model.Store, error)
+	// FindWithStaff returns the store with its staff, or ErrNotFound.
+	FindWithStaff(ctx context.Context, id uint) (*model.Store, error)
+	// FirstOwnedBy returns the oldest store of the owner, or ErrNotFound.
+	FirstOwnedBy(ctx context.Context, ownerID uint) (*model.Store, error)
+	// ListForUser returns the stores the user owns or works at, in id order.
+	ListForUser(ctx context.Context, userID uint) ([]model.Store, error)
+	// SlugTaken reports whether a store other than exceptID uses the slug.
+	SlugTaken(ctx context.Context, slug string, exceptID uint) (bool, error)
+	Create(ctx context.Context, store *model.Store) error
+	// Save writes the columns of the store, not its staff.
+	Save(ctx context.Context, store *model.Store) error
+	// IsStaff reports whether the user is on the staff of the store.
+	IsStaff(ctx context.Context, storeID, userID uint) (bool, error)
+	// AddStaff puts a user on the staff of a store and reports whether they
+	// were not on it already.
+	AddStaff(ctx context.Context, staff *model.StoreStaff) (bool, error)
+	// RemoveStaff takes a user off the staff of a store and reports whether
+	// they were on it.
+	RemoveStaff(ctx context.Context, storeID, userID uint) (bool, error)
+}
+
+// ProductRepository stores products, their taxonomy and their stock.
+type ProductRepository interface {
+	// FindByID returns the product with the id, or ErrNotFound.
+	FindByID(ctx context.Context, id uint) (*model.Product, error)
+	// FindByIDForUpdate is FindByID holding a lock on the product until the
+	// unit of work ends.
+	FindByIDForUpdate(ctx context.Context, id uint) (*model.Product, error)
+	// FindWithDetails returns a product with its category, tags, images,
+	// options and variants, or ErrNotFound.
+	FindWithDetails(ctx context.Context, id uint) (*model.Product, error)
+	// ListByUser returns the products the user created.
+	ListByUser(ctx context.Context, userID uint) ([]model.Product, error)
+	// ListByStatus returns the products in the status, oldest first.
+	ListByStatus(ctx context.Context, status model.StatusType) ([]model.Product, error)
+	Create(ctx context.Context, product *model.Product) error
+	// Save writes the columns of the product, not its associations.
+	Save(ctx context.Context, product *model.Product) error
+	// DeletePending deletes the product if it is pending and reports
+	// whether it was.
+	DeletePending(ctx context.Context, id uint) (bool, error)
+	// SetCategory moves the product to the category, or out of its category
+	// when categoryID is nil. It returns ErrNotFound for an unknown category.
+	SetCategory(ctx context.Context, product *model.Product, categoryID *uint) error
+	// SetTags replaces the tags of the product, creating tags whose slug is
+	// new. product.Tags is set to the stored tags in slug order.
+	SetTags(ctx context.Context, product *model.Product, tags []model.Tag) error
+	// LockProducts returns the products with the ids, locked in id order.
+	// Missing ids are left out.
+	LockProducts(ctx context.Context, ids []uint) ([]model.Product, error)
+	// LockVariants returns the variants with the ids, locked in id order.
+	// Missing ids are left out.
+	LockVariants(ctx context.Context, ids []uint) ([]model.ProductVariant, error)
+	// ProductIDsWithVariants returns which of the products are sold in
+	// variants.
+	ProductIDsWithVariants(ctx context.Context, productIDs []uint) ([]uint, error)
+	// AdjustStock changes the stock of the variant, or of the product when
+	// variantID is nil, by delta.
+	AdjustStock(ctx context.Context, productID uint, variantID *uint, delta int) error
+}
+
+// Catalog sort orders.
+const (
+	CatalogSortNewest    = "newest"
+	CatalogSortPriceAsc  = "price_asc"
+	CatalogSortPriceDesc = "price_desc"
+)
+
+// CatalogFilter selects a page of approved products for ListProducts. Zero
+// values are ignored.
+type CatalogFilter struct {
+	StoreID  uint
+	Category string // slug; matches the category and its subcategories
+	Tag      string // slug
+	Search   string
+	MinPrice *float64
+	MaxPrice *float64
+	// Sort is one of the catalog sort orders. The page starts after the
+	// product After when it is set.
+	Sort  string
+	After *CatalogPosition
+	Limit int
+}
+
+// CatalogPosition is where a product is in the catalog order: its sort key,
+// CreatedAt or Price, and its ID to break ties.
+type CatalogPosition struct {
+	CreatedAt time.Time
+	Price     float64
+	ID        uint
+}
+
+// CatalogRepository reads the public catalog.
+type CatalogRepository interface {
+	// ListProducts returns the approved products matching the filter with
+	// their details, in the order of filter.Sort.
+	ListProducts(ctx context.Context, filter CatalogFilter) ([]model.Product, error)
+}
+
+// CategoryRepository stores the category tree.
+type CategoryRepository interface {
+	// List returns every category ordered by name.
+	List(ctx context.Context) ([]model.Category, error)
+	// FindByID returns the category with the id, or ErrNotFound.
+	FindByID(ctx context.Context, id uint) (*model.Category, error)
+	// SlugTaken reports whether a category other than exceptID uses the
+	// slug.
+	SlugTaken(ctx context.Context, slug string, exceptID uint) (bool, error)
+	Create(ctx context.Context, category *model.Category) error
+	// Save writes the columns of the category, not its children.
+	Save(ctx context.Context, category *model.Category) error
+	// HasChildren reports whether the category has subcategories.
+	HasChildren(ctx context.Context, id uint) (bool, error)
+	// Delete deletes the category, leaving its products uncategorized, and
+	// reports whether it existed.
+	Delete(ctx context.Context, id uint) (bool, error)
+	// Ancestors returns the ids of the category and its ancestors, nearest
+	// first. It returns none for an unknown category.
+	Ancestors(ctx context.Context, id uint) ([]uint, error)
+}
+
+// ImageRepository stores product images. The image files themselves are
+// kept in blob storage.
+type ImageRepository interface {
+	// List returns the images of the product in display order.
+	List(ctx context.Context, productID uint) ([]model.ProductImage, error)
+	// Find returns an image of the product, or ErrNotFound.
+	Find(ctx context.Context, productID, imageID uint) (*model.ProductImage, error)
+	Create(ctx context.Context, images []model.ProductImage) error
+	// SetPosition moves an image in the display order of its product.
+	SetPosition(ctx context.Context, imageID uint, position int) error
+	// SetPrimary makes the image the only primary image of its product.
+	SetPrimary(ctx context.Context, image *model.ProductImage) error
+	Delete(ctx context.Context, imageID uint) error
+}
+
+// VariantRepository stores the options of products and the variants that
+// combine them.
+type VariantRepository interface {
+	// ListOptions returns the options of the product with their values, in
+	// display order.
+	ListOptions(ctx context.Context, productID uint) ([]model.ProductOption, error)
+	// FindOption returns an option of the product with its values, or
+	// ErrNotFound.
+	FindOption(ctx context.Context, productID, optionID uint) (*model.ProductOption, error)
+	// CreateOption saves the option together with its values.
+	CreateOption(ctx context.Context, option *model.ProductOption) error
+	// CreateOptionValue adds a value to an existing option.
+	CreateOptionValue(ctx context.Context, value *model.ProductOptionValue) error
+	// DeleteOption deletes an option of the product with its values and
+	// reports whether it existed.
+	DeleteOption(ctx context.Context, productID, optionID uint) (bool, error)
+	// List returns the variants of the product with their option values, in
+	// id order.
+	List(ctx context.Context, productID uint) ([]model.ProductVariant, error)
+	// Find returns a variant of the product without its option values, or
+	// ErrNotFound.
+	Find(ctx context.Context, productID, variantID uint) (*model.ProductVariant, error)
+	// FindForUpdate is Find holding a lock on the variant until the unit of
+	// work ends.
+	FindForUpdate(ctx context.Context, productID, variantID uint) (*model.ProductVariant, error)
+	// Values returns the option values of the variant.
+	Values(ctx context.Context, variantID uint) ([]model.ProductOptionValue, error)
+	// SKUTaken reports whether a variant other than exceptID uses the SKU.
+	SKUTaken(ctx context.Context, sku string, exceptID uint) (bool, error)
+	// Create saves the variant and links it to its option values, which
+	// must already exist.
+	Create(ctx context.Context, variant *model.ProductVariant) error
+	// Save writes the columns of the variant, not its option values.
+	Save(ctx context.Context, variant *model.ProductVariant) error
+	// Ordered reports whether any order has an item of the variant.
+	Ordered(ctx context.Context, variantID uint) (bool, error)
+	Delete(ctx context.Context, variantID uint) error
+}
+
+// OrderFilter narrows the orders returned by OrderRepository.List. Zero
+// values are ignored.
+type OrderFilter struct {
+	Status model.OrderStatusType
+	UserID uint
+	From   time.Time // inclusive
+	To     time.Time // exclusive
+}
+
+// OrderRepository stores orders and their status history.
+type OrderRepository interface {
+	// Create saves the order together with its items.
+	Create(ctx context.Context, order *model.Order) error
+	// FindByID returns the order with the id, without its items, or
+	// ErrNotFound.
+	FindByID(ctx context.Context, id uint) (*model.Order, error)
+	// FindByIDForUpdate is FindByID holding a lock on the order until the
+	// unit of work ends.
+	FindByIDForUpdate(ctx context.Context, id uint) (*model.Order, error)
+	// FindWithItems returns the order with its items, their products and
+	// their variants, or ErrNotFound.
+	FindWithItems(ctx context.Context, id uint) (*model.Order, error)
+	// List returns the orders matching the filter with their items, newest
+	// first.
+	List(ctx context.Context, filter OrderFilter) ([]model.Order, error)
+	// ListItems returns the items of the order by product and variant.
+	ListItems(ctx context.Context, orderID uint) ([]model.OrderItem, error)
+	// Save writes the columns of the order, not its items.
+	Save(ctx context.Context, order *model.Order) error
+	// AddHistory records a status change of an order.
+	AddHistory(ctx context.Context, entry *model.OrderStatusHistory) error
+	// History returns the status changes of the order, oldest first.
+	History(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error)
+	// ListForStore returns the orders with items of the store's products,
+	// newest first, each with only those items. An empty status matches
+	// every order.
+	ListForStore(ctx context.Context, storeID uint, status model.OrderStatusType) ([]model.Order, error)
+	// CountStoreItems returns how many items of the order are products of
+	// the store and how many are products of other stores.
+	CountStoreItems(ctx context.Context, orderID, storeID uint) (own, other int, err error)
+}
+
+// PaymentRepository stores payments of orders.
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *model.Payment) error
+	// Save writes every column of the payment.
+	Save(ctx context.Context, payment *model.Payment) error
+	// FindPending returns the pending payment of the order for the amount,
+	// or ErrNotFound.
+	FindPending(ctx context.Context, orderID uint, amount float64) (*model.Payment, error)
+	// FindByReferenceForUser returns the payment with the reference if it is
+	// for one of the user's orders, or ErrNotFound.
+	FindByReferenceForUser(ctx context.Context, reference string, userID uint) (*model.Payment, error)
+	// FindByReferenceForUpdate returns the payment with the reference,
+	// holding a lock on it until the unit of work ends, or ErrNotFound.
+	FindByReferenceForUpdate(ctx context.Context, reference string) (*model.Payment, error)
+}
+
+// CartRepository stores shopping carts for checkout.
+type CartRepository interface {
+	// FindByUserForUpdate returns the user's cart holding a lock on it until
+	// the unit of work ends, or ErrNotFound.
+	FindByUserForUpdate(ctx context.Context, userID uint) (*model.Cart, error)
+	// ListItems returns the items of the cart in the order they were added.
+	ListItems(ctx context.Context, cartID uint) ([]model.CartItem, error)
+	// SetItemPrice changes the price the item was added at.
+	SetItemPrice(ctx context.Context, itemID uint, price float64) error
+	// Clear removes every item from the cart.
+	Clear(ctx context.Context, cartID uint) error
+	// FindWithItems returns the user's cart with its items in the order they
+	// were added, their products and their variants, or ErrNotFound.
+	FindWithItems(ctx context.Context, userID uint) (*model.Cart, error)
+	// FindOrCreate returns the user's cart, creating it if there is none.
+	FindOrCreate(ctx context.Context, userID uint) (*model.Cart, error)
+	// AddItem puts the item in its cart. If the cart has a line for the same
+	// product, or the same variant, the quantity is added to that line and
+	// its price set to the item's.
+	AddItem(ctx context.Context, item *model.CartItem) error
+	// UpdateItem sets the quantity and price of the line in the user's cart
+	// for the product, or for one of its variants when variantID is not 0,
+	// and reports whether there was one.
+	UpdateItem(ctx context.Context, userID, productID, variantID uint, quantity int, price float64) (bool, error)
+	// RemoveItem deletes that line and reports whether there was one.
+	RemoveItem(ctx context.Context, userID, productID, variantID uint) (bool, error)
+}
+
+// InviteRepository stores admin invites.
+type InviteRepository interface {
+	Create(ctx context.Context, invite *model.AdminInvite) error
+	// FindByTokenHashForUpdate returns the invite with the token hash,
+	// holding a lock on it until the unit of work ends, or ErrNotFound.
+	FindByTokenHashForUpdate(ctx context.Context, tokenHash string) (*model.AdminInvite, error)
+	// ExpireBootstrapInvites expires the bootstrap invites that are neither
+	// accepted nor expired.
+	ExpireBootstrapInvites(ctx context.Context) error
+	// Save writes every column of the invite.
+	Save(ctx context.Context, invite *model.AdminInvite) error
+}
+
+// Locker serializes units of work that check and then change state no row
+// lock covers, such as whether any admin exists.
+type Locker interface {
+	// Lock waits for and holds the named lock until the unit of work ends.
+	Lock(ctx context.Context, name string) error
+}
+
+// Repositories is the set of repositories a service works with. Inside a
+// unit of work they all read and write in its transaction, and Emails queues
+// email that is only sent if the unit of work commits.
+type Repositories struct {
+	Users      UserRepository
+	Tokens     TokenRepository
+	Invites    InviteRepository
+	Stores     StoreRepository
+	Products   ProductRepository
+	Catalog    CatalogRepository
+	Categories CategoryRepository
+	Images     ImageRepository
+	Variants   VariantRepository
+	Orders     OrderRepository
+	Payments   PaymentRepository
+	Carts      CartRepository
+	Locks      Locker
+	Emails     mailer.Mailer
+}
+
+// UnitOfWork runs changes that must be made together.
+type UnitOfWork interface {
+	// Do calls fn with repositories bound to a new transaction. The
+	// transaction commits if fn returns nil and rolls back otherwise.
+	Do(ctx context.Context, fn func(repos Repositories) error) error
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"instashop/internal/model"
+)
+
+type storeRepository struct {
+	db *gorm.DB
+}
+
+func (r *storeRepository) FindByID(ctx context.Context, id uint) (*model.Store, error) {
+	return r.find(r.db.WithContext(ctx), id)
+}
+
+func (r *storeRepository) FindByIDForUpdate(ctx context.Context, id uint) (*model.Store, error) {
+	return r.find(r.db.WithContext(ctx).Clauses(forUpdate), id)
+}
+
+func (r *storeRepository) FindWithStaff(ctx context.Context, id uint) (*model.Store, error) {
+	return r.find(r.db.WithContext(ctx).Preload("Staff"), id)
+}
+
+func (r *storeRepository) find(query *gorm.DB, id uint) (*model.Store, error) {
+	var store model.Store
+	if err := query.First(&store, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &store, nil
+}
+
+func (r *storeRepository) FindBySlug(ctx context.Context, slug string) (*model.Store, error) {
+	var store model.Store
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&store).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &store, nil
+}
+
+func (r *storeRepository) FirstOwnedBy(ctx context.Context, ownerID uint) (*model.Store, error) {
+	var store model.Store
+	if err := r.db.WithContext(ctx).Where("owner_id = ?", ownerID).Order("id").First(&store).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &store, nil
+}
+
+func (r *storeRepository) ListForUser(ctx context.Context, userID uint) ([]model.Store, error) {
+	var stores []model.Store
+	if err := r.db.WithContext(ctx).
+		Where("owner_id = ? OR id IN (SELECT store_id FROM store_staff WHERE user_id = ?)", userID, userID).
+		Order("id").
+		Find(&stores).Error; err != nil {
+		return nil, err
+	}
+	return stores, nil
+}
+
+func (r *storeRepository) SlugTaken(ctx context.Context, slug string, exceptID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.Store{}).
+		Where("slug = ? AND id <> ?", slug, exceptID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *storeRepository) Create(ctx context.Context, store *model.Store) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(store).Error
+}
+
+func (r *storeRepository) Save(ctx context.Context, store *model.Store) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(store).Error
+}
+
+func (r *storeRepository) IsStaff(ctx context.Context, storeID, userID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.StoreStaff{}).
+		Where("store_id = ? AND user_id = ?", storeID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *storeRepository) AddStaff(ctx context.Context, staff *model.StoreStaff) (bool, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(staff)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *storeRepository) RemoveStaff(ctx context.Context, storeID, userID uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("store_id = ? AND user_id = ?", storeID, userID).
+		Delete(&model.StoreStaff{})
+	return result.RowsAffected > 0, result.Error
+}
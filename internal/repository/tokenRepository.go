@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"instashop/internal/model"
+)
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *tokenRepository) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *tokenRepository) FindRefreshTokenForUpdate(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := r.db.WithContext(ctx).Clauses(forUpdate).
+		Where("token_hash = ?", tokenHash).
+		First(&token).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (r *tokenRepository) FindUserRefreshToken(ctx context.Context, tokenHash string, userID uint) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := r.db.WithContext(ctx).
+		Where("token_hash = ? AND user_id = ?", tokenHash, userID).
+		First(&token).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (r *tokenRepository) ReplaceRefreshToken(ctx context.Context, id, replacedBy uint) error {
+	return r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"revoked_at":  time.Now(),
+			"replaced_by": replacedBy,
+		}).Error
+}
+
+func (r *tokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *tokenRepository) RevokeUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *tokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	db := r.db.WithContext(ctx)
+	revoked := model.RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+		return err
+	}
+	return db.Where("expires_at < ?", time.Now()).Delete(&model.RevokedToken{}).Error
+}
+
+func (r *tokenRepository) IsAccessTokenRevoked(ctx context.Context, jti string, userID uint, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := r.db.WithContext(ctx).Raw(
+		`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)
+			OR EXISTS (SELECT 1 FROM users WHERE id = ? AND (password_changed_at > ? OR tokens_valid_after > ?))`,
+		jti, userID, issuedAt, issuedAt,
+	).Scan(&revoked).Error
+	return revoked, err
+}
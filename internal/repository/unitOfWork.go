@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"instashop/internal/mailer"
+)
+
+// New returns the GORM repositories over db. emails may be nil for services
+// that send no email; otherwise Emails queues through db, so with a
+// transaction the email is only sent if it commits.
+func New(db *gorm.DB, emails mailer.Mailer) Repositories {
+	repos := Repositories{
+		Users:      &userRepository{db: db},
+		Tokens:     &tokenRepository{db: db},
+		Invites:    &inviteRepository{db: db},
+		Stores:     &storeRepository{db: db},
+		Products:   &productRepository{db: db},
+		Catalog:    &catalogRepository{db: db},
+		Categories: &categoryRepository{db: db},
+		Images:     &imageRepository{db: db},
+		Variants:   &variantRepository{db: db},
+		Orders:     &orderRepository{db: db},
+		Payments:   &paymentRepository{db: db},
+		Carts:      &cartRepository{db: db},
+		Locks:      &advisoryLocker{db: db},
+	}
+	if emails != nil {
+		repos.Emails = emails.WithTx(db)
+	}
+	return repos
+}
+
+type gormUnitOfWork struct {
+	db     *gorm.DB
+	emails mailer.Mailer
+}
+
+// NewUnitOfWork returns a UnitOfWork running each unit in a database
+// transaction on db.
+func NewUnitOfWork(db *gorm.DB, emails mailer.Mailer) UnitOfWork {
+	return &gormUnitOfWork{db: db, emails: emails}
+}
+
+func (u *gormUnitOfWork) Do(ctx context.Context, fn func(repos Repositories) error) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx, u.emails))
+	})
+}
+
+// forUpdate locks the rows a query reads until the transaction ends.
+var forUpdate = clause.Locking{Strength: "UPDATE"}
+
+// notFound turns GORM's not-found error into ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
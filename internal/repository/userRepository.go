@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/model"
+)
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func (r *userRepository) FindByID(ctx context.Context, id uint) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByIDForUpdate(ctx context.Context, id uint) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).Clauses(forUpdate).First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.findByEmail(r.db.WithContext(ctx), email)
+}
+
+func (r *userRepository) FindByEmailForUpdate(ctx context.Context, email string) (*model.User, error) {
+	return r.findByEmail(r.db.WithContext(ctx).Clauses(forUpdate), email)
+}
+
+func (r *userRepository) findByEmail(query *gorm.DB, email string) (*model.User, error) {
+	var user model.User
+	if err := query.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) ExistsByEmailOrUsername(ctx context.Context, email, username string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("email = ? OR username = ?", email, username).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *userRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("username = ?", username).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *userRepository) LockAdmins(ctx context.Context) ([]model.User, error) {
+	var admins []model.User
+	if err := r.db.WithContext(ctx).Clauses(forUpdate).
+		Where("role = ?", model.RoleAdmin).
+		Order("id").
+		Find(&admins).Error; err != nil {
+		return nil, err
+	}
+	return admins, nil
+}
+
+func (r *userRepository) AdminExists(ctx context.Context) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("role = ?", model.RoleAdmin).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *userRepository) Create(ctx context.Context, user *model.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *userRepository) Save(ctx context.Context, user *model.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
+}
+
+func (r *userRepository) RecordRoleChange(ctx context.Context, change *model.RoleChange) error {
+	return r.db.WithContext(ctx).Create(change).Error
+}
+
+func (r *userRepository) ListRoleChanges(ctx context.Context, userID uint) ([]model.RoleChange, error) {
+	var changes []model.RoleChange
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at, id").
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
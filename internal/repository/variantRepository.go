@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"instashop/internal/model"
+)
+
+type variantRepository struct {
+	db *gorm.DB
+}
+
+// orderValues is the Preload condition that loads option values in display
+// order.
+func orderValues(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+func (r *variantRepository) ListOptions(ctx context.Context, productID uint) ([]model.ProductOption, error) {
+	var options []model.ProductOption
+	if err := r.db.WithContext(ctx).
+		Preload("Values", orderValues).
+		Where("product_id = ?", productID).
+		Order("position, id").
+		Find(&options).Error; err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (r *variantRepository) FindOption(ctx context.Context, productID, optionID uint) (*model.ProductOption, error) {
+	var option model.ProductOption
+	if err := r.db.WithContext(ctx).
+		Preload("Values", orderValues).
+		Where("id = ? AND product_id = ?", optionID, productID).
+		First(&option).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &option, nil
+}
+
+func (r *variantRepository) CreateOption(ctx context.Context, option *model.ProductOption) error {
+	return r.db.WithContext(ctx).Create(option).Error
+}
+
+func (r *variantRepository) CreateOptionValue(ctx context.Context, value *model.ProductOptionValue) error {
+	return r.db.WithContext(ctx).Create(value).Error
+}
+
+func (r *variantRepository) DeleteOption(ctx context.Context, productID, optionID uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND product_id = ?", optionID, productID).
+		Delete(&model.ProductOption{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *variantRepository) List(ctx context.Context, productID uint) ([]model.ProductVariant, error) {
+	var variants []model.ProductVariant
+	if err := r.db.WithContext(ctx).
+		Preload("Values").
+		Where("product_id = ?", productID).
+		Order("id").
+		Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (r *variantRepository) Find(ctx context.Context, productID, variantID uint) (*model.ProductVariant, error) {
+	return r.find(r.db.WithContext(ctx), productID, variantID)
+}
+
+func (r *variantRepository) FindForUpdate(ctx context.Context, productID, variantID uint) (*model.ProductVariant, error) {
+	return r.find(r.db.WithContext(ctx).Clauses(forUpdate), productID, variantID)
+}
+
+func (r *variantRepository) find(query *gorm.DB, productID, variantID uint) (*model.ProductVariant, error) {
+	var variant model.ProductVariant
+	if err := query.Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &variant, nil
+}
+
+func (r *variantRepository) Values(ctx context.Context, variantID uint) ([]model.ProductOptionValue, error) {
+	var values []model.ProductOptionValue
+	if err := r.db.WithContext(ctx).Model(&model.ProductVariant{ID: variantID}).Association("Values").Find(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (r *variantRepository) SKUTaken(ctx context.Context, sku string, exceptID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.ProductVariant{}).
+		Where("sku = ? AND id <> ?", sku, exceptID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *variantRepository) Create(ctx context.Context, variant *model.ProductVariant) error {
+	// The option values exist already; only link them
+	return r.db.WithContext(ctx).Omit("Values.*").Create(variant).Error
+}
+
+func (r *variantRepository) Save(ctx context.Context, variant *model.ProductVariant) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(variant).Error
+}
+
+func (r *variantRepository) Ordered(ctx context.Context, variantID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.OrderItem{}).
+		Where("variant_id = ?", variantID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *variantRepository) Delete(ctx context.Context, variantID uint) error {
+	return r.db.WithContext(ctx).Delete(&model.ProductVariant{}, variantID).Error
+}
@@ -1,28 +1,60 @@
 package server
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	"instashop/internal/config"
 	"instashop/internal/controller"
-	"instashop/internal/database"
+	"instashop/internal/mailer"
 	"instashop/internal/middleware"
+	"instashop/internal/payment"
+	"instashop/internal/repository"
 	"instashop/internal/service"
+	"instashop/internal/storage"
 )
 
 // RegisterRoutes sets up all the routes for the application
 func (s *Server) RegisterRoutes() http.Handler {
 
-	dbService := database.New()
+	cfg := s.config
+	dbService := s.db
+	emails := mailer.NewOutbox(dbService.GetGORM())
+	repos := repository.New(dbService.GetGORM(), emails)
+	unitOfWork := repository.NewUnitOfWork(dbService.GetGORM(), emails)
 
-	// Pass GORM DB to the user service
-	userService := service.NewUserService(dbService.GetGORM())
-	userController := controller.NewUserController(userService)
-	productService := service.NewProductService(dbService.GetGORM())
+	tokenService := service.NewTokenService(repos, unitOfWork, cfg.JWT.Secret)
+	userService := service.NewUserService(repos, unitOfWork, tokenService)
+	userController := controller.NewUserController(userService, tokenService)
+	productService := service.NewProductService(repos, unitOfWork)
 	productController := controller.NewProductController(productService)
-	orderService := service.NewOderService(dbService.GetGORM())
+	orderService := service.NewOderService(repos, unitOfWork)
 	orderController := controller.NewOrderController(orderService)
+	catalogService := service.NewCatalogService(repos)
+	catalogController := controller.NewCatalogController(catalogService)
+	cartService := service.NewCartService(repos, unitOfWork, orderService)
+	cartController := controller.NewCartController(cartService)
+	paymentService := service.NewPaymentService(repos, unitOfWork, newPaymentProvider(cfg.Payment), orderService, cfg.Payment.Currency, cfg.Payment.CallbackURL)
+	paymentController := controller.NewPaymentController(paymentService)
+	adminInviteService := service.NewAdminInviteService(repos, unitOfWork, cfg.Admin.InviteURL, cfg.Admin.BootstrapToken)
+	adminInviteController := controller.NewAdminInviteController(adminInviteService)
+	blobStorage, uploadDir := newBlobStorage(cfg.Storage)
+	productImageService := service.NewProductImageService(repos, unitOfWork, blobStorage)
+	productImageController := controller.NewProductImageController(productImageService)
+	productVariantService := service.NewProductVariantService(repos, unitOfWork)
+	productVariantController := controller.NewProductVariantController(productVariantService)
+	categoryService := service.NewCategoryService(repos, unitOfWork)
+	categoryController := controller.NewCategoryController(categoryService)
+	storeService := service.NewStoreService(repos, unitOfWork, orderService, blobStorage)
+	storeController := controller.NewStoreController(storeService, catalogService)
+	healthChecks := []service.HealthCheck{{Name: "database", Check: dbService.Ping}}
+	if smtp, ok := s.mailTransport.(*mailer.SMTPTransport); ok {
+		healthChecks = append(healthChecks, service.HealthCheck{Name: "smtp", Check: smtp.Ping})
+	}
+	healthService := service.NewHealthService(dbService, cfg.Server.HealthCheckTimeout, healthChecks...)
+	healthController := controller.NewHealthController(healthService)
 
 	// Initialize router
 	r := gin.Default()
@@ -33,9 +65,25 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Basic routes
 	r.GET("/", s.HelloWorldHandler)
 
+	// Liveness and readiness probes for the orchestrator
+	r.GET("/healthz", healthController.Liveness)
+	r.GET("/readyz", healthController.Readiness)
+
+	// Uploads kept on local disk are served by the app itself
+	if uploadDir != "" {
+		r.Static("/uploads", uploadDir)
+	}
+
+	verifyToken := middleware.VerifyToken([]byte(cfg.JWT.Secret), tokenService)
+
 	// API routes
 	r.POST("/v1/auth/users/create", userController.CreateUser)
-	r.POST("/v1/auth/admin/create", userController.CreateAdmin)
+
+	// Admins are created by an existing admin, or by invite. The bootstrap
+	// invite creates the first admin and is refused once one exists.
+	r.POST("/v1/auth/admin/create", verifyToken, middleware.RequirePermission(middleware.PermUserManage), userController.CreateAdmin)
+	r.POST("/v1/auth/admin/bootstrap", adminInviteController.BootstrapInviteHandler)
+	r.POST("/v1/auth/admin/invites/accept", adminInviteController.AcceptInviteHandler)
 
 	// Verify email route
 	r.POST("/v1/auth/verify-email", userController.VerifyEmailHandler)
@@ -44,22 +92,118 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	r.POST("/v1/auth/login", userController.LoginHandler)
 
-	// Product routes
+	r.POST("/v1/auth/refresh", userController.RefreshHandler)
+
+	// Password reset routes
+	r.POST("/v1/auth/forgot-password", userController.ForgotPasswordHandler)
+	r.POST("/v1/auth/reset-password", userController.ResetPasswordHandler)
+
+	// Public catalog
+	r.GET("/v1/catalog", catalogController.ListProducts)
+	r.GET("/v1/categories", categoryController.ListCategories)
+	r.GET("/v1/stores/:slug", storeController.GetStorePage)
+
+	// Payment provider webhooks are authenticated by their signature
+	r.POST("/v1/webhooks/payments", paymentController.WebhookHandler)
+
+	// Authenticated routes. Each route declares the permission it needs; see
+	// middleware.rolePermissions for which roles hold it.
 	authorized := r.Group("/v1")
-	authorized.Use(middleware.VerifyToken())
+	authorized.Use(verifyToken)
 	{
-		authorized.POST("/products", productController.CreateProduct)
-		authorized.GET("/products/:productID", productController.GetProduct)
-		authorized.GET("/products", productController.GetAllProductsByUserID)
-		authorized.PATCH("/products/:productID", productController.UpdateProduct)
-		authorized.DELETE("/products/:productID", productController.DeletePendingProduct)
+		authorized.POST("/auth/logout", userController.LogoutHandler)
+
+		// Product routes
+		authorized.POST("/products", middleware.RequirePermission(middleware.PermProductCreate), productController.CreateProduct)
+		authorized.GET("/products/:productID", middleware.RequirePermission(middleware.PermProductRead), productController.GetProduct)
+		authorized.GET("/products", middleware.RequirePermission(middleware.PermProductRead), productController.GetAllProductsByUserID)
+		authorized.PATCH("/products/:productID", middleware.RequirePermission(middleware.PermProductUpdate), productController.UpdateProduct)
+		authorized.PUT("/products/:productID/stock", middleware.RequirePermission(middleware.PermProductUpdate), productController.UpdateStock)
+		authorized.DELETE("/products/:productID", middleware.RequirePermission(middleware.PermProductDelete), productController.DeletePendingProduct)
+
+		// Product image routes
+		authorized.POST("/products/:productID/images", middleware.RequirePermission(middleware.PermProductUpdate), productImageController.AddImages)
+		authorized.GET("/products/:productID/images", middleware.RequirePermission(middleware.PermProductRead), productImageController.ListImages)
+		authorized.PUT("/products/:productID/images/order", middleware.RequirePermission(middleware.PermProductUpdate), productImageController.ReorderImages)
+		authorized.PATCH("/products/:productID/images/:imageID/primary", middleware.RequirePermission(middleware.PermProductUpdate), productImageController.SetPrimaryImage)
+		authorized.DELETE("/products/:productID/images/:imageID", middleware.RequirePermission(middleware.PermProductUpdate), productImageController.DeleteImage)
+
+		// Product option and variant routes
+		authorized.POST("/products/:productID/options", middleware.RequirePermission(middleware.PermProductUpdate), productVariantController.AddOption)
+		authorized.POST("/products/:productID/options/:optionID/values", middleware.RequirePermission(middleware.PermProductUpdate), productVariantController.AddOptionValue)
+		authorized.DELETE("/products/:productID/options/:optionID", middleware.RequirePermission(middleware.PermProductUpdate), productVariantController.DeleteOption)
+		authorized.POST("/products/:productID/variants", middleware.RequirePermission(middleware.PermProductUpdate), productVariantController.CreateVariant)
+		authorized.PATCH("/products/:productID/variants/:variantID", middleware.RequirePermission(middleware.PermProductUpdate), productVariantController.UpdateVariant)
+		authorized.DELETE("/products/:productID/variants/:variantID", middleware.RequirePermission(middleware.PermProductUpdate), productVariantController.DeleteVariant)
 
 		// Order routes
 		orderRoutes := authorized.Group("/orders")
 		{
-			orderRoutes.POST("/", orderController.PlaceOrderHandler)
-			orderRoutes.GET("/", orderController.ListOrdersHandler)
-			orderRoutes.PATCH("/:orderID", orderController.CancelOrderHandler)
+			orderRoutes.POST("/", middleware.RequirePermission(middleware.PermOrderPlace), orderController.PlaceOrderHandler)
+			orderRoutes.GET("/", middleware.RequirePermission(middleware.PermOrderRead), orderController.ListOrdersHandler)
+			orderRoutes.PATCH("/:orderID", middleware.RequirePermission(middleware.PermOrderCancel), orderController.CancelOrderHandler)
+			orderRoutes.GET("/:orderID/history", middleware.RequirePermission(middleware.PermOrderRead), orderController.OrderHistoryHandler)
+			orderRoutes.POST("/:orderID/payments", middleware.RequirePermission(middleware.PermPaymentCreate), paymentController.InitializePaymentHandler)
+		}
+
+		authorized.GET("/payments/:reference/verify", middleware.RequirePermission(middleware.PermPaymentCreate), paymentController.VerifyPaymentHandler)
+
+		// Cart routes
+		cartRoutes := authorized.Group("/cart")
+		cartRoutes.Use(middleware.RequirePermission(middleware.PermCartManage))
+		{
+			cartRoutes.GET("/", cartController.GetCart)
+			cartRoutes.POST("/items", cartController.AddItem)
+			cartRoutes.PATCH("/items/:productID", cartController.UpdateItem)
+			cartRoutes.DELETE("/items/:productID", cartController.RemoveItem)
+			cartRoutes.POST("/checkout", cartController.Checkout)
+		}
+
+		// Seller routes. Owners manage their stores; owners and staff manage
+		// the store's orders.
+		sellerRoutes := authorized.Group("/seller/stores")
+		{
+			sellerRoutes.POST("", middleware.RequirePermission(middleware.PermStoreManage), storeController.CreateStore)
+			sellerRoutes.GET("", middleware.RequirePermission(middleware.PermStoreOrders), storeController.ListMyStores)
+			sellerRoutes.GET("/:storeID", middleware.RequirePermission(middleware.PermStoreOrders), storeController.GetStore)
+			sellerRoutes.PATCH("/:storeID", middleware.RequirePermission(middleware.PermStoreManage), storeController.UpdateStore)
+			sellerRoutes.POST("/:storeID/staff", middleware.RequirePermission(middleware.PermStoreManage), storeController.AddStaff)
+			sellerRoutes.DELETE("/:storeID/staff/:userID", middleware.RequirePermission(middleware.PermStoreManage), storeController.RemoveStaff)
+			sellerRoutes.GET("/:storeID/orders", middleware.RequirePermission(middleware.PermStoreOrders), storeController.ListStoreOrders)
+			sellerRoutes.PATCH("/:storeID/orders/:orderID/status", middleware.RequirePermission(middleware.PermStoreOrders), storeController.UpdateStoreOrderStatus)
+		}
+
+		// Admin routes
+		adminRoutes := authorized.Group("/admin")
+		adminRoutes.Use(middleware.RequireRole(middleware.RoleAdmin))
+		{
+			adminOrderRoutes := adminRoutes.Group("/orders")
+			adminOrderRoutes.GET("/", middleware.RequirePermission(middleware.PermOrderReadAll), orderController.ListAllOrdersHandler)
+			adminOrderRoutes.PATCH("/:orderID/approve", middleware.RequirePermission(middleware.PermOrderApprove), orderController.ApproveOrderHandler)
+			adminOrderRoutes.PATCH("/:orderID/decline", middleware.RequirePermission(middleware.PermOrderApprove), orderController.DeclineOrderHandler)
+			adminOrderRoutes.PATCH("/:orderID/status", middleware.RequirePermission(middleware.PermOrderFulfil), orderController.UpdateOrderStatusHandler)
+
+			adminProductRoutes := adminRoutes.Group("/products")
+			adminProductRoutes.Use(middleware.RequirePermission(middleware.PermProductModerate))
+			adminProductRoutes.GET("/pending", productController.ListPendingProducts)
+			adminProductRoutes.PATCH("/:productID/approve", productController.ApproveProduct)
+			adminProductRoutes.PATCH("/:productID/decline", productController.DeclineProduct)
+
+			adminRoutes.POST("/payments/:reference/refund", middleware.RequirePermission(middleware.PermPaymentRefund), paymentController.RefundPaymentHandler)
+
+			adminRoutes.GET("/health", middleware.RequirePermission(middleware.PermSystemHealth), healthController.Details)
+
+			adminCategoryRoutes := adminRoutes.Group("/categories")
+			adminCategoryRoutes.Use(middleware.RequirePermission(middleware.PermCategoryManage))
+			adminCategoryRoutes.POST("", categoryController.CreateCategory)
+			adminCategoryRoutes.PATCH("/:categoryID", categoryController.UpdateCategory)
+			adminCategoryRoutes.DELETE("/:categoryID", categoryController.DeleteCategory)
+
+			adminUserRoutes := adminRoutes.Group("/")
+			adminUserRoutes.Use(middleware.RequirePermission(middleware.PermUserManage))
+			adminUserRoutes.POST("/invites", adminInviteController.CreateInviteHandler)
+			adminUserRoutes.PATCH("/users/:userID/role", adminInviteController.ChangeRoleHandler)
+			adminUserRoutes.GET("/users/:userID/role-changes", adminInviteController.RoleChangesHandler)
 		}
 
 	}
@@ -67,12 +211,30 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Handle not found routes
 	r.NoRoute(middleware.HandleNotFound)
 
-	// Assign the DB close method to the Server's cleanup logic
-	s.cleanupFunc = func() {
-		s.db.Close()
+	return r
+}
+
+// newPaymentProvider picks the configured payment provider. Paystack is the
+// default; "fake" is for local development.
+func newPaymentProvider(cfg config.Payment) payment.PaymentProvider {
+	if cfg.Provider == "fake" {
+		return payment.NewFakeProvider(cfg.WebhookSecret)
 	}
+	return payment.NewPaystackProvider(cfg.PaystackSecretKey, cfg.PaystackBaseURL)
+}
 
-	return r
+// newBlobStorage picks where uploads are stored. The cloudinary driver uses
+// the Cloudinary account; the local driver keeps files in the upload
+// directory, and then also returns that directory so it can be served.
+func newBlobStorage(cfg config.Storage) (storage.BlobStorage, string) {
+	if cfg.Driver == "cloudinary" {
+		cld, err := storage.NewCloudinaryStorage(cfg.Cloudinary.CloudName, cfg.Cloudinary.APIKey, cfg.Cloudinary.APISecret, cfg.Cloudinary.Folder)
+		if err != nil {
+			log.Fatalf("Failed to configure Cloudinary storage: %v", err)
+		}
+		return cld, ""
+	}
+	return storage.NewLocalStorage(cfg.UploadDir, cfg.UploadBaseURL), cfg.UploadDir
 }
 
 func (s *Server) HelloWorldHandler(c *gin.Context) {
@@ -1,38 +1,57 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 
-	_ "github.com/joho/godotenv/autoload"
-
+	"instashop/internal/config"
 	"instashop/internal/database"
+	"instashop/internal/lifecycle"
+	"instashop/internal/mailer"
+	"instashop/internal/utils"
 )
 
 type Server struct {
-	port        int
-	db          database.Service
-	cleanupFunc func()
+	port   int
+	config *config.Config
+	db     database.Service
+	// mailTransport delivers the emails mailWorker takes from the outbox
+	mailTransport mailer.Transport
+	mailWorker    *mailer.Worker
+	httpServer    *http.Server
+	lifecycle     *lifecycle.Manager
 }
 
-func NewServer() *http.Server {
-	portStr := os.Getenv("PORT")
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		port = 8080
-	}
+func NewServer(cfg *config.Config) *Server {
+	return New(cfg, database.New(cfg.Database), newMailTransport(cfg.Mail))
+}
 
-	db := database.New()
+// New returns a Server using db and delivering email through mailTransport,
+// e.g. a test schema and a transport that captures the email.
+func New(cfg *config.Config, db database.Service, mailTransport mailer.Transport) *Server {
+	utils.SetServiceName(cfg.ServiceName)
 
 	newServer := &Server{
-		port: port,
-		db:   db,
+		port:          cfg.Server.Port,
+		config:        cfg,
+		db:            db,
+		mailTransport: mailTransport,
+		lifecycle:     lifecycle.New(),
 	}
 
-	server := &http.Server{
+	newServer.mailWorker = mailer.NewWorker(newServer.db.GetGORM(), newServer.mailTransport, mailer.WorkerConfig{
+		Workers:      cfg.Mail.Workers,
+		PollInterval: cfg.Mail.PollInterval,
+		MaxAttempts:  cfg.Mail.MaxAttempts,
+		Backoff:      cfg.Mail.RetryBackoff,
+		MaxBackoff:   cfg.Mail.MaxRetryBackoff,
+	})
+
+	newServer.httpServer = &http.Server{
 		Addr:         fmt.Sprintf(":%d", newServer.port),
 		Handler:      newServer.RegisterRoutes(),
 		IdleTimeout:  time.Minute,
@@ -40,5 +59,57 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	return server
+	// Hooks run in reverse: stop taking requests, then let the mail workers
+	// finish the emails they are sending, then close the connections they
+	// all used
+	newServer.lifecycle.OnShutdown("database", func(context.Context) error {
+		return newServer.db.Close()
+	})
+	if smtp, ok := newServer.mailTransport.(*mailer.SMTPTransport); ok {
+		newServer.lifecycle.OnShutdown("smtp connections", smtp.Close)
+	}
+	newServer.lifecycle.OnShutdown("mail workers", newServer.mailWorker.Stop)
+	newServer.lifecycle.OnShutdown("http server", newServer.httpServer.Shutdown)
+
+	return newServer
+}
+
+// Run serves requests until ctx is cancelled, e.g. by a signal, then shuts
+// down, giving in-flight requests and background work the configured
+// shutdown timeout to finish.
+func (s *Server) Run(ctx context.Context) error {
+	s.mailWorker.Start()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", s.httpServer.Addr)
+		serveErr <- s.httpServer.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// The server failed to start or stopped on its own
+		err = fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+		log.Printf("Shutting down, waiting up to %s", s.config.Server.ShutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.Server.ShutdownTimeout)
+	defer cancel()
+	return errors.Join(err, s.lifecycle.Shutdown(shutdownCtx))
+}
+
+// Handler returns the routes the server serves.
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// newMailTransport picks how queued email is delivered: through the SMTP
+// server, or written to files for local development.
+func newMailTransport(cfg config.Mail) mailer.Transport {
+	if cfg.Transport == "file" {
+		return mailer.NewFileTransport(cfg.FileDir, cfg.Sender)
+	}
+	return mailer.NewSMTPTransport(cfg, cfg.Workers)
 }
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"instashop/internal/common"
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/utils"
+)
+
+// AdminInviteTTL is how long an admin invite can be accepted for.
+const AdminInviteTTL = 72 * time.Hour
+
+// adminBootstrapLock serializes creating and accepting bootstrap invites.
+const adminBootstrapLock = "admin_bootstrap"
+
+// AdminInviteService manages admin invites and role changes. Every change of
+// role is recorded in role_changes.
+type AdminInviteService struct {
+	Repos repository.Repositories
+	Tx    repository.UnitOfWork
+	// InviteURL is the page that accepts invites; the token is appended as
+	// the "token" query parameter.
+	InviteURL string
+	// BootstrapToken allows the first admin to be invited when no admin
+	// exists. Bootstrapping is disabled when it is empty.
+	BootstrapToken string
+}
+
+func NewAdminInviteService(repos repository.Repositories, tx repository.UnitOfWork, inviteURL, bootstrapToken string) *AdminInviteService {
+	return &AdminInviteService{Repos: repos, Tx: tx, InviteURL: inviteURL, BootstrapToken: bootstrapToken}
+}
+
+// CreateInvite emails an admin invite to email on behalf of an existing admin
+func (s *AdminInviteService) CreateInvite(ctx context.Context, invitedBy uint, email string) (*model.AdminInvite, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, utils.NewBadRequestError("email is required")
+	}
+
+	var invite *model.AdminInvite
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		var token string
+		var err error
+		invite, token, err = createInvite(ctx, tx.Invites, email, &invitedBy)
+		if err != nil {
+			return err
+		}
+		return s.sendInvite(ctx, tx.Emails, invite, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// CreateBootstrapInvite emails an invite for the first admin. It requires the
+// configured bootstrap token and is refused once any admin exists, so it can
+// only ever produce one admin. Requesting it again replaces any unaccepted
+// bootstrap invite.
+func (s *AdminInviteService) CreateBootstrapInvite(ctx context.Context, bootstrapToken, email string) (*model.AdminInvite, error) {
+	if s.BootstrapToken == "" {
+		return nil, utils.NewForbiddenError("admin bootstrap is disabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(bootstrapToken), []byte(s.BootstrapToken)) != 1 {
+		return nil, utils.NewUnauthorizedError("invalid bootstrap token")
+	}
+
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, utils.NewBadRequestError("email is required")
+	}
+
+	var invite *model.AdminInvite
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Serialize bootstrap requests so two cannot both see no admin
+		if err := tx.Locks.Lock(ctx, adminBootstrapLock); err != nil {
+			return fmt.Errorf("failed to lock admin bootstrap: %w", err)
+		}
+
+		if err := ensureNoAdmin(ctx, tx.Users); err != nil {
+			return err
+		}
+
+		// Expire any earlier bootstrap invite that was never accepted
+		if err := tx.Invites.ExpireBootstrapInvites(ctx); err != nil {
+			return fmt.Errorf("failed to expire bootstrap invites: %w", err)
+		}
+
+		var token string
+		var err error
+		invite, token, err = createInvite(ctx, tx.Invites, email, nil)
+		if err != nil {
+			return err
+		}
+		return s.sendInvite(ctx, tx.Emails, invite, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// AcceptInvite redeems an invite. If an account already exists for the
+// invited email, password must be its password and the account is promoted to
+// admin; otherwise a new verified admin account is created.
+func (s *AdminInviteService) AcceptInvite(ctx context.Context, token, username, password string) (*model.User, error) {
+	invalidInvite := utils.NewBadRequestError("invalid or expired invite")
+
+	var user *model.User
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Take the bootstrap lock before the invite row, in the same order as
+		// CreateBootstrapInvite, so a bootstrap invite cannot be accepted while
+		// another admin is being bootstrapped
+		if err := tx.Locks.Lock(ctx, adminBootstrapLock); err != nil {
+			return fmt.Errorf("failed to lock admin bootstrap: %w", err)
+		}
+
+		invite, err := tx.Invites.FindByTokenHashForUpdate(ctx, hashToken(token))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return invalidInvite
+			}
+			return fmt.Errorf("failed to retrieve invite: %w", err)
+		}
+		if invite.AcceptedAt != nil || time.Now().After(invite.ExpiresAt) {
+			return invalidInvite
+		}
+
+		if invite.InvitedBy == nil {
+			if err := ensureNoAdmin(ctx, tx.Users); err != nil {
+				return err
+			}
+		}
+
+		fromRole := ""
+		user, err = tx.Users.FindByEmailForUpdate(ctx, invite.Email)
+		switch {
+		case err == nil:
+			// Promote the existing account
+			if !utils.VerifyPassword(password, user.Password) {
+				return utils.NewUnauthorizedError("invalid password")
+			}
+			if user.Role == model.RoleAdmin {
+				return utils.NewConflictError("user is already an admin")
+			}
+			fromRole = user.Role
+			user.Role = model.RoleAdmin
+			user.VerifiedEmail = true
+			if err := tx.Users.Save(ctx, user); err != nil {
+				return fmt.Errorf("failed to update user: %w", err)
+			}
+
+		case errors.Is(err, repository.ErrNotFound):
+			// Create a new admin account
+			username = strings.TrimSpace(username)
+			if username == "" {
+				return utils.NewBadRequestError("username is required")
+			}
+			if _, err := common.ValidatePasswordString(password); err != nil {
+				return utils.NewValidationError(err.Error())
+			}
+
+			taken, err := tx.Users.ExistsByUsername(ctx, username)
+			if err != nil {
+				return fmt.Errorf("failed to check username: %w", err)
+			}
+			if taken {
+				return utils.NewConflictError("username is already taken")
+			}
+
+			user = &model.User{
+				Username:      username,
+				Email:         invite.Email,
+				Password:      utils.HashPassword(password),
+				VerifiedEmail: true,
+				Role:          model.RoleAdmin,
+			}
+			if err := tx.Users.Create(ctx, user); err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+
+		default:
+			return fmt.Errorf("failed to retrieve user: %w", err)
+		}
+
+		if err := recordRoleChange(ctx, tx.Users, user.ID, fromRole, model.RoleAdmin, invite.InvitedBy, &invite.ID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		invite.AcceptedAt = &now
+		invite.AcceptedBy = &user.ID
+		if err := tx.Invites.Save(ctx, invite); err != nil {
+			return fmt.Errorf("failed to update invite: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ChangeRole sets the role of a user on behalf of an admin. Admins cannot
+// change their own role, so there is always at least one admin left. A user
+// whose role is lowered is signed out of every session: their refresh tokens
+// are revoked and their access tokens, which carry the old role, rejected.
+func (s *AdminInviteService) ChangeRole(ctx context.Context, actorID, userID uint, role string) (*model.User, error) {
+	if !model.IsValidRole(role) {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("invalid role %q", role))
+	}
+	if actorID == userID {
+		return nil, utils.NewBadRequestError("you cannot change your own role")
+	}
+
+	var user *model.User
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Lock every admin so two admins cannot demote each other at once
+		admins, err := tx.Users.LockAdmins(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve admins: %w", err)
+		}
+		actorIsAdmin := false
+		for _, admin := range admins {
+			if admin.ID == actorID {
+				actorIsAdmin = true
+			}
+		}
+		if !actorIsAdmin {
+			return utils.NewForbiddenError("only admins can change roles")
+		}
+
+		user, err = tx.Users.FindByIDForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewNotFoundError("user not found")
+			}
+			return fmt.Errorf("failed to retrieve user: %w", err)
+		}
+
+		fromRole := user.Role
+		if fromRole == role {
+			return nil
+		}
+
+		user.Role = role
+		demoted := roleRank(role) < roleRank(fromRole)
+		if demoted {
+			// Sessions issued before this moment are rejected by VerifyToken
+			validAfter := tokenCutoff()
+			user.TokensValidAfter = &validAfter
+		}
+		if err := tx.Users.Save(ctx, user); err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		if demoted {
+			if err := tx.Tokens.RevokeUser(ctx, user.ID); err != nil {
+				return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+			}
+		}
+		return recordRoleChange(ctx, tx.Users, user.ID, fromRole, role, &actorID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ListRoleChanges returns the role history of a user, oldest first
+func (s *AdminInviteService) ListRoleChanges(ctx context.Context, userID uint) ([]model.RoleChange, error) {
+	changes, err := s.Repos.Users.ListRoleChanges(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve role changes: %w", err)
+	}
+	return changes, nil
+}
+
+// sendInvite queues the invite email in the unit of work storing the invite,
+// so it is only sent if the invite is stored.
+func (s *AdminInviteService) sendInvite(ctx context.Context, emails mailer.Mailer, invite *model.AdminInvite, token string) error {
+	link := token
+	if s.InviteURL != "" {
+		link = s.InviteURL + "?token=" + url.QueryEscape(token)
+	}
+
+	return emails.Send(ctx, mailer.Email{
+		To:       invite.Email,
+		Template: mailer.TemplateAdminInvite,
+		Data:     mailer.AdminInviteData{Link: link, ExpiresAt: invite.ExpiresAt},
+	})
+}
+
+// createInvite stores a new invite and returns it with its plaintext token.
+func createInvite(ctx context.Context, invites repository.InviteRepository, email string, invitedBy *uint) (*model.AdminInvite, string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	invite := &model.AdminInvite{
+		Email:     email,
+		TokenHash: hashToken(token),
+		InvitedBy: invitedBy,
+		ExpiresAt: time.Now().Add(AdminInviteTTL),
+	}
+	if err := invites.Create(ctx, invite); err != nil {
+		return nil, "", fmt.Errorf("failed to create invite: %w", err)
+	}
+	return invite, token, nil
+}
+
+// ensureNoAdmin returns a conflict if any admin account exists.
+func ensureNoAdmin(ctx context.Context, users repository.UserRepository) error {
+	exists, err := users.AdminExists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if exists {
+		return utils.NewConflictError("an admin already exists; ask an admin for an invite")
+	}
+	return nil
+}
+
+// recordRoleChange writes an audit record of a role change.
+func recordRoleChange(ctx context.Context, users repository.UserRepository, userID uint, fromRole, toRole string, changedBy, inviteID *uint) error {
+	change := &model.RoleChange{
+		UserID:    userID,
+		FromRole:  fromRole,
+		ToRole:    toRole,
+		ChangedBy: changedBy,
+		InviteID:  inviteID,
+	}
+	if err := users.RecordRoleChange(ctx, change); err != nil {
+		return fmt.Errorf("failed to record role change: %w", err)
+	}
+	return nil
+}
+
+// roleRank orders roles by privilege.
+func roleRank(role string) int {
+	switch role {
+	case model.RoleAdmin:
+		return 2
+	case model.RoleEditor:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/utils"
+)
+
+type CartService struct {
+	Repos        repository.Repositories
+	Tx           repository.UnitOfWork
+	OrderService *OrderService
+}
+
+func NewCartService(repos repository.Repositories, tx repository.UnitOfWork, orderService *OrderService) *CartService {
+	return &CartService{Repos: repos, Tx: tx, OrderService: orderService}
+}
+
+// CartLine is a cart item priced at the current product or variant price.
+type CartLine struct {
+	ProductID  uint    `json:"product_id"`
+	VariantID  *uint   `json:"variant_id"`
+	SKU        string  `json:"sku,omitempty"`
+	Name       string  `json:"name"`
+	Quantity   int     `json:"quantity"`
+	UnitPrice  float64 `json:"unit_price"`
+	AddedPrice float64 `json:"added_price"`
+	LineTotal  float64 `json:"line_total"`
+	// Available is false when the product is no longer approved or the
+	// product or variant does not have enough stock for the requested quantity.
+	Available bool `json:"available"`
+}
+
+// CartSummary is the user's cart with totals at the current product prices.
+type CartSummary struct {
+	Items []CartLine `json:"items"`
+	Total float64    `json:"total"`
+}
+
+// GetCart returns the user's cart priced at the current product prices
+func (s *CartService) GetCart(ctx context.Context, userID uint) (*CartSummary, error) {
+	cart, err := s.Repos.Carts.FindWithItems(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &CartSummary{Items: []CartLine{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve cart: %w", err)
+	}
+
+	summary := &CartSummary{Items: []CartLine{}}
+	for _, item := range cart.Items {
+		price, stock := item.Product.Price, item.Product.Stock
+		line := CartLine{
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Name:       item.Product.Name,
+			Quantity:   item.Quantity,
+			AddedPrice: item.UnitPrice,
+		}
+		if item.Variant != nil {
+			line.SKU = item.Variant.SKU
+			price, stock = item.Variant.UnitPrice(item.Product), item.Variant.Stock
+		}
+		line.UnitPrice = price
+		line.LineTotal = roundMoney(price * float64(item.Quantity))
+		line.Available = item.Product.Status == model.StatusApproved && stock >= item.Quantity
+		summary.Items = append(summary.Items, line)
+		summary.Total = roundMoney(summary.Total + line.LineTotal)
+	}
+	return summary, nil
+}
+
+// AddItem adds a quantity of a product to the user's cart, creating the cart
+// if needed. Adding a product already in the cart increases its quantity.
+// variantID picks the variant of a product sold in variants and is 0
+// otherwise.
+func (s *CartService) AddItem(ctx context.Context, userID uint, productID uint, variantID uint, quantity int) error {
+	if quantity < 1 {
+		return utils.NewBadRequestError("quantity must be at least 1")
+	}
+
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		product, variant, err := purchasableItem(ctx, tx, productID, variantID)
+		if err != nil {
+			return err
+		}
+
+		cart, err := tx.Carts.FindOrCreate(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve cart: %w", err)
+		}
+
+		item := model.CartItem{
+			CartID:    cart.ID,
+			ProductID: product.ID,
+			Quantity:  quantity,
+			UnitPrice: product.Price,
+		}
+		if variant != nil {
+			item.VariantID = &variant.ID
+			item.UnitPrice = variant.UnitPrice(product)
+		}
+		if err := tx.Carts.AddItem(ctx, &item); err != nil {
+			return fmt.Errorf("failed to add item to cart: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateItem sets the quantity of a product or variant in the user's cart and
+// refreshes its price to the current price
+func (s *CartService) UpdateItem(ctx context.Context, userID uint, productID uint, variantID uint, quantity int) error {
+	if quantity < 1 {
+		return utils.NewBadRequestError("quantity must be at least 1")
+	}
+
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		product, variant, err := purchasableItem(ctx, tx, productID, variantID)
+		if err != nil {
+			return err
+		}
+
+		price := product.Price
+		if variant != nil {
+			price = variant.UnitPrice(product)
+		}
+		updated, err := tx.Carts.UpdateItem(ctx, userID, productID, variantID, quantity, price)
+		if err != nil {
+			return fmt.Errorf("failed to update cart item: %w", err)
+		}
+		if !updated {
+			return utils.NewNotFoundError("product is not in your cart")
+		}
+		return nil
+	})
+}
+
+// RemoveItem removes a product or variant from the user's cart
+func (s *CartService) RemoveItem(ctx context.Context, userID uint, productID uint, variantID uint) error {
+	removed, err := s.Repos.Carts.RemoveItem(ctx, userID, productID, variantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove cart item: %w", err)
+	}
+	if !removed {
+		return utils.NewNotFoundError("product is not in your cart")
+	}
+	return nil
+}
+
+// Checkout turns the user's cart into an order and empties the cart in a
+// single unit of work. Prices are checked against the locked product and
+// variant rows the order is placed from. If any price changed since it was
+// added, the cart is updated to the current prices and a conflict is returned
+// so the customer can review it before checking out again.
+func (s *CartService) Checkout(ctx context.Context, userID uint) (*model.Order, error) {
+	var order *model.Order
+	var priceChanges *priceChangeError
+
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Lock the cart so the same cart cannot be checked out twice
+		cart, err := tx.Carts.FindByUserForUpdate(ctx, userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return utils.NewBadRequestError("your cart is empty")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to retrieve cart: %w", err)
+		}
+
+		items, err := tx.Carts.ListItems(ctx, cart.ID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve cart items: %w", err)
+		}
+		if len(items) == 0 {
+			return utils.NewBadRequestError("your cart is empty")
+		}
+
+		orderItems := make([]OrderItemInput, 0, len(items))
+		expected := make(map[orderLine]float64, len(items))
+		for _, item := range items {
+			input := OrderItemInput{ProductID: item.ProductID, Quantity: item.Quantity}
+			if item.VariantID != nil {
+				input.VariantID = *item.VariantID
+			}
+			orderItems = append(orderItems, input)
+			expected[orderLine{ProductID: input.ProductID, VariantID: input.VariantID}] = item.UnitPrice
+		}
+
+		order, err = s.OrderService.placeOrder(ctx, tx, userID, orderItems, expected)
+		if errors.As(err, &priceChanges) {
+			// Commit the refreshed prices without placing the order
+			for _, item := range items {
+				line := orderLine{ProductID: item.ProductID}
+				if item.VariantID != nil {
+					line.VariantID = *item.VariantID
+				}
+				price, changed := priceChanges.prices[line]
+				if !changed {
+					continue
+				}
+				if err := tx.Carts.SetItemPrice(ctx, item.ID, price); err != nil {
+					return fmt.Errorf("failed to update cart item: %w", err)
+				}
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Carts.Clear(ctx, cart.ID); err != nil {
+			return fmt.Errorf("failed to empty cart: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if priceChanges != nil {
+		return nil, utils.NewConflictError(priceChanges.Error() + "; review your cart and check out again")
+	}
+
+	return order, nil
+}
+
+// purchasableItem returns the product, and the chosen variant, if the product
+// exists and is approved. Products sold in variants require a variant.
+func purchasableItem(ctx context.Context, repos repository.Repositories, productID uint, variantID uint) (*model.Product, *model.ProductVariant, error) {
+	product, err := repos.Products.FindByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, utils.NewNotFoundError("product not found")
+		}
+		return nil, nil, fmt.Errorf("failed to retrieve product: %w", err)
+	}
+
+	if product.Status != model.StatusApproved {
+		return nil, nil, utils.NewBadRequestError(fmt.Sprintf("product %d is not available for purchase", productID))
+	}
+
+	if variantID == 0 {
+		withVariants, err := repos.Products.ProductIDsWithVariants(ctx, []uint{productID})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve variants: %w", err)
+		}
+		if len(withVariants) > 0 {
+			return nil, nil, utils.NewBadRequestError(fmt.Sprintf("choose a variant of product %d", productID))
+		}
+		return product, nil, nil
+	}
+
+	variant, err := repos.Variants.Find(ctx, productID, variantID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, utils.NewNotFoundError("variant not found")
+		}
+		return nil, nil, fmt.Errorf("failed to retrieve variant: %w", err)
+	}
+	return product, variant, nil
+}
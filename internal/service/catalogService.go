@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/utils"
+)
+
+// Catalog sort orders.
+const (
+	CatalogSortNewest    = repository.CatalogSortNewest
+	CatalogSortPriceAsc  = repository.CatalogSortPriceAsc
+	CatalogSortPriceDesc = repository.CatalogSortPriceDesc
+)
+
+const (
+	defaultCatalogLimit = 20
+	maxCatalogLimit     = 100
+)
+
+type CatalogService struct {
+	Repos repository.Repositories
+}
+
+func NewCatalogService(repos repository.Repositories) *CatalogService {
+	return &CatalogService{Repos: repos}
+}
+
+// CatalogQuery describes a page of the public catalog. Zero values are ignored.
+type CatalogQuery struct {
+	StoreID  uint
+	Category string // slug; matches the category and its subcategories
+	Tag      string // slug
+	Search   string
+	MinPrice *float64
+	MaxPrice *float64
+	Sort     string
+	Cursor   string
+	Limit    int
+}
+
+// CatalogPage is a page of approved products. NextCursor is empty on the last page.
+type CatalogPage struct {
+	Products   []model.Product `json:"products"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// catalogCursor is the position after the last product of a page. Value is
+// the sort key of that product and ID breaks ties so the ordering is stable.
+type catalogCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// ListProducts returns a page of approved products matching the query.
+func (s *CatalogService) ListProducts(ctx context.Context, q CatalogQuery) (*CatalogPage, error) {
+	if q.Sort == "" {
+		q.Sort = CatalogSortNewest
+	}
+	if q.Sort != CatalogSortNewest && q.Sort != CatalogSortPriceAsc && q.Sort != CatalogSortPriceDesc {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("invalid sort %q", q.Sort))
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultCatalogLimit
+	}
+	if q.Limit > maxCatalogLimit {
+		q.Limit = maxCatalogLimit
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return nil, utils.NewBadRequestError("min_price cannot be greater than max_price")
+	}
+
+	filter := repository.CatalogFilter{
+		StoreID:  q.StoreID,
+		Category: q.Category,
+		Tag:      q.Tag,
+		Search:   q.Search,
+		MinPrice: q.MinPrice,
+		MaxPrice: q.MaxPrice,
+		Sort:     q.Sort,
+		// Fetch one extra row to know whether there is another page
+		Limit: q.Limit + 1,
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeCatalogCursor(q.Cursor)
+		if err != nil || cursor.Sort != q.Sort {
+			return nil, utils.NewBadRequestError("invalid cursor")
+		}
+
+		after := &repository.CatalogPosition{ID: cursor.ID}
+		if q.Sort == CatalogSortNewest {
+			after.CreatedAt, err = time.Parse(time.RFC3339Nano, cursor.Value)
+		} else {
+			after.Price, err = strconv.ParseFloat(cursor.Value, 64)
+		}
+		if err != nil {
+			return nil, utils.NewBadRequestError("invalid cursor")
+		}
+		filter.After = after
+	}
+
+	products, err := s.Repos.Catalog.ListProducts(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve catalog: %w", err)
+	}
+
+	page := &CatalogPage{Products: products}
+	if len(products) > q.Limit {
+		page.Products = products[:q.Limit]
+		last := page.Products[q.Limit-1]
+
+		cursor := catalogCursor{Sort: q.Sort, ID: last.ID}
+		if q.Sort == CatalogSortNewest {
+			cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+		} else {
+			cursor.Value = strconv.FormatFloat(last.Price, 'f', -1, 64)
+		}
+		page.NextCursor = encodeCatalogCursor(cursor)
+	}
+
+	return page, nil
+}
+
+func encodeCatalogCursor(cursor catalogCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCatalogCursor(encoded string) (*catalogCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor catalogCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
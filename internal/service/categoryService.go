@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/utils"
+)
+
+// maxProductTags is the most tags a product can have
+const maxProductTags = 20
+
+// categoryTreeLock serializes category moves so two concurrent moves cannot
+// form a cycle
+const categoryTreeLock = "category_tree"
+
+type CategoryService struct {
+	Repos repository.Repositories
+	Tx    repository.UnitOfWork
+}
+
+func NewCategoryService(repos repository.Repositories, tx repository.UnitOfWork) *CategoryService {
+	return &CategoryService{Repos: repos, Tx: tx}
+}
+
+// CategoryInput describes a category. An empty Slug is derived from the Name
+// and a nil ParentID makes a top-level category.
+type CategoryInput struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Slug     string `json:"slug" binding:"omitempty,max=100,slug"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// CategoryUpdate holds the fields of a category to change. Nil fields are left
+// unchanged; a ParentID of 0 moves the category to the top level.
+type CategoryUpdate struct {
+	Name     *string `json:"name" binding:"omitnil,min=1,max=100"`
+	Slug     *string `json:"slug" binding:"omitnil,max=100,slug"`
+	ParentID *uint   `json:"parent_id"`
+}
+
+// ProductTaxonomy is the category and tags of a product. A nil CategoryID or
+// Tags leaves that part unchanged on update; a CategoryID of 0 removes the
+// category and an empty Tags removes all tags.
+type ProductTaxonomy struct {
+	CategoryID *uint
+	Tags       []string
+}
+
+// ListCategories returns the category tree: the top-level categories with
+// their children nested, each level sorted by name.
+func (s *CategoryService) ListCategories(ctx context.Context) ([]model.Category, error) {
+	categories, err := s.Repos.Categories.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve categories: %w", err)
+	}
+
+	children := make(map[uint][]model.Category)
+	var roots []model.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var attach func(nodes []model.Category) []model.Category
+	attach = func(nodes []model.Category) []model.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	return attach(roots), nil
+}
+
+// CreateCategory adds a category, under ParentID when set
+func (s *CategoryService) CreateCategory(ctx context.Context, input CategoryInput) (*model.Category, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, utils.NewBadRequestError("category name is required")
+	}
+	slug := input.Slug
+	if slug == "" {
+		slug = slugify(name)
+	}
+	if err := validateSlug(slug); err != nil {
+		return nil, err
+	}
+
+	category := &model.Category{Name: name, Slug: slug, ParentID: input.ParentID}
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if input.ParentID != nil {
+			if _, err := tx.Categories.FindByID(ctx, *input.ParentID); err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return utils.NewNotFoundError("parent category not found")
+				}
+				return fmt.Errorf("failed to retrieve category: %w", err)
+			}
+		}
+		if err := ensureCategorySlugAvailable(ctx, tx.Categories, slug, 0); err != nil {
+			return err
+		}
+		if err := tx.Categories.Create(ctx, category); err != nil {
+			return fmt.Errorf("failed to create category: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// UpdateCategory renames or moves a category. A category cannot be moved
+// under itself or one of its descendants.
+func (s *CategoryService) UpdateCategory(ctx context.Context, categoryID uint, input CategoryUpdate) (*model.Category, error) {
+	var category *model.Category
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if err := tx.Locks.Lock(ctx, categoryTreeLock); err != nil {
+			return fmt.Errorf("failed to lock categories: %w", err)
+		}
+
+		var err error
+		category, err = tx.Categories.FindByID(ctx, categoryID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewNotFoundError("category not found")
+			}
+			return fmt.Errorf("failed to retrieve category: %w", err)
+		}
+
+		if input.Name != nil {
+			name := strings.TrimSpace(*input.Name)
+			if name == "" {
+				return utils.NewBadRequestError("category name cannot be empty")
+			}
+			category.Name = name
+		}
+		if input.Slug != nil {
+			if err := validateSlug(*input.Slug); err != nil {
+				return err
+			}
+			if err := ensureCategorySlugAvailable(ctx, tx.Categories, *input.Slug, category.ID); err != nil {
+				return err
+			}
+			category.Slug = *input.Slug
+		}
+		if input.ParentID != nil {
+			if *input.ParentID == 0 {
+				category.ParentID = nil
+			} else {
+				if err := ensureNotDescendant(ctx, tx.Categories, *input.ParentID, category.ID); err != nil {
+					return err
+				}
+				parentID := *input.ParentID
+				category.ParentID = &parentID
+			}
+		}
+		if input.Name == nil && input.Slug == nil && input.ParentID == nil {
+			return nil
+		}
+
+		if err := tx.Categories.Save(ctx, category); err != nil {
+			return fmt.Errorf("failed to update category: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// DeleteCategory removes a category without subcategories. Its products are
+// left uncategorized.
+func (s *CategoryService) DeleteCategory(ctx context.Context, categoryID uint) error {
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		hasChildren, err := tx.Categories.HasChildren(ctx, categoryID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve categories: %w", err)
+		}
+		if hasChildren {
+			return utils.NewConflictError("move or delete the subcategories first")
+		}
+
+		deleted, err := tx.Categories.Delete(ctx, categoryID)
+		if err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
+		if !deleted {
+			return utils.NewNotFoundError("category not found")
+		}
+		return nil
+	})
+}
+
+// applyTaxonomy sets the category and tags of a product inside a unit of
+// work.
+func applyTaxonomy(ctx context.Context, products repository.ProductRepository, product *model.Product, taxonomy ProductTaxonomy) error {
+	if taxonomy.CategoryID != nil {
+		var categoryID *uint
+		if *taxonomy.CategoryID != 0 {
+			categoryID = taxonomy.CategoryID
+		}
+		if err := products.SetCategory(ctx, product, categoryID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewBadRequestError(fmt.Sprintf("category %d does not exist", *taxonomy.CategoryID))
+			}
+			return fmt.Errorf("failed to set category: %w", err)
+		}
+	}
+
+	if taxonomy.Tags != nil {
+		tags, err := parseTags(taxonomy.Tags)
+		if err != nil {
+			return err
+		}
+		if err := products.SetTags(ctx, product, tags); err != nil {
+			return fmt.Errorf("failed to set tags: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseTags returns the tags with the given names. Names that differ only in
+// case or punctuation are the same tag.
+func parseTags(names []string) ([]model.Tag, error) {
+	seen := make(map[string]bool)
+	tags := []model.Tag{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := slugify(name)
+		if slug == "" || len(slug) > 50 {
+			return nil, utils.NewBadRequestError(fmt.Sprintf("invalid tag %q", name))
+		}
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		tags = append(tags, model.Tag{Name: name, Slug: slug})
+	}
+	if len(tags) > maxProductTags {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("a product can have at most %d tags", maxProductTags))
+	}
+	return tags, nil
+}
+
+// ensureCategorySlugAvailable checks no other category uses the slug.
+// exceptID is the category being updated, or 0.
+func ensureCategorySlugAvailable(ctx context.Context, categories repository.CategoryRepository, slug string, exceptID uint) error {
+	taken, err := categories.SlugTaken(ctx, slug, exceptID)
+	if err != nil {
+		return fmt.Errorf("failed to check slug: %w", err)
+	}
+	if taken {
+		return utils.NewConflictError(fmt.Sprintf("slug %q is already taken", slug))
+	}
+	return nil
+}
+
+// ensureNotDescendant checks parentID exists and is not categoryID or one of
+// its descendants, which would make the tree a cycle.
+func ensureNotDescendant(ctx context.Context, categories repository.CategoryRepository, parentID uint, categoryID uint) error {
+	ancestors, err := categories.Ancestors(ctx, parentID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve categories: %w", err)
+	}
+	if len(ancestors) == 0 {
+		return utils.NewNotFoundError("parent category not found")
+	}
+	for _, id := range ancestors {
+		if id == categoryID {
+			return utils.NewBadRequestError("a category cannot be moved under itself or its subcategories")
+		}
+	}
+	return nil
+}
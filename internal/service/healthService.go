@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"instashop/internal/database"
+)
+
+// HealthCheck probes a dependency the service needs to serve traffic.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of one HealthCheck.
+type CheckResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Readiness reports whether every dependency is up.
+type Readiness struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type HealthService struct {
+	DB      database.Service
+	Checks  []HealthCheck
+	Timeout time.Duration
+}
+
+// NewHealthService returns a service running checks for readiness. Each check
+// gets at most timeout to finish.
+func NewHealthService(db database.Service, timeout time.Duration, checks ...HealthCheck) *HealthService {
+	return &HealthService{DB: db, Checks: checks, Timeout: timeout}
+}
+
+// Ready runs every check concurrently. The service is ready when all of them
+// pass.
+func (s *HealthService) Ready(ctx context.Context) Readiness {
+	results := make([]CheckResult, len(s.Checks))
+	var wg sync.WaitGroup
+	for i, check := range s.Checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			results[i] = CheckResult{Status: "up", LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				results[i].Status, results[i].Error = "down", err.Error()
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	readiness := Readiness{Ready: true, Checks: make(map[string]CheckResult, len(s.Checks))}
+	for i, check := range s.Checks {
+		readiness.Checks[check.Name] = results[i]
+		if results[i].Status != "up" {
+			readiness.Ready = false
+		}
+	}
+	return readiness
+}
+
+// PoolStats returns the database connection pool statistics.
+func (s *HealthService) PoolStats() map[string]string {
+	return s.DB.Health()
+}
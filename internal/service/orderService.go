@@ -4,117 +4,374 @@ import (
 	"context"
 	"errors"
 	"fmt"
-
-	"gorm.io/gorm"
+	"math"
+	"strings"
 
 	"instashop/internal/model"
+	"instashop/internal/repository"
 	"instashop/internal/utils"
 )
 
 type OrderService struct {
-	DB *gorm.DB
+	Repos repository.Repositories
+	Tx    repository.UnitOfWork
+}
+
+func NewOderService(repos repository.Repositories, tx repository.UnitOfWork) *OrderService {
+	return &OrderService{Repos: repos, Tx: tx}
 }
 
-func NewOderService(db *gorm.DB) *OrderService {
-	return &OrderService{DB: db}
+// OrderItemInput is a product and quantity requested when placing an order.
+// VariantID is required for products sold in variants.
+type OrderItemInput struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	VariantID uint `json:"variant_id"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
 }
 
-func (s *OrderService) PlaceOrder(ctx context.Context, order *model.Order) (*model.Order, error) {
+// orderLine identifies a line of an order: a product, or one of its variants.
+type orderLine struct {
+	ProductID uint
+	VariantID uint
+}
+
+// PlaceOrder creates an order for the user from the requested items. Unit
+// prices are snapshotted from the current product or variant prices and the
+// order total is computed server-side.
+func (s *OrderService) PlaceOrder(ctx context.Context, userID uint, items []OrderItemInput) (*model.Order, error) {
 	// Validate the order
-	if len(order.Products) == 0 {
-		utils.NewBadRequestError("order must contain at least one product")
+	if len(items) == 0 {
+		return nil, utils.NewBadRequestError("order must contain at least one product")
+	}
+
+	var order *model.Order
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		order, err = s.placeOrder(ctx, tx, userID, items, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Begin a transaction
-	tx := s.DB.WithContext(ctx).Begin()
+	return order, nil
+}
+
+// priceChangeError is returned by placeOrder when lines are no longer at the
+// price the customer expected. prices holds the current price of those lines.
+type priceChangeError struct {
+	changes []string
+	prices  map[orderLine]float64
+}
+
+func (e *priceChangeError) Error() string {
+	return "prices changed for " + strings.Join(e.changes, ", ")
+}
 
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+// placeOrder does the work of PlaceOrder inside an existing unit of work.
+// When expected is set, every line must still cost its expected unit price,
+// checked against the locked rows; otherwise a *priceChangeError is returned
+// and nothing is written.
+func (s *OrderService) placeOrder(ctx context.Context, tx repository.Repositories, userID uint, items []OrderItemInput, expected map[orderLine]float64) (*model.Order, error) {
+	// Merge duplicate lines so each product or variant appears once
+	quantities := make(map[orderLine]int)
+	var lines []orderLine
+	var productIDs, variantIDs []uint
+	for _, item := range items {
+		if item.Quantity < 1 {
+			return nil, utils.NewBadRequestError("quantity must be at least 1")
+		}
+		line := orderLine{ProductID: item.ProductID, VariantID: item.VariantID}
+		if _, seen := quantities[line]; !seen {
+			lines = append(lines, line)
+			productIDs = append(productIDs, item.ProductID)
+			if item.VariantID != 0 {
+				variantIDs = append(variantIDs, item.VariantID)
+			}
 		}
-	}()
+		quantities[line] += item.Quantity
+	}
 
-	// Save the order
-	if err := tx.Create(order).Error; err != nil {
-		tx.Rollback()
-		return nil, err
+	// Lock the product rows, then the variant rows, so concurrent orders
+	// cannot reserve the same stock. Rows are locked in id order to avoid
+	// deadlocks between overlapping orders.
+	products, err := tx.Products.LockProducts(ctx, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve products: %w", err)
+	}
+
+	productsByID := make(map[uint]model.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
 	}
 
-	// Save the relationship in the many-to-many table
-	for _, product := range order.Products {
-		err := tx.Model(order).Association("Products").Append(&product)
+	variantsByID := make(map[uint]model.ProductVariant)
+	if len(variantIDs) > 0 {
+		variants, err := tx.Products.LockVariants(ctx, variantIDs)
 		if err != nil {
-			tx.Rollback()
-			return nil, err
+			return nil, fmt.Errorf("failed to retrieve variants: %w", err)
+		}
+		for _, variant := range variants {
+			variantsByID[variant.ID] = variant
 		}
 	}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
+	// Products sold in variants must be ordered by variant
+	variantProductIDs, err := tx.Products.ProductIDsWithVariants(ctx, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve variants: %w", err)
+	}
+	hasVariants := make(map[uint]bool, len(variantProductIDs))
+	for _, productID := range variantProductIDs {
+		hasVariants[productID] = true
 	}
-	fmt.Printf("Order: %w", order)
 
-	return order, nil
+	// Build the order lines from the current product and variant prices
+	order := &model.Order{
+		UserID: userID,
+		Status: model.OrderStatusPending,
+	}
+	priceChanges := &priceChangeError{prices: make(map[orderLine]float64)}
+	for _, line := range lines {
+		product, ok := productsByID[line.ProductID]
+		if !ok {
+			return nil, utils.NewNotFoundError(fmt.Sprintf("product %d not found", line.ProductID))
+		}
+
+		if product.Status != model.StatusApproved {
+			return nil, utils.NewBadRequestError(fmt.Sprintf("product %d is not available for purchase", line.ProductID))
+		}
+
+		quantity := quantities[line]
+		item := model.OrderItem{ProductID: product.ID, Quantity: quantity, UnitPrice: product.Price}
+		if line.VariantID == 0 {
+			if hasVariants[product.ID] {
+				return nil, utils.NewBadRequestError(fmt.Sprintf("choose a variant of product %d", product.ID))
+			}
+			if product.Stock < quantity {
+				return nil, utils.NewConflictError(fmt.Sprintf("insufficient stock for product %d", product.ID))
+			}
+		} else {
+			variant, ok := variantsByID[line.VariantID]
+			if !ok || variant.ProductID != product.ID {
+				return nil, utils.NewNotFoundError(fmt.Sprintf("variant %d of product %d not found", line.VariantID, product.ID))
+			}
+			if variant.Stock < quantity {
+				return nil, utils.NewConflictError(fmt.Sprintf("insufficient stock for variant %s", variant.SKU))
+			}
+			variantID := variant.ID
+			item.VariantID = &variantID
+			item.UnitPrice = variant.UnitPrice(&product)
+		}
+
+		if price, ok := expected[line]; ok && price != item.UnitPrice {
+			name := product.Name
+			if line.VariantID != 0 {
+				name = fmt.Sprintf("%s (%s)", product.Name, variantsByID[line.VariantID].SKU)
+			}
+			priceChanges.changes = append(priceChanges.changes, fmt.Sprintf("%s (%.2f -> %.2f)", name, price, item.UnitPrice))
+			priceChanges.prices[line] = item.UnitPrice
+		}
+
+		item.LineTotal = roundMoney(item.UnitPrice * float64(quantity))
+		order.Items = append(order.Items, item)
+		order.Total = roundMoney(order.Total + item.LineTotal)
+	}
+
+	if len(priceChanges.changes) > 0 {
+		return nil, priceChanges
+	}
+
+	// Save the order together with its items
+	if err := tx.Orders.Create(ctx, order); err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	// Reserve the stock for each line
+	for _, item := range order.Items {
+		if err := tx.Products.AdjustStock(ctx, item.ProductID, item.VariantID, -item.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to reserve stock: %w", err)
+		}
+	}
+
+	// Start the order history
+	history := model.OrderStatusHistory{
+		OrderID:   order.ID,
+		ToStatus:  order.Status,
+		ChangedBy: &userID,
+	}
+	if err := tx.Orders.AddHistory(ctx, &history); err != nil {
+		return nil, fmt.Errorf("failed to record order history: %w", err)
+	}
+
+	placed, err := tx.Orders.FindWithItems(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve order: %w", err)
+	}
+
+	return placed, nil
+}
+
+// transitionOrder moves a locked order to the next status, records the change
+// in the order history and releases the reserved stock when the order is
+// declined or canceled. changedBy is nil for system-initiated changes.
+func (s *OrderService) transitionOrder(ctx context.Context, tx repository.Repositories, order *model.Order, next model.OrderStatusType, changedBy *uint) error {
+	if !order.Status.CanTransitionTo(next) {
+		return utils.NewConflictError(fmt.Sprintf("cannot change order status from %s to %s", order.Status, next))
+	}
+
+	previous := order.Status
+	order.Status = next
+	if err := tx.Orders.Save(ctx, order); err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	history := model.OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: &previous,
+		ToStatus:   next,
+		ChangedBy:  changedBy,
+	}
+	if err := tx.Orders.AddHistory(ctx, &history); err != nil {
+		return fmt.Errorf("failed to record order history: %w", err)
+	}
+
+	if next == model.OrderStatusDeclined || next == model.OrderStatusCanceled {
+		return s.releaseStock(ctx, tx, order.ID)
+	}
+	return nil
+}
+
+// releaseStock returns the stock reserved by the order's items.
+func (s *OrderService) releaseStock(ctx context.Context, tx repository.Repositories, orderID uint) error {
+	items, err := tx.Orders.ListItems(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve order items: %w", err)
+	}
+
+	for _, item := range items {
+		if err := tx.Products.AdjustStock(ctx, item.ProductID, item.VariantID, item.Quantity); err != nil {
+			return fmt.Errorf("failed to release stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// roundMoney rounds an amount to two decimal places.
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
 }
 
 // ListOrders retrieves all orders for a specific user
 func (s *OrderService) ListOrders(ctx context.Context, userID uint) ([]model.Order, error) {
-	var orders []model.Order
-	// Preload Products if you need associated products in the response
-	if err := s.DB.WithContext(ctx).
-		Preload("Products").
-		Where("user_id = ?", userID).
-		Find(&orders).Error; err != nil {
+	// The order lines and their products come with the orders for the response
+	orders, err := s.Repos.Orders.List(ctx, repository.OrderFilter{UserID: userID})
+	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve orders: %w", err)
 	}
 	return orders, nil
 }
 
-// CancelOrder cancels an order if it is still in Pending status
+// CancelOrder cancels an order if it is still in Pending status and releases
+// its reserved stock
 func (s *OrderService) CancelOrder(ctx context.Context, orderID uint, userID uint) error {
-	var order model.Order
-	if err := s.DB.WithContext(ctx).First(&order, orderID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("order not found")
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		order, err := lockOrder(ctx, tx.Orders, orderID)
+		if err != nil {
+			return err
+		}
+
+		if order.UserID != userID {
+			return utils.NewForbiddenError("you do not have permission to cancel this order")
+		}
+
+		if order.Status != model.OrderStatusPending {
+			return utils.NewBadRequestError("only pending orders can be canceled")
 		}
-		return fmt.Errorf("failed to retrieve order: %w", err)
+
+		return s.transitionOrder(ctx, tx, order, model.OrderStatusCanceled, &userID)
+	})
+}
+
+// lockOrder loads an order and locks it until the unit of work ends.
+func lockOrder(ctx context.Context, orders repository.OrderRepository, orderID uint) (*model.Order, error) {
+	order, err := orders.FindByIDForUpdate(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("order not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve order: %w", err)
 	}
+	return order, nil
+}
 
-	if order.UserID != userID {
-		return errors.New("you do not have permission to cancel this order")
+// OrderFilter narrows the orders returned by ListAllOrders. Zero values are ignored.
+type OrderFilter = repository.OrderFilter
+
+// ListAllOrders retrieves orders across all users matching the filter
+func (s *OrderService) ListAllOrders(ctx context.Context, filter OrderFilter) ([]model.Order, error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("invalid order status %q", filter.Status))
 	}
 
-	if order.Status != "pending" {
-		utils.NewBadRequestError("only pending orders can be canceled")
+	orders, err := s.Repos.Orders.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve orders: %w", err)
 	}
+	return orders, nil
+}
 
-	order.Status = "canceled"
-	if err := s.DB.WithContext(ctx).Save(&order).Error; err != nil {
-		return fmt.Errorf("failed to cancel order: %w", err)
+// UpdateOrderStatus moves an order to the given status on behalf of changedBy.
+// Only transitions allowed by the order state machine are accepted. Orders are
+// only marked paid by their payment, and paid orders only canceled by
+// refunding it, so neither can be done here.
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uint, status model.OrderStatusType, changedBy uint) (*model.Order, error) {
+	if !status.IsValid() {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("invalid order status %q", status))
 	}
-	return nil
+	if status == model.OrderStatusPaid {
+		return nil, utils.NewBadRequestError("orders are marked paid when their payment succeeds")
+	}
+
+	var order *model.Order
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		order, err = lockOrder(ctx, tx.Orders, orderID)
+		if err != nil {
+			return err
+		}
+
+		if order.Status == model.OrderStatusPaid && status == model.OrderStatusCanceled {
+			return utils.NewConflictError("paid orders can only be canceled by refunding the payment")
+		}
+
+		return s.transitionOrder(ctx, tx, order, status, &changedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
 }
 
-// UpdateOrderStatus updates the status of an order
-func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uint, userID uint, status string) error {
-	var order model.Order
-	if err := s.DB.WithContext(ctx).First(&order, orderID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("order not found")
+// GetOrderHistory retrieves the status history of an order, oldest first.
+// Unless canViewAll is set, only the owner of the order may see it.
+func (s *OrderService) GetOrderHistory(ctx context.Context, orderID uint, userID uint, canViewAll bool) ([]model.OrderStatusHistory, error) {
+	order, err := s.Repos.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("order not found")
 		}
-		return fmt.Errorf("failed to retrieve order: %w", err)
+		return nil, fmt.Errorf("failed to retrieve order: %w", err)
 	}
 
-	// Optional: Check user permissions or admin privileges
-	if order.UserID != userID {
-		return errors.New("you do not have permission to update this order")
+	if !canViewAll && order.UserID != userID {
+		return nil, utils.NewForbiddenError("you do not have permission to view this order")
 	}
 
-	// Update the status
-	order.Status = model.OrderStatusType(status)
-	if err := s.DB.WithContext(ctx).Save(&order).Error; err != nil {
-		return fmt.Errorf("failed to update order status: %w", err)
+	history, err := s.Repos.Orders.History(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve order history: %w", err)
 	}
-	return nil
+	return history, nil
 }
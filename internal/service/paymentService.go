@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/payment"
+	"instashop/internal/repository"
+	"instashop/internal/utils"
+)
+
+type PaymentService struct {
+	Repos        repository.Repositories
+	Tx           repository.UnitOfWork
+	Provider     payment.PaymentProvider
+	OrderService *OrderService
+	Currency     string
+	CallbackURL  string
+}
+
+func NewPaymentService(repos repository.Repositories, tx repository.UnitOfWork, provider payment.PaymentProvider, orderService *OrderService, currency, callbackURL string) *PaymentService {
+	return &PaymentService{
+		Repos:        repos,
+		Tx:           tx,
+		Provider:     provider,
+		OrderService: orderService,
+		Currency:     currency,
+		CallbackURL:  callbackURL,
+	}
+}
+
+// InitializePayment starts a payment for the user's order. If a pending
+// payment already exists for the order it is returned instead.
+func (s *PaymentService) InitializePayment(ctx context.Context, orderID uint, userID uint) (*model.Payment, error) {
+	order, err := s.Repos.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("order not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve order: %w", err)
+	}
+
+	if order.UserID != userID {
+		return nil, utils.NewForbiddenError("you do not have permission to pay for this order")
+	}
+
+	if !order.Status.CanTransitionTo(model.OrderStatusPaid) {
+		return nil, utils.NewConflictError(fmt.Sprintf("a %s order cannot be paid", order.Status))
+	}
+
+	existing, err := s.Repos.Payments.FindPending(ctx, order.ID, order.Total)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("failed to retrieve payment: %w", err)
+	}
+
+	user, err := s.Repos.Users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+
+	reference, err := newPaymentReference(order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err := s.Provider.InitializeCharge(ctx, payment.ChargeRequest{
+		Reference:   reference,
+		Email:       user.Email,
+		Amount:      toMinorUnits(order.Total),
+		Currency:    s.Currency,
+		CallbackURL: s.CallbackURL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize payment: %w", err)
+	}
+
+	p := &model.Payment{
+		OrderID:          order.ID,
+		Provider:         s.Provider.Name(),
+		Reference:        reference,
+		Amount:           order.Total,
+		Currency:         s.Currency,
+		Status:           model.PaymentStatusPending,
+		AuthorizationURL: charge.AuthorizationURL,
+	}
+	if err := s.Repos.Payments.Create(ctx, p); err != nil {
+		return nil, fmt.Errorf("failed to save payment: %w", err)
+	}
+
+	return p, nil
+}
+
+// VerifyPayment asks the provider for the state of one of the user's payments
+// and applies it, for clients returning from the payment page before the
+// webhook arrives.
+func (s *PaymentService) VerifyPayment(ctx context.Context, reference string, userID uint) (*model.Payment, error) {
+	_, err := s.Repos.Payments.FindByReferenceForUser(ctx, reference, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("payment not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve payment: %w", err)
+	}
+
+	result, err := s.Provider.VerifyCharge(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify payment: %w", err)
+	}
+
+	return s.applyChargeResult(ctx, result)
+}
+
+// HandleWebhook verifies and applies a provider webhook. Repeated deliveries
+// of the same event are harmless, and events that do not settle a charge are
+// acknowledged without doing anything.
+func (s *PaymentService) HandleWebhook(ctx context.Context, payload []byte, header http.Header) error {
+	result, err := s.Provider.ParseWebhook(payload, header)
+	if err != nil {
+		if errors.Is(err, payment.ErrInvalidSignature) {
+			return utils.NewUnauthorizedError("invalid webhook signature")
+		}
+		return utils.NewBadRequestError(err.Error())
+	}
+	if result == nil {
+		return nil
+	}
+
+	_, err = s.applyChargeResult(ctx, result)
+	return err
+}
+
+// RefundPayment refunds a successful payment in full and cancels its order,
+// releasing the reserved stock.
+func (s *PaymentService) RefundPayment(ctx context.Context, reference string, refundedBy uint) (*model.Payment, error) {
+	var p *model.Payment
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		p, err = tx.Payments.FindByReferenceForUpdate(ctx, reference)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewNotFoundError("payment not found")
+			}
+			return fmt.Errorf("failed to retrieve payment: %w", err)
+		}
+
+		if p.Status != model.PaymentStatusSucceeded {
+			return utils.NewConflictError("only successful payments can be refunded")
+		}
+
+		order, err := tx.Orders.FindByIDForUpdate(ctx, p.OrderID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve order: %w", err)
+		}
+		if err := s.OrderService.transitionOrder(ctx, tx, order, model.OrderStatusCanceled, &refundedBy); err != nil {
+			return err
+		}
+
+		p.Status = model.PaymentStatusRefunded
+		if err := tx.Payments.Save(ctx, p); err != nil {
+			return fmt.Errorf("failed to update payment: %w", err)
+		}
+
+		// Refund last so a provider failure rolls the local changes back
+		if err := s.Provider.Refund(ctx, p.Reference, toMinorUnits(p.Amount)); err != nil {
+			return fmt.Errorf("failed to refund payment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// applyChargeResult records the outcome of a charge and moves the order to
+// paid on success. A successful charge for the wrong amount or currency fails
+// the payment instead. Payments that are no longer pending are left unchanged.
+func (s *PaymentService) applyChargeResult(ctx context.Context, result *payment.ChargeResult) (*model.Payment, error) {
+	var p *model.Payment
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		p, err = tx.Payments.FindByReferenceForUpdate(ctx, result.Reference)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewNotFoundError("payment not found")
+			}
+			return fmt.Errorf("failed to retrieve payment: %w", err)
+		}
+
+		if p.Status != model.PaymentStatusPending {
+			return nil
+		}
+
+		switch result.Status {
+		case payment.ChargeStatusFailed:
+			p.Status = model.PaymentStatusFailed
+		case payment.ChargeStatusSuccess:
+			if result.Amount != toMinorUnits(p.Amount) || (result.Currency != "" && result.Currency != p.Currency) {
+				// The charge is settled, so reporting an error would only make
+				// the provider retry it; fail the payment for review instead
+				log.Printf("payment %s charged %d %s, want %d %s", p.Reference, result.Amount, result.Currency, toMinorUnits(p.Amount), p.Currency)
+				p.Status = model.PaymentStatusFailed
+				break
+			}
+
+			now := time.Now()
+			p.Status = model.PaymentStatusSucceeded
+			p.PaidAt = &now
+
+			order, err := tx.Orders.FindByIDForUpdate(ctx, p.OrderID)
+			if err != nil {
+				return fmt.Errorf("failed to retrieve order: %w", err)
+			}
+			if order.Status.CanTransitionTo(model.OrderStatusPaid) {
+				if err := s.OrderService.transitionOrder(ctx, tx, order, model.OrderStatusPaid, nil); err != nil {
+					return err
+				}
+			} else {
+				// The money arrived after the order was closed; keep the
+				// payment so it can be refunded.
+				log.Printf("payment %s succeeded for %s order %d", p.Reference, order.Status, order.ID)
+			}
+		default:
+			return nil
+		}
+
+		if err := tx.Payments.Save(ctx, p); err != nil {
+			return fmt.Errorf("failed to update payment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// newPaymentReference returns a unique reference for a payment of the order.
+func newPaymentReference(orderID uint) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate payment reference: %w", err)
+	}
+	return fmt.Sprintf("order_%d_%s", orderID, hex.EncodeToString(b)), nil
+}
+
+// toMinorUnits converts an amount to the currency's minor unit.
+func toMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"sort"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/storage"
+	"instashop/internal/utils"
+)
+
+// MaxProductImages is the most images a product can have
+const MaxProductImages = 10
+
+type ProductImageService struct {
+	Repos   repository.Repositories
+	Tx      repository.UnitOfWork
+	Storage storage.BlobStorage
+}
+
+func NewProductImageService(repos repository.Repositories, tx repository.UnitOfWork, blobStorage storage.BlobStorage) *ProductImageService {
+	return &ProductImageService{Repos: repos, Tx: tx, Storage: blobStorage}
+}
+
+// AddImages uploads images for a product the user's store sells and appends
+// them after the existing images. The first image of a product becomes its
+// primary image.
+func (s *ProductImageService) AddImages(ctx context.Context, productID uint, userID uint, files []*multipart.FileHeader) ([]model.ProductImage, error) {
+	if len(files) == 0 {
+		return nil, utils.NewBadRequestError("at least one image is required")
+	}
+
+	product, err := storeProduct(ctx, s.Repos, productID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.Repos.Images.List(ctx, product.ID)
+	if err != nil {
+		return nil, fmt.Errorf("internal server error: %w", err)
+	}
+	if len(existing)+len(files) > MaxProductImages {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("a product can have at most %d images", MaxProductImages))
+	}
+
+	// Upload before opening the unit of work so slow storage does not hold
+	// the product lock
+	folder := fmt.Sprintf("products/%d", product.ID)
+	uploaded := make([]*utils.UploadedImage, 0, len(files))
+	for _, file := range files {
+		image, err := utils.UploadImage(ctx, s.Storage, folder, file)
+		if err != nil {
+			s.deleteBlobs(uploaded)
+			return nil, err
+		}
+		uploaded = append(uploaded, image)
+	}
+
+	var images []model.ProductImage
+	err = s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Lock the product so concurrent uploads get distinct positions
+		if _, err := lockProduct(ctx, tx.Products, product.ID); err != nil {
+			return err
+		}
+
+		existing, err := tx.Images.List(ctx, product.ID)
+		if err != nil {
+			return fmt.Errorf("internal server error: %w", err)
+		}
+		if len(existing)+len(uploaded) > MaxProductImages {
+			return utils.NewBadRequestError(fmt.Sprintf("a product can have at most %d images", MaxProductImages))
+		}
+
+		position, hasPrimary := 0, false
+		for _, image := range existing {
+			if image.Position >= position {
+				position = image.Position + 1
+			}
+			hasPrimary = hasPrimary || image.IsPrimary
+		}
+
+		for i, upload := range uploaded {
+			images = append(images, model.ProductImage{
+				ProductID:   product.ID,
+				URL:         upload.URL,
+				StorageKey:  upload.Key,
+				ContentType: upload.ContentType,
+				Size:        upload.Size,
+				Position:    position + i,
+				IsPrimary:   !hasPrimary && i == 0,
+			})
+		}
+		if err := tx.Images.Create(ctx, images); err != nil {
+			return fmt.Errorf("failed to save images: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.deleteBlobs(uploaded)
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// ListImages returns the images of a product in display order
+func (s *ProductImageService) ListImages(ctx context.Context, productID uint) ([]model.ProductImage, error) {
+	if _, err := s.Repos.Products.FindByID(ctx, productID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("product not found")
+		}
+		return nil, fmt.Errorf("internal server error: %w", err)
+	}
+
+	images, err := s.Repos.Images.List(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("internal server error: %w", err)
+	}
+	return images, nil
+}
+
+// ReorderImages sets the display order of a product's images. imageIDs must
+// list every image of the product exactly once.
+func (s *ProductImageService) ReorderImages(ctx context.Context, productID uint, userID uint, imageIDs []uint) ([]model.ProductImage, error) {
+	var images []model.ProductImage
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := lockStoreProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+
+		var err error
+		images, err = tx.Images.List(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("internal server error: %w", err)
+		}
+
+		positions := make(map[uint]int, len(imageIDs))
+		for i, id := range imageIDs {
+			if _, seen := positions[id]; seen {
+				return utils.NewBadRequestError(fmt.Sprintf("image %d is listed more than once", id))
+			}
+			positions[id] = i
+		}
+		if len(positions) != len(images) {
+			return utils.NewBadRequestError("the new order must list every image of the product")
+		}
+
+		for i := range images {
+			position, ok := positions[images[i].ID]
+			if !ok {
+				return utils.NewBadRequestError("the new order must list every image of the product")
+			}
+			images[i].Position = position
+			if err := tx.Images.SetPosition(ctx, images[i].ID, position); err != nil {
+				return fmt.Errorf("failed to reorder images: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortImages(images)
+	return images, nil
+}
+
+// SetPrimaryImage makes an image the primary image of its product
+func (s *ProductImageService) SetPrimaryImage(ctx context.Context, productID uint, imageID uint, userID uint) (*model.ProductImage, error) {
+	var image *model.ProductImage
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := lockStoreProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+
+		var err error
+		image, err = findImage(ctx, tx.Images, productID, imageID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Images.SetPrimary(ctx, image); err != nil {
+			return fmt.Errorf("failed to update images: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// DeleteImage removes an image from a product. When the primary image is
+// removed the next image in display order takes its place.
+func (s *ProductImageService) DeleteImage(ctx context.Context, productID uint, imageID uint, userID uint) error {
+	var image *model.ProductImage
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := lockStoreProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+
+		var err error
+		image, err = findImage(ctx, tx.Images, productID, imageID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Images.Delete(ctx, image.ID); err != nil {
+			return fmt.Errorf("failed to delete image: %w", err)
+		}
+
+		if !image.IsPrimary {
+			return nil
+		}
+		remaining, err := tx.Images.List(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("internal server error: %w", err)
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+		if err := tx.Images.SetPrimary(ctx, &remaining[0]); err != nil {
+			return fmt.Errorf("failed to update images: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The row is gone, so a blob left behind is only wasted space
+	if err := s.Storage.Delete(ctx, image.StorageKey); err != nil {
+		log.Printf("Could not delete image %s: %v", image.StorageKey, err)
+	}
+	return nil
+}
+
+// findImage returns an image of the product.
+func findImage(ctx context.Context, images repository.ImageRepository, productID, imageID uint) (*model.ProductImage, error) {
+	image, err := images.Find(ctx, productID, imageID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("image not found")
+		}
+		return nil, fmt.Errorf("internal server error: %w", err)
+	}
+	return image, nil
+}
+
+// deleteBlobs removes uploads whose rows could not be saved
+func (s *ProductImageService) deleteBlobs(uploaded []*utils.UploadedImage) {
+	for _, image := range uploaded {
+		if err := s.Storage.Delete(context.Background(), image.Key); err != nil {
+			log.Printf("Could not delete image %s: %v", image.Key, err)
+		}
+	}
+}
+
+func sortImages(images []model.ProductImage) {
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Position != images[j].Position {
+			return images[i].Position < images[j].Position
+		}
+		return images[i].ID < images[j].ID
+	})
+}
@@ -4,98 +4,276 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
-	"gorm.io/gorm"
-
+	"instashop/internal/mailer"
 	"instashop/internal/model"
+	"instashop/internal/repository"
 	"instashop/internal/utils"
 )
 
 type ProductService struct {
-	DB *gorm.DB
+	Repos repository.Repositories
+	Tx    repository.UnitOfWork
 }
 
-func NewProductService(db *gorm.DB) *ProductService {
-	return &ProductService{DB: db}
+func NewProductService(repos repository.Repositories, tx repository.UnitOfWork) *ProductService {
+	return &ProductService{Repos: repos, Tx: tx}
 }
 
-func (s *ProductService) CreateProduct(ctx context.Context, userID uint, name, description string, price float64) (*model.Product, error) {
+// CreateProduct adds a product to a store the user owns or works at. With a
+// zero storeID the product goes to the user's first own store.
+func (s *ProductService) CreateProduct(ctx context.Context, userID uint, storeID uint, name, description string, price float64, stock int, taxonomy ProductTaxonomy) (*model.Product, error) {
+	if stock < 0 {
+		return nil, utils.NewBadRequestError("stock cannot be negative")
+	}
+
+	if storeID == 0 {
+		store, err := s.Repos.Stores.FirstOwnedBy(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, utils.NewBadRequestError("create a store before adding products")
+			}
+			return nil, fmt.Errorf("failed to retrieve store: %w", err)
+		}
+		storeID = store.ID
+	} else if _, err := checkStoreMember(ctx, s.Repos.Stores, storeID, userID); err != nil {
+		return nil, err
+	}
+
 	// Create a new Product instance
 	product := &model.Product{
 		UserID:      userID,
+		StoreID:     storeID,
 		Name:        name,
 		Description: description,
 		Price:       price,
+		Stock:       stock,
 	}
 
-	// Save the product to the database
-	if err := s.DB.WithContext(ctx).Create(product).Error; err != nil {
-		return nil, fmt.Errorf("failed to create product: %w", err)
+	// Save the product to the database together with its category and tags
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if err := tx.Products.Create(ctx, product); err != nil {
+			return fmt.Errorf("failed to create product: %w", err)
+		}
+		return applyTaxonomy(ctx, tx.Products, product, taxonomy)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return product, nil
 }
 
+// GetProduct returns a product of a store the user owns or works at, with
+// its details.
 func (s *ProductService) GetProduct(ctx context.Context, productID uint, userID uint) (*model.Product, error) {
-	var product model.Product
-
-	// Fetch the product by productID and UserID
-	if err := s.DB.WithContext(ctx).Where("id = ? AND user_id = ?", productID, userID).First(&product).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	product, err := s.Repos.Products.FindWithDetails(ctx, productID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return nil, utils.NewNotFoundError("product not found")
 		}
 		return nil, fmt.Errorf("internal server error: %w", err)
 	}
+	if _, err := checkStoreMember(ctx, s.Repos.Stores, product.StoreID, userID); err != nil {
+		return nil, err
+	}
 
-	return &product, nil
+	return product, nil
 }
 
 func (s *ProductService) GetAllProductsByUserID(ctx context.Context, userID uint) ([]model.Product, error) {
-	var products []model.Product
-
-	if err := s.DB.WithContext(ctx).Where("user_id = ?", userID).Find(&products).Error; err != nil {
+	products, err := s.Repos.Products.ListByUser(ctx, userID)
+	if err != nil {
 		return nil, fmt.Errorf("internal server error: %w", err)
 	}
 
 	return products, nil
 }
 
-func (s *ProductService) UpdateProduct(ctx context.Context, productID uint, updatedProduct model.Product) (*model.Product, error) {
-	var product model.Product
+// UpdateProduct changes the name and price of a product of a store the user
+// owns or works at, and its category and tags as described by taxonomy.
+func (s *ProductService) UpdateProduct(ctx context.Context, productID uint, userID uint, updatedProduct model.Product, taxonomy ProductTaxonomy) (*model.Product, error) {
+	var product *model.Product
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Fetch the existing product
+		var err error
+		product, err = lockStoreProduct(ctx, tx, productID, userID)
+		if err != nil {
+			return err
+		}
+
+		// Update the product fields
+		product.Name = updatedProduct.Name
+		product.Price = updatedProduct.Price
 
-	// Fetch the existing product
-	if err := s.DB.WithContext(ctx).First(&product, productID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, utils.NewNotFoundError("product not found")
+		// Save the updated product
+		if err := tx.Products.Save(ctx, product); err != nil {
+			return fmt.Errorf("failed to update product: %w", err)
 		}
-		return nil, fmt.Errorf("internal server error: %w", err)
+		return applyTaxonomy(ctx, tx.Products, product, taxonomy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// UpdateStock sets the available stock of a product of a store the user owns
+// or works at
+func (s *ProductService) UpdateStock(ctx context.Context, productID uint, userID uint, stock int) (*model.Product, error) {
+	if stock < 0 {
+		return nil, utils.NewBadRequestError("stock cannot be negative")
 	}
 
-	// Update the product fields
-	product.Name = updatedProduct.Name
-	product.Price = updatedProduct.Price
+	var product *model.Product
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Lock the row so the update does not race with orders reserving stock
+		var err error
+		product, err = lockStoreProduct(ctx, tx, productID, userID)
+		if err != nil {
+			return err
+		}
 
-	// Save the updated product
-	if err := s.DB.WithContext(ctx).Save(&product).Error; err != nil {
-		return nil, fmt.Errorf("failed to update product: %w", err)
+		product.Stock = stock
+		if err := tx.Products.Save(ctx, product); err != nil {
+			return fmt.Errorf("failed to update stock: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &product, nil
+	return product, nil
 }
 
+// DeletePendingProduct deletes a product of a store the user owns or works at
+// while it is still waiting for review
 func (s *ProductService) DeletePendingProduct(ctx context.Context, productID uint, userID uint) error {
-	// Delete the product with the specified productID and userID where status is "pending"
-	result := s.DB.WithContext(ctx).Where("id = ? AND user_id = ? AND status = ?", productID, userID, "pending").Delete(&model.Product{})
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := lockStoreProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+
+		deleted, err := tx.Products.DeletePending(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("failed to delete product: %w", err)
+		}
+		if !deleted {
+			return utils.NewNotFoundError("no pending product found for the given productID")
+		}
+		return nil
+	})
+}
+
+// ListPendingProducts returns the moderation queue, oldest first
+func (s *ProductService) ListPendingProducts(ctx context.Context) ([]model.Product, error) {
+	products, err := s.Repos.Products.ListByStatus(ctx, model.StatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("internal server error: %w", err)
+	}
+	return products, nil
+}
+
+// ReviewProduct approves or declines a pending product and emails the seller
+// the outcome. A reason is required when declining.
+func (s *ProductService) ReviewProduct(ctx context.Context, productID uint, reviewerID uint, status model.StatusType, reason string) (*model.Product, error) {
+	if status != model.StatusApproved && status != model.StatusDeclined {
+		return nil, utils.NewBadRequestError("products can only be approved or declined")
+	}
+	reason = strings.TrimSpace(reason)
+	if status == model.StatusDeclined && reason == "" {
+		return nil, utils.NewBadRequestError("a reason is required when declining a product")
+	}
+
+	var product *model.Product
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		product, err = lockProduct(ctx, tx.Products, productID)
+		if err != nil {
+			return err
+		}
+
+		if product.Status != model.StatusPending {
+			return utils.NewConflictError("only pending products can be reviewed")
+		}
+
+		now := time.Now()
+		product.Status = status
+		product.DeclineReason = reason
+		product.ReviewedBy = &reviewerID
+		product.ReviewedAt = &now
+		if err := tx.Products.Save(ctx, product); err != nil {
+			return fmt.Errorf("failed to review product: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seller, err := s.Repos.Users.FindByID(ctx, product.UserID)
+	if err != nil {
+		log.Printf("Could not find seller %d to notify: %v", product.UserID, err)
+		return product, nil
+	}
+
+	// Notify the seller
+	if err := s.Repos.Emails.Send(ctx, mailer.Email{
+		To:       seller.Email,
+		Template: mailer.TemplateProductReviewed,
+		Data: mailer.ProductReviewedData{
+			ProductName: product.Name,
+			Approved:    product.Status == model.StatusApproved,
+			Reason:      product.DeclineReason,
+		},
+	}); err != nil {
+		log.Printf("Could not queue review email for product %d: %v", product.ID, err)
+	}
+
+	return product, nil
+}
 
-	// Check for errors
-	if result.Error != nil {
-		utils.NewBadRequestError("failed to delete product: %w")
+// lockProduct loads a product and locks it until the unit of work ends.
+func lockProduct(ctx context.Context, products repository.ProductRepository, productID uint) (*model.Product, error) {
+	product, err := products.FindByIDForUpdate(ctx, productID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("product not found")
+		}
+		return nil, fmt.Errorf("internal server error: %w", err)
 	}
+	return product, nil
+}
 
-	// Check if any rows were affected
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no pending product found for the given productID and userID")
+// lockStoreProduct is lockProduct for a product of a store the user owns or
+// works at.
+func lockStoreProduct(ctx context.Context, tx repository.Repositories, productID uint, userID uint) (*model.Product, error) {
+	product, err := lockProduct(ctx, tx.Products, productID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkStoreMember(ctx, tx.Stores, product.StoreID, userID); err != nil {
+		return nil, err
 	}
+	return product, nil
+}
 
-	return nil
+// storeProduct is lockStoreProduct without the lock.
+func storeProduct(ctx context.Context, repos repository.Repositories, productID uint, userID uint) (*model.Product, error) {
+	product, err := repos.Products.FindByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("product not found")
+		}
+		return nil, fmt.Errorf("internal server error: %w", err)
+	}
+	if _, err := checkStoreMember(ctx, repos.Stores, product.StoreID, userID); err != nil {
+		return nil, err
+	}
+	return product, nil
 }
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/utils"
+)
+
+type ProductVariantService struct {
+	Repos repository.Repositories
+	Tx    repository.UnitOfWork
+}
+
+func NewProductVariantService(repos repository.Repositories, tx repository.UnitOfWork) *ProductVariantService {
+	return &ProductVariantService{Repos: repos, Tx: tx}
+}
+
+// OptionInput is an option type and its values, in display order.
+type OptionInput struct {
+	Name   string   `json:"name" binding:"required,max=50"`
+	Values []string `json:"values" binding:"required,min=1,max=50,dive,required,max=50"`
+}
+
+// VariantInput describes a new variant. Options maps each option name of the
+// product to the chosen value, e.g. {"Size": "M", "Colour": "Red"}. A nil
+// Price sells the variant at the product price.
+type VariantInput struct {
+	SKU     string            `json:"sku" binding:"required,max=64"`
+	Price   *float64          `json:"price" binding:"omitnil,price"`
+	Stock   int               `json:"stock" binding:"min=0"`
+	Options map[string]string `json:"options" binding:"required"`
+}
+
+// VariantUpdate holds the fields of a variant to change. Nil fields are left
+// unchanged.
+type VariantUpdate struct {
+	SKU   *string  `json:"sku" binding:"omitnil,min=1,max=64"`
+	Price *float64 `json:"price" binding:"omitnil,price"`
+	Stock *int     `json:"stock" binding:"omitnil,min=0"`
+}
+
+// AddOption adds an option type to a product. Options can only change while
+// the product has no variants, since every variant picks a value of every
+// option.
+func (s *ProductVariantService) AddOption(ctx context.Context, productID uint, userID uint, input OptionInput) (*model.ProductOption, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, utils.NewBadRequestError("option name is required")
+	}
+	values, err := optionValues(input.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	var option model.ProductOption
+	err = s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := lockStoreProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+		if err := ensureNoVariants(ctx, tx.Products, productID); err != nil {
+			return err
+		}
+
+		options, err := tx.Variants.ListOptions(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("internal server error: %w", err)
+		}
+		position := 0
+		for _, existing := range options {
+			if strings.EqualFold(existing.Name, name) {
+				return utils.NewConflictError(fmt.Sprintf("the product already has a %q option", existing.Name))
+			}
+			if existing.Position >= position {
+				position = existing.Position + 1
+			}
+		}
+
+		option = model.ProductOption{ProductID: productID, Name: name, Position: position}
+		for i, value := range values {
+			option.Values = append(option.Values, model.ProductOptionValue{Value: value, Position: i})
+		}
+		if err := tx.Variants.CreateOption(ctx, &option); err != nil {
+			return fmt.Errorf("failed to create option: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &option, nil
+}
+
+// AddOptionValue adds a value to an existing option, e.g. a new size. Unlike
+// adding an option this is allowed while the product has variants.
+func (s *ProductVariantService) AddOptionValue(ctx context.Context, productID uint, optionID uint, userID uint, value string) (*model.ProductOptionValue, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, utils.NewBadRequestError("option value is required")
+	}
+
+	var optionValue model.ProductOptionValue
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := lockStoreProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+
+		option, err := tx.Variants.FindOption(ctx, productID, optionID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewNotFoundError("option not found")
+			}
+			return fmt.Errorf("internal server error: %w", err)
+		}
+
+		position := 0
+		for _, existing := range option.Values {
+			if strings.EqualFold(existing.Value, value) {
+				return utils.NewConflictError(fmt.Sprintf("the %s option already has the value %q", option.Name, existing.Value))
+			}
+			if existing.Position >= position {
+				position = existing.Position + 1
+			}
+		}
+
+		optionValue = model.ProductOptionValue{OptionID: option.ID, Value: value, Position: position}
+		if err := tx.Variants.CreateOptionValue(ctx, &optionValue); err != nil {
+			return fmt.Errorf("failed to create option value: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &optionValue, nil
+}
+
+// DeleteOption removes an option type and its values from a product that has
+// no variants.
+func (s *ProductVariantService) DeleteOption(ctx context.Context, productID uint, optionID uint, userID uint) error {
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := lockStoreProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+		if err := ensureNoVariants(ctx, tx.Products, productID); err != nil {
+			return err
+		}
+
+		deleted, err := tx.Variants.DeleteOption(ctx, productID, optionID)
+		if err != nil {
+			return fmt.Errorf("failed to delete option: %w", err)
+		}
+		if !deleted {
+			return utils.NewNotFoundError("option not found")
+		}
+		return nil
+	})
+}
+
+// CreateVariant adds a variant to a product. The variant must pick one value
+// of every option of the product, and no other variant may have the same
+// combination.
+func (s *ProductVariantService) CreateVariant(ctx context.Context, productID uint, userID uint, input VariantInput) (*model.ProductVariant, error) {
+	sku := strings.TrimSpace(input.SKU)
+	if sku == "" {
+		return nil, utils.NewBadRequestError("sku is required")
+	}
+	if input.Price != nil && *input.Price < 0 {
+		return nil, utils.NewBadRequestError("price cannot be negative")
+	}
+	if input.Stock < 0 {
+		return nil, utils.NewBadRequestError("stock cannot be negative")
+	}
+
+	var variant model.ProductVariant
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := lockStoreProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+
+		options, err := tx.Variants.ListOptions(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("internal server error: %w", err)
+		}
+		if len(options) == 0 {
+			return utils.NewBadRequestError("add options to the product before creating variants")
+		}
+
+		values, err := chooseOptionValues(options, input.Options)
+		if err != nil {
+			return err
+		}
+
+		variants, err := tx.Variants.List(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("internal server error: %w", err)
+		}
+		key := variantKey(values)
+		for _, existing := range variants {
+			if variantKey(existing.Values) == key {
+				return utils.NewConflictError(fmt.Sprintf("variant %s already has these options", existing.SKU))
+			}
+		}
+
+		if err := ensureSKUAvailable(ctx, tx.Variants, sku, 0); err != nil {
+			return err
+		}
+
+		variant = model.ProductVariant{
+			ProductID: productID,
+			SKU:       sku,
+			Price:     input.Price,
+			Stock:     input.Stock,
+			Values:    values,
+		}
+		if err := tx.Variants.Create(ctx, &variant); err != nil {
+			return fmt.Errorf("failed to create variant: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &variant, nil
+}
+
+// UpdateVariant changes the SKU, price or stock of a variant
+func (s *ProductVariantService) UpdateVariant(ctx context.Context, productID uint, variantID uint, userID uint, input VariantUpdate) (*model.ProductVariant, error) {
+	if input.Price != nil && *input.Price < 0 {
+		return nil, utils.NewBadRequestError("price cannot be negative")
+	}
+	if input.Stock != nil && *input.Stock < 0 {
+		return nil, utils.NewBadRequestError("stock cannot be negative")
+	}
+
+	var variant *model.ProductVariant
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := storeProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+
+		// Lock the row so the update does not race with orders reserving stock
+		var err error
+		variant, err = lockVariant(ctx, tx.Variants, productID, variantID)
+		if err != nil {
+			return err
+		}
+
+		if input.SKU != nil {
+			sku := strings.TrimSpace(*input.SKU)
+			if sku == "" {
+				return utils.NewBadRequestError("sku cannot be empty")
+			}
+			if err := ensureSKUAvailable(ctx, tx.Variants, sku, variant.ID); err != nil {
+				return err
+			}
+			variant.SKU = sku
+		}
+		if input.Price != nil {
+			variant.Price = input.Price
+		}
+		if input.Stock != nil {
+			variant.Stock = *input.Stock
+		}
+		if input.SKU == nil && input.Price == nil && input.Stock == nil {
+			return nil
+		}
+
+		if err := tx.Variants.Save(ctx, variant); err != nil {
+			return fmt.Errorf("failed to update variant: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	variant.Values, err = s.Repos.Variants.Values(ctx, variant.ID)
+	if err != nil {
+		return nil, fmt.Errorf("internal server error: %w", err)
+	}
+	return variant, nil
+}
+
+// DeleteVariant removes a variant that has never been ordered. Variants with
+// orders should be taken out of sale by setting their stock to zero.
+func (s *ProductVariantService) DeleteVariant(ctx context.Context, productID uint, variantID uint, userID uint) error {
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := storeProduct(ctx, tx, productID, userID); err != nil {
+			return err
+		}
+
+		variant, err := lockVariant(ctx, tx.Variants, productID, variantID)
+		if err != nil {
+			return err
+		}
+
+		ordered, err := tx.Variants.Ordered(ctx, variant.ID)
+		if err != nil {
+			return fmt.Errorf("internal server error: %w", err)
+		}
+		if ordered {
+			return utils.NewConflictError("this variant has been ordered; set its stock to 0 instead")
+		}
+
+		if err := tx.Variants.Delete(ctx, variant.ID); err != nil {
+			return fmt.Errorf("failed to delete variant: %w", err)
+		}
+		return nil
+	})
+}
+
+// optionValues trims the values of a new option and rejects blanks and
+// duplicates.
+func optionValues(input []string) ([]string, error) {
+	if len(input) == 0 {
+		return nil, utils.NewBadRequestError("an option needs at least one value")
+	}
+
+	seen := make(map[string]bool, len(input))
+	values := make([]string, 0, len(input))
+	for _, value := range input {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, utils.NewBadRequestError("option values cannot be empty")
+		}
+		if seen[strings.ToLower(value)] {
+			return nil, utils.NewBadRequestError(fmt.Sprintf("option value %q is listed more than once", value))
+		}
+		seen[strings.ToLower(value)] = true
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// chooseOptionValues resolves the option names and values chosen for a
+// variant, requiring exactly one value for every option.
+func chooseOptionValues(options []model.ProductOption, chosen map[string]string) ([]model.ProductOptionValue, error) {
+	if len(chosen) != len(options) {
+		return nil, utils.NewBadRequestError("a variant must choose one value for every option of the product")
+	}
+
+	values := make([]model.ProductOptionValue, 0, len(options))
+	for _, option := range options {
+		var choice string
+		found := false
+		for name, value := range chosen {
+			if strings.EqualFold(name, option.Name) {
+				choice, found = value, true
+				break
+			}
+		}
+		if !found {
+			return nil, utils.NewBadRequestError(fmt.Sprintf("a value for the %s option is required", option.Name))
+		}
+
+		matched := false
+		for _, value := range option.Values {
+			if strings.EqualFold(value.Value, strings.TrimSpace(choice)) {
+				values = append(values, value)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, utils.NewBadRequestError(fmt.Sprintf("%q is not a value of the %s option", choice, option.Name))
+		}
+	}
+	return values, nil
+}
+
+// variantKey identifies a combination of option values regardless of order.
+func variantKey(values []model.ProductOptionValue) string {
+	ids := make([]int, 0, len(values))
+	for _, value := range values {
+		ids = append(ids, int(value.ID))
+	}
+	sort.Ints(ids)
+	return fmt.Sprint(ids)
+}
+
+// lockVariant loads a variant of the product and locks it until the unit of
+// work ends.
+func lockVariant(ctx context.Context, variants repository.VariantRepository, productID, variantID uint) (*model.ProductVariant, error) {
+	variant, err := variants.FindForUpdate(ctx, productID, variantID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("variant not found")
+		}
+		return nil, fmt.Errorf("internal server error: %w", err)
+	}
+	return variant, nil
+}
+
+// ensureNoVariants refuses changes to a product's options once it has
+// variants.
+func ensureNoVariants(ctx context.Context, products repository.ProductRepository, productID uint) error {
+	ids, err := products.ProductIDsWithVariants(ctx, []uint{productID})
+	if err != nil {
+		return fmt.Errorf("internal server error: %w", err)
+	}
+	if len(ids) > 0 {
+		return utils.NewConflictError("delete the product's variants before changing its options")
+	}
+	return nil
+}
+
+// ensureSKUAvailable checks no other variant uses the SKU. exceptID is the
+// variant being updated, or 0.
+func ensureSKUAvailable(ctx context.Context, variants repository.VariantRepository, sku string, exceptID uint) error {
+	taken, err := variants.SKUTaken(ctx, sku, exceptID)
+	if err != nil {
+		return fmt.Errorf("internal server error: %w", err)
+	}
+	if taken {
+		return utils.NewConflictError(fmt.Sprintf("sku %q is already in use", sku))
+	}
+	return nil
+}
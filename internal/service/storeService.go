@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"strings"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/storage"
+	"instashop/internal/utils"
+	"instashop/internal/validation"
+)
+
+const maxStoreNameLength = 100
+
+// storeOrderStatuses are the order statuses store staff can set. Payment and
+// cancellation are driven by the payment provider and the customer.
+var storeOrderStatuses = map[model.OrderStatusType]bool{
+	model.OrderStatusApproved:  true,
+	model.OrderStatusDeclined:  true,
+	model.OrderStatusShipped:   true,
+	model.OrderStatusDelivered: true,
+}
+
+type StoreService struct {
+	Repos        repository.Repositories
+	Tx           repository.UnitOfWork
+	OrderService *OrderService
+	Storage      storage.BlobStorage
+}
+
+func NewStoreService(repos repository.Repositories, tx repository.UnitOfWork, orderService *OrderService, blobStorage storage.BlobStorage) *StoreService {
+	return &StoreService{Repos: repos, Tx: tx, OrderService: orderService, Storage: blobStorage}
+}
+
+// StoreInput holds the editable fields of a store. Empty fields are left
+// unchanged on update; an empty slug is derived from the name on create.
+type StoreInput struct {
+	Name        string `json:"name" binding:"max=100"`
+	Slug        string `json:"slug" binding:"omitempty,max=100,slug"`
+	Description string `json:"description" binding:"max=2000"`
+}
+
+// CreateStore opens a new store owned by the user
+func (s *StoreService) CreateStore(ctx context.Context, ownerID uint, input StoreInput, logo *multipart.FileHeader) (*model.Store, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, utils.NewBadRequestError("store name is required")
+	}
+	if len(name) > maxStoreNameLength {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("store name must be at most %d characters", maxStoreNameLength))
+	}
+
+	slug := input.Slug
+	if slug == "" {
+		slug = slugify(name)
+	}
+	if err := validateSlug(slug); err != nil {
+		return nil, err
+	}
+
+	store := &model.Store{
+		OwnerID:     ownerID,
+		Name:        name,
+		Slug:        slug,
+		Description: strings.TrimSpace(input.Description),
+	}
+
+	if err := ensureStoreSlugAvailable(ctx, s.Repos.Stores, slug, 0); err != nil {
+		return nil, err
+	}
+
+	var uploaded *utils.UploadedImage
+	if logo != nil {
+		var err error
+		uploaded, err = utils.UploadImage(ctx, s.Storage, "stores", logo)
+		if err != nil {
+			return nil, err
+		}
+		store.LogoURL = uploaded.URL
+	}
+
+	if err := s.Repos.Stores.Create(ctx, store); err != nil {
+		s.deleteLogo(uploaded)
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+	return store, nil
+}
+
+// UpdateStore changes the details of a store. Only the owner can update it.
+func (s *StoreService) UpdateStore(ctx context.Context, storeID, userID uint, input StoreInput, logo *multipart.FileHeader) (*model.Store, error) {
+	store, err := ownedStore(ctx, s.Repos.Stores, storeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if len(name) > maxStoreNameLength {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("store name must be at most %d characters", maxStoreNameLength))
+	}
+	slug := ""
+	if input.Slug != "" && input.Slug != store.Slug {
+		if err := validateSlug(input.Slug); err != nil {
+			return nil, err
+		}
+		slug = input.Slug
+	}
+	description := strings.TrimSpace(input.Description)
+
+	var uploaded *utils.UploadedImage
+	if logo != nil {
+		uploaded, err = utils.UploadImage(ctx, s.Storage, "stores", logo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Lock the store so concurrent updates do not overwrite each other
+		var err error
+		store, err = tx.Stores.FindByIDForUpdate(ctx, storeID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve store: %w", err)
+		}
+
+		if name != "" {
+			store.Name = name
+		}
+		if slug != "" {
+			if err := ensureStoreSlugAvailable(ctx, tx.Stores, slug, store.ID); err != nil {
+				return err
+			}
+			store.Slug = slug
+		}
+		if description != "" {
+			store.Description = description
+		}
+		if uploaded != nil {
+			store.LogoURL = uploaded.URL
+		}
+
+		if err := tx.Stores.Save(ctx, store); err != nil {
+			return fmt.Errorf("failed to update store: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.deleteLogo(uploaded)
+		return nil, err
+	}
+	return store, nil
+}
+
+// deleteLogo removes an uploaded logo whose store could not be saved
+func (s *StoreService) deleteLogo(uploaded *utils.UploadedImage) {
+	if uploaded == nil {
+		return
+	}
+	if err := s.Storage.Delete(context.Background(), uploaded.Key); err != nil {
+		log.Printf("Could not delete logo %s: %v", uploaded.Key, err)
+	}
+}
+
+// GetStoreBySlug returns a store for its public page
+func (s *StoreService) GetStoreBySlug(ctx context.Context, slug string) (*model.Store, error) {
+	store, err := s.Repos.Stores.FindBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("store not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve store: %w", err)
+	}
+	return store, nil
+}
+
+// GetManagedStore returns a store with its staff to its owner or staff
+func (s *StoreService) GetManagedStore(ctx context.Context, storeID, userID uint) (*model.Store, error) {
+	if _, err := checkStoreMember(ctx, s.Repos.Stores, storeID, userID); err != nil {
+		return nil, err
+	}
+
+	store, err := s.Repos.Stores.FindWithStaff(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve store: %w", err)
+	}
+	return store, nil
+}
+
+// ListStoresForUser returns the stores the user owns or works at
+func (s *StoreService) ListStoresForUser(ctx context.Context, userID uint) ([]model.Store, error) {
+	stores, err := s.Repos.Stores.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve stores: %w", err)
+	}
+	return stores, nil
+}
+
+// AddStaff adds the user with the given email to the store's staff. Only the
+// owner can manage staff.
+func (s *StoreService) AddStaff(ctx context.Context, storeID, ownerID uint, email string) (*model.StoreStaff, error) {
+	store, err := ownedStore(ctx, s.Repos.Stores, storeID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.Repos.Users.FindByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("user not found")
+		}
+		return nil, fmt.Errorf("error finding user: %w", err)
+	}
+	if user.ID == store.OwnerID {
+		return nil, utils.NewBadRequestError("the owner cannot be added as staff")
+	}
+
+	staff := &model.StoreStaff{StoreID: store.ID, UserID: user.ID}
+	added, err := s.Repos.Stores.AddStaff(ctx, staff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add staff: %w", err)
+	}
+	if !added {
+		return nil, utils.NewConflictError("user is already a staff member")
+	}
+	return staff, nil
+}
+
+// RemoveStaff removes a user from the store's staff
+func (s *StoreService) RemoveStaff(ctx context.Context, storeID, ownerID, userID uint) error {
+	if _, err := ownedStore(ctx, s.Repos.Stores, storeID, ownerID); err != nil {
+		return err
+	}
+
+	removed, err := s.Repos.Stores.RemoveStaff(ctx, storeID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove staff: %w", err)
+	}
+	if !removed {
+		return utils.NewNotFoundError("user is not a staff member")
+	}
+	return nil
+}
+
+// ListStoreOrders returns the orders containing the store's products, newest
+// first. Each order only includes the store's own items.
+func (s *StoreService) ListStoreOrders(ctx context.Context, storeID, userID uint, status model.OrderStatusType) ([]model.Order, error) {
+	if status != "" && !status.IsValid() {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("invalid order status %q", status))
+	}
+	if _, err := checkStoreMember(ctx, s.Repos.Stores, storeID, userID); err != nil {
+		return nil, err
+	}
+
+	orders, err := s.Repos.Orders.ListForStore(ctx, storeID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve orders: %w", err)
+	}
+	return orders, nil
+}
+
+// UpdateStoreOrderStatus lets store staff approve, decline, ship or deliver an
+// order. Orders that also contain other stores' products cannot be changed by
+// one store alone.
+func (s *StoreService) UpdateStoreOrderStatus(ctx context.Context, storeID, orderID, userID uint, status model.OrderStatusType) (*model.Order, error) {
+	if !storeOrderStatuses[status] {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("store staff cannot set order status %q", status))
+	}
+
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if _, err := checkStoreMember(ctx, tx.Stores, storeID, userID); err != nil {
+			return err
+		}
+
+		order, err := tx.Orders.FindByIDForUpdate(ctx, orderID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewNotFoundError("order not found")
+			}
+			return fmt.Errorf("failed to retrieve order: %w", err)
+		}
+
+		own, other, err := tx.Orders.CountStoreItems(ctx, order.ID, storeID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve order items: %w", err)
+		}
+		if own == 0 {
+			return utils.NewNotFoundError("order not found")
+		}
+		if other > 0 {
+			return utils.NewForbiddenError("this order contains products from other stores")
+		}
+
+		return s.OrderService.transitionOrder(ctx, tx, order, status, &userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.Repos.Orders.FindWithItems(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve order: %w", err)
+	}
+	return order, nil
+}
+
+// ownedStore returns the store if the user owns it.
+func ownedStore(ctx context.Context, stores repository.StoreRepository, storeID, userID uint) (*model.Store, error) {
+	store, err := stores.FindByID(ctx, storeID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("store not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve store: %w", err)
+	}
+	if store.OwnerID != userID {
+		return nil, utils.NewForbiddenError("only the store owner can do this")
+	}
+	return store, nil
+}
+
+// ensureStoreSlugAvailable returns a conflict if another store uses the slug.
+// exceptID is the store being updated, or 0.
+func ensureStoreSlugAvailable(ctx context.Context, stores repository.StoreRepository, slug string, exceptID uint) error {
+	taken, err := stores.SlugTaken(ctx, slug, exceptID)
+	if err != nil {
+		return fmt.Errorf("failed to check slug: %w", err)
+	}
+	if taken {
+		return utils.NewConflictError(fmt.Sprintf("store slug %q is already taken", slug))
+	}
+	return nil
+}
+
+// checkStoreMember returns the store if the user is its owner or a staff
+// member.
+func checkStoreMember(ctx context.Context, stores repository.StoreRepository, storeID, userID uint) (*model.Store, error) {
+	store, err := stores.FindByID(ctx, storeID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewNotFoundError("store not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve store: %w", err)
+	}
+	if store.OwnerID == userID {
+		return store, nil
+	}
+
+	staff, err := stores.IsStaff(ctx, storeID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check store staff: %w", err)
+	}
+	if !staff {
+		return nil, utils.NewForbiddenError("you do not work at this store")
+	}
+	return store, nil
+}
+
+// validateSlug checks a slug is lowercase words separated by single hyphens.
+func validateSlug(slug string) error {
+	if len(slug) > maxStoreNameLength || !validation.IsSlug(slug) {
+		return utils.NewBadRequestError("slug must be lowercase letters, digits and single hyphens")
+	}
+	return nil
+}
+
+// slugify turns a name into a slug, e.g. "Ada's Shoes" becomes "ada-s-shoes".
+func slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			hyphen = false
+		} else if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
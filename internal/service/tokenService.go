@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/utils"
+)
+
+// RefreshTokenTTL is how long a refresh token can be used.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+type TokenService struct {
+	Repos repository.Repositories
+	Tx    repository.UnitOfWork
+	// JWTSecret signs access tokens; the auth middleware verifies them with
+	// the same secret.
+	JWTSecret []byte
+}
+
+func NewTokenService(repos repository.Repositories, tx repository.UnitOfWork, jwtSecret string) *TokenService {
+	return &TokenService{Repos: repos, Tx: tx, JWTSecret: []byte(jwtSecret)}
+}
+
+// TokenPair is returned on login and refresh. Token is the access token.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// IssueTokens starts a new refresh token family for the user, e.g. on login.
+func (s *TokenService) IssueTokens(ctx context.Context, user *model.User) (*TokenPair, error) {
+	familyID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, _, err := s.issue(ctx, s.Repos.Tokens, user, familyID)
+	return pair, err
+}
+
+// Refresh exchanges a refresh token for a new token pair, rotating the
+// refresh token. Presenting a token that was already used revokes every token
+// in its family, since either the client or an attacker holds a stolen copy.
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	var pair *TokenPair
+	reused := false
+
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		current, err := tx.Tokens.FindRefreshTokenForUpdate(ctx, hashToken(refreshToken))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewUnauthorizedError("invalid refresh token")
+			}
+			return fmt.Errorf("failed to retrieve refresh token: %w", err)
+		}
+
+		if current.RevokedAt != nil {
+			reused = true
+			return s.revokeFamily(ctx, tx.Tokens, current.FamilyID)
+		}
+
+		if current.ExpiresAt.Before(time.Now()) {
+			return utils.NewUnauthorizedError("refresh token has expired")
+		}
+
+		user, err := tx.Users.FindByID(ctx, current.UserID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewUnauthorizedError("invalid refresh token")
+			}
+			return fmt.Errorf("failed to retrieve user: %w", err)
+		}
+		// Tokens issued before a password reset do not survive it
+		if user.PasswordChangedAt != nil && current.CreatedAt.Before(*user.PasswordChangedAt) {
+			return utils.NewUnauthorizedError("refresh token has been revoked")
+		}
+
+		var next *model.RefreshToken
+		pair, next, err = s.issue(ctx, tx.Tokens, user, current.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Tokens.ReplaceRefreshToken(ctx, current.ID, next.ID); err != nil {
+			return fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The family revocation is committed before reporting the reuse
+	if reused {
+		return nil, utils.NewUnauthorizedError("refresh token has already been used")
+	}
+
+	return pair, nil
+}
+
+// Logout revokes the access token described by claims and, if given, the
+// refresh token family it was issued with.
+func (s *TokenService) Logout(ctx context.Context, claims *utils.Claims, refreshToken string) error {
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if err := tx.Tokens.RevokeAccessToken(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+			return fmt.Errorf("failed to revoke token: %w", err)
+		}
+
+		if refreshToken == "" {
+			return nil
+		}
+
+		userID, err := strconv.ParseUint(claims.UserID, 10, 64)
+		if err != nil {
+			return nil
+		}
+		token, err := tx.Tokens.FindUserRefreshToken(ctx, hashToken(refreshToken), uint(userID))
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to retrieve refresh token: %w", err)
+		}
+		return s.revokeFamily(ctx, tx.Tokens, token.FamilyID)
+	})
+}
+
+// IsTokenRevoked reports whether the access token described by claims has
+// been revoked, either individually or because the user's password changed
+// or their tokens were invalidated after it was issued. It is used by
+// middleware.VerifyToken.
+func (s *TokenService) IsTokenRevoked(ctx context.Context, claims *utils.Claims) (bool, error) {
+	userID, err := strconv.ParseUint(claims.UserID, 10, 64)
+	if err != nil {
+		return true, nil
+	}
+	revoked, err := s.Repos.Tokens.IsAccessTokenRevoked(ctx, claims.Id, uint(userID), time.Unix(claims.IssuedAt, 0))
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked tokens: %w", err)
+	}
+	return revoked, nil
+}
+
+// issue creates an access token and a refresh token in the given family.
+func (s *TokenService) issue(ctx context.Context, tokens repository.TokenRepository, user *model.User, familyID string) (*TokenPair, *model.RefreshToken, error) {
+	accessToken, err := utils.GenerateJWT(s.JWTSecret, strconv.FormatUint(uint64(user.ID), 10), user.Role)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := &model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}
+	if err := tokens.CreateRefreshToken(ctx, record); err != nil {
+		return nil, nil, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	pair := &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
+	}
+	return pair, record, nil
+}
+
+// revokeFamily revokes every unrevoked token in a refresh token family.
+func (s *TokenService) revokeFamily(ctx context.Context, tokens repository.TokenRepository, familyID string) error {
+	if err := tokens.RevokeFamily(ctx, familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// tokenCutoff returns the current time for revoking every token issued
+// before it, e.g. as a user's PasswordChangedAt or TokensValidAfter. Token
+// issue times are whole seconds, so the cutoff is too; otherwise a token
+// issued later in the same second would be rejected as well.
+func tokenCutoff() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// hashToken returns the hex SHA-256 of a token for storage and lookup.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
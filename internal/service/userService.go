@@ -2,61 +2,69 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	"gorm.io/gorm"
-
 	"instashop/internal/common"
+	"instashop/internal/mailer"
 	"instashop/internal/model"
+	"instashop/internal/repository"
 	"instashop/internal/utils"
 )
 
+// Sessions issues the tokens of a user's sessions.
+// *TokenService implements it.
+type Sessions interface {
+	IssueTokens(ctx context.Context, user *model.User) (*TokenPair, error)
+}
+
 type UserService struct {
-	DB *gorm.DB
+	Repos  repository.Repositories
+	Tx     repository.UnitOfWork
+	Tokens Sessions
 }
 
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{DB: db}
+// otpValidFor describes utils.GetOtpExpiryTime in emails.
+const otpValidFor = "10 minutes"
+
+func NewUserService(repos repository.Repositories, tx repository.UnitOfWork, tokens Sessions) *UserService {
+	return &UserService{Repos: repos, Tx: tx, Tokens: tokens}
 }
 
 func (s *UserService) validateUserInput(user *model.User) error {
 	if user.Email == "" || user.Username == "" || user.Password == "" {
-		utils.NewBadRequestError("email, username, and password are required")
-
+		return utils.NewValidationError("email, username, and password are required")
 	}
 	return nil
 }
 
-func (s *UserService) CreateUser(ctx context.Context, user *model.User) error {
+// prepareUser checks a new account and fills in its hashed password and
+// verification code, which it returns.
+func (s *UserService) prepareUser(ctx context.Context, user *model.User) (string, error) {
 	// Validate input
 	if err := s.validateUserInput(user); err != nil {
-		return err
+		return "", err
 	}
 
 	// Check for existing user with the same email or username
-	var existingUser model.User
-	err := s.DB.WithContext(ctx).
-		Where("email = ? OR username = ?", user.Email, user.Username).
-		First(&existingUser).Error
-
-	if err == nil {
-		utils.NewBadRequestError("user with given email or username already exists")
+	exists, err := s.Repos.Users.ExistsByEmailOrUsername(ctx, user.Email, user.Username)
+	if err != nil {
+		return "", fmt.Errorf("internal server error: %w", err)
 	}
-
-	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return fmt.Errorf("internal server error: %w", err)
+	if exists {
+		return "", utils.NewConflictError("user with given email or username already exists")
 	}
 
 	// Validate password
 	passwordValidation, err := common.ValidatePasswordString(user.Password)
 	if err != nil {
-		return err
+		return "", utils.NewValidationError(err.Error())
 	}
 	if !passwordValidation.IsValid {
-		return errors.New("password is not valid")
+		return "", utils.NewValidationError("password is not valid")
 	}
 
 	// Hash password
@@ -66,7 +74,7 @@ func (s *UserService) CreateUser(ctx context.Context, user *model.User) error {
 	// Generate OTP token
 	otpToken, err := utils.GenerateRandomNumber()
 	if err != nil {
-		return fmt.Errorf("failed to generate OTP token: %w", err)
+		return "", fmt.Errorf("failed to generate OTP token: %w", err)
 	}
 	user.OtpToken = otpToken
 	user.ExpiredAt = utils.GetOtpExpiryTime()
@@ -74,199 +82,275 @@ func (s *UserService) CreateUser(ctx context.Context, user *model.User) error {
 	// Set createdAt and updatedAt timestamps
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
-
-	// Send OTP email asynchronously
-	go func() {
-		to := []string{user.Email}
-		subject := "Test Email"
-		body := fmt.Sprintf("<h1>Hello from Mailtrap! Here is your OTP: %s</h1>", otpToken)
-
-		if err := utils.SendMail(subject, body, to); err != nil {
-			log.Printf("Could not send email: %v", err)
-		} else {
-			fmt.Println("Email sent successfully!")
-		}
-	}()
-
-	// Insert the new user into the database
-	result := s.DB.WithContext(ctx).Create(user)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	return nil
+	return otpToken, nil
 }
 
-func (s *UserService) CreateAdmin(ctx context.Context, user *model.User) error {
-	// Validate input
-	if err := s.validateUserInput(user); err != nil {
-		return err
-	}
-
-	// Check for existing user with the same email or username
-	var existingUser model.User
-	err := s.DB.WithContext(ctx).
-		Where("email = ? OR username = ?", user.Email, user.Username).
-		First(&existingUser).Error
-
-	if err == nil {
-		return errors.New("user with given email or username already exists")
-	}
-
-	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return fmt.Errorf("internal server error: %w", err)
-	}
-
-	// Validate password
-	passwordValidation, err := common.ValidatePasswordString(user.Password)
+func (s *UserService) CreateUser(ctx context.Context, user *model.User) error {
+	otpToken, err := s.prepareUser(ctx, user)
 	if err != nil {
 		return err
 	}
-	if !passwordValidation.IsValid {
-		return errors.New("password is not valid")
-	}
 
-	// Hash password
-	user.Password = utils.HashPassword(user.Password)
-	user.VerifiedEmail = false
+	// Insert the new user and queue the OTP email together
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if err := tx.Users.Create(ctx, user); err != nil {
+			return err
+		}
+		return tx.Emails.Send(ctx, verifyEmail(user.Email, otpToken))
+	})
+}
 
-	// Generate OTP token
-	otpToken, err := utils.GenerateRandomNumber()
+// CreateAdmin creates an admin account on behalf of an existing admin and
+// records who created it.
+func (s *UserService) CreateAdmin(ctx context.Context, createdBy uint, user *model.User) error {
+	otpToken, err := s.prepareUser(ctx, user)
 	if err != nil {
-		return fmt.Errorf("failed to generate OTP token: %w", err)
+		return err
 	}
-	user.OtpToken = otpToken
-	user.ExpiredAt = utils.GetOtpExpiryTime()
-	user.Role = "admin"
-
-	// Set createdAt and updatedAt timestamps
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
-
-	// Send OTP email asynchronously
-	go func() {
-		to := []string{user.Email}
-		subject := "Test Email"
-		body := fmt.Sprintf("<h1>Hello from Mailtrap! Here is your OTP: %s</h1>", otpToken)
+	user.Role = model.RoleAdmin
 
-		if err := utils.SendMail(subject, body, to); err != nil {
-			log.Printf("Could not send email: %v", err)
-		} else {
-			fmt.Println("Email sent successfully!")
+	// Insert the new user and the audit record of who created it, and queue
+	// the OTP email
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		if err := tx.Users.Create(ctx, user); err != nil {
+			return err
 		}
-	}()
-
-	// Insert the new user into the database
-	result := s.DB.WithContext(ctx).Create(user)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	return nil
+		if err := tx.Users.RecordRoleChange(ctx, &model.RoleChange{
+			UserID:    user.ID,
+			ToRole:    model.RoleAdmin,
+			ChangedBy: &createdBy,
+		}); err != nil {
+			return fmt.Errorf("failed to record role change: %w", err)
+		}
+		return tx.Emails.Send(ctx, verifyEmail(user.Email, otpToken))
+	})
 }
 
 func (s *UserService) VerifyEmail(email string, otpToken string) error {
-	// Find user by email
-	var user model.User
-	err := s.DB.Where("email = ?", email).First(&user).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("user not found")
+	ctx := context.Background()
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Find user by email
+		user, err := tx.Users.FindByEmailForUpdate(ctx, email)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewNotFoundError("user not found")
+			}
+			return fmt.Errorf("error finding user: %w", err)
 		}
-		return fmt.Errorf("error finding user: %w", err)
-	}
-
-	// Check if OTP token matches and is not expired
-	if user.OtpToken != otpToken {
-		utils.NewBadRequestError("invalid OTP token")
-	}
 
-	if user.ExpiredAt.Before(time.Now()) {
-		return errors.New("OTP token has expired")
-	}
+		// Check if OTP token matches and is not expired
+		if user.OtpToken != otpToken {
+			return utils.NewBadRequestError("invalid OTP token")
+		}
 
-	// Update user record to mark email as verified
-	user.VerifiedEmail = true
-	user.OtpToken = ""
-	user.ExpiredAt = time.Time{}
+		if user.ExpiredAt.Before(time.Now()) {
+			return utils.NewBadRequestError("OTP token has expired")
+		}
 
-	if err := s.DB.Save(&user).Error; err != nil {
-		return fmt.Errorf("failed to update user: %w", err)
-	}
+		// Update user record to mark email as verified
+		user.VerifiedEmail = true
+		user.OtpToken = ""
+		user.ExpiredAt = time.Time{}
 
-	return nil
+		if err := tx.Users.Save(ctx, user); err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		return nil
+	})
 }
 
 func (s *UserService) SendMail(email string) (string, error) {
-	// Check if user with the given email exists
-	var user model.User
-	err := s.DB.Where("email = ?", email).First(&user).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", errors.New("user does not exist")
-		}
-		return "", fmt.Errorf("error finding user: %w", err)
-	}
-
 	// Generate OTP token
 	otpToken, err := utils.GenerateRandomNumber()
 	if err != nil {
 		return "", fmt.Errorf("failed to generate OTP token: %w", err)
 	}
 
-	// Update user's OTP token and expiry time
-	user.OtpToken = otpToken
-	user.ExpiredAt = utils.GetOtpExpiryTime()
-
-	if err := s.DB.Save(&user).Error; err != nil {
-		return "", fmt.Errorf("failed to update user: %w", err)
-	}
-
-	// Send email with OTP token
-	to := []string{email}
-	subject := "OTP for account verification"
-	body := fmt.Sprintf("Your OTP is: %s", otpToken)
+	// Save the new OTP and queue the email with it
+	ctx := context.Background()
+	err = s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		// Check if user with the given email exists
+		user, err := tx.Users.FindByEmailForUpdate(ctx, email)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return utils.NewNotFoundError("user does not exist")
+			}
+			return fmt.Errorf("error finding user: %w", err)
+		}
 
-	if err := utils.SendMail(subject, body, to); err != nil {
-		return "", fmt.Errorf("failed to send email: %w", err)
+		// Update user's OTP token and expiry time
+		user.OtpToken = otpToken
+		user.ExpiredAt = utils.GetOtpExpiryTime()
+		if err := tx.Users.Save(ctx, user); err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		return tx.Emails.Send(ctx, verifyEmail(user.Email, otpToken))
+	})
+	if err != nil {
+		return "", err
 	}
 
-	log.Printf("Email sent successfully to %s\n", email)
-
 	// Return success message
 	successMessage := fmt.Sprintf("Email sent successfully to %s", email)
 	return successMessage, nil
 }
 
-func (s *UserService) Login( email, password string) (string, error) {
+func (s *UserService) Login(email, password string) (*TokenPair, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	// Find user by email
-	var user model.User
-	err := s.DB.WithContext(ctx).
-		Where("email = ?", email).First(&user).Error
+	user, err := s.Repos.Users.FindByEmail(ctx, email)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", fmt.Errorf("user not found: %w", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, utils.NewUnauthorizedError("invalid credentials")
 		}
-		return "", fmt.Errorf("database error: %w", err)
+		return nil, fmt.Errorf("database error: %w", err)
 	}
 
 	// Check if password is correct
 	if !utils.VerifyPassword(password, user.Password) {
-		return "", utils.NewBadRequestError("invalid credentials")
+		return nil, utils.NewUnauthorizedError("invalid credentials")
 	}
 
 	// Check if email is verified
 	if !user.VerifiedEmail {
-		return "", utils.NewBadRequestError("please verify your email")
+		return nil, utils.NewForbiddenError("please verify your email")
+	}
+
+	// Issue an access token and a refresh token
+	tokens, err := s.Tokens.IssueTokens(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// maxPasswordResetAttempts is how many wrong codes invalidate a reset code
+// and lock password resets for passwordResetLockout. Issuing a new code does
+// not reset the count.
+const maxPasswordResetAttempts = 5
+
+// passwordResetLockout is how long no reset code is issued after too many
+// wrong codes.
+const passwordResetLockout = time.Hour
+
+// ForgotPassword emails a single-use reset code to the user. It succeeds
+// whether or not the email belongs to an account, or resets are locked for
+// it, so callers cannot probe for registered addresses.
+func (s *UserService) ForgotPassword(ctx context.Context, email string) error {
+	// Generate the reset code; only its hash is stored
+	code, err := utils.GenerateRandomNumber()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset code: %w", err)
+	}
+	expiresAt := utils.GetOtpExpiryTime()
+
+	// Store the code and queue the email with it
+	return s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		user, err := tx.Users.FindByEmailForUpdate(ctx, email)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error finding user: %w", err)
+		}
+		if user.PasswordResetLockedUntil != nil && user.PasswordResetLockedUntil.After(time.Now()) {
+			return nil
+		}
+
+		user.PasswordResetToken = hashToken(code)
+		user.PasswordResetExpiresAt = &expiresAt
+		if err := tx.Users.Save(ctx, user); err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		return tx.Emails.Send(ctx, mailer.Email{
+			To:       user.Email,
+			Template: mailer.TemplatePasswordReset,
+			Data:     mailer.PasswordResetData{Code: code, ExpiresIn: otpValidFor},
+		})
+	})
+}
+
+// ResetPassword sets a new password using a code from ForgotPassword. The code
+// can only be used once, and every existing session of the user is revoked.
+func (s *UserService) ResetPassword(ctx context.Context, email, code, newPassword string) error {
+	invalidCode := utils.NewBadRequestError("invalid or expired reset code")
+
+	if _, err := common.ValidatePasswordString(newPassword); err != nil {
+		return utils.NewValidationError(err.Error())
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(fmt.Sprintf("%d", user.ID), user.Role)
+	var user *model.User
+	changed := false
+	err := s.Tx.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		user, err = tx.Users.FindByEmailForUpdate(ctx, email)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return invalidCode
+			}
+			return fmt.Errorf("error finding user: %w", err)
+		}
+
+		if user.PasswordResetToken == "" || user.PasswordResetExpiresAt == nil || user.PasswordResetExpiresAt.Before(time.Now()) {
+			return invalidCode
+		}
+
+		if subtle.ConstantTimeCompare([]byte(hashToken(code)), []byte(user.PasswordResetToken)) != 1 {
+			// Too many wrong guesses burn the code and lock resets; the
+			// failed attempt is committed even though an error is returned
+			user.PasswordResetAttempts++
+			if user.PasswordResetAttempts >= maxPasswordResetAttempts {
+				lockedUntil := time.Now().Add(passwordResetLockout)
+				user.PasswordResetToken = ""
+				user.PasswordResetExpiresAt = nil
+				user.PasswordResetAttempts = 0
+				user.PasswordResetLockedUntil = &lockedUntil
+			}
+			return tx.Users.Save(ctx, user)
+		}
+
+		// Sessions issued before this moment are rejected by VerifyToken
+		changedAt := tokenCutoff()
+		user.Password = utils.HashPassword(newPassword)
+		user.PasswordResetToken = ""
+		user.PasswordResetExpiresAt = nil
+		user.PasswordResetAttempts = 0
+		user.PasswordResetLockedUntil = nil
+		user.PasswordChangedAt = &changedAt
+		if err := tx.Users.Save(ctx, user); err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		// Revoke the refresh tokens with the password change so no session
+		// outlives it if either fails
+		if err := tx.Tokens.RevokeUser(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		changed = true
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+		return err
+	}
+	if !changed {
+		return invalidCode
+	}
+
+	// The password is already changed, so a failure to queue the
+	// confirmation is only logged
+	if err := s.Repos.Emails.Send(ctx, mailer.Email{To: user.Email, Template: mailer.TemplatePasswordChanged}); err != nil {
+		log.Printf("Could not queue password change email: %v", err)
 	}
 
-	return token, nil
+	return nil
+}
+
+// verifyEmail is the email carrying an account verification code.
+func verifyEmail(to, code string) mailer.Email {
+	return mailer.Email{
+		To:       to,
+		Template: mailer.TemplateVerifyEmail,
+		Data:     mailer.VerifyEmailData{Code: code, ExpiresIn: otpValidFor},
+	}
 }
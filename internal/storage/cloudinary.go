@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+)
+
+// CloudinaryStorage stores files in Cloudinary under Folder. Cloudinary
+// public IDs do not carry a file extension, so it is dropped from the key.
+type CloudinaryStorage struct {
+	cld    *cloudinary.Cloudinary
+	Folder string
+}
+
+func NewCloudinaryStorage(cloudName, apiKey, apiSecret, folder string) (*CloudinaryStorage, error) {
+	if cloudName == "" || apiKey == "" || apiSecret == "" {
+		return nil, errors.New("cloudinary cloud name, API key and API secret are required")
+	}
+
+	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Cloudinary instance: %w", err)
+	}
+	return &CloudinaryStorage{cld: cld, Folder: folder}, nil
+}
+
+func (s *CloudinaryStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	overwrite := true
+	result, err := s.cld.Upload.Upload(ctx, r, uploader.UploadParams{
+		PublicID:  s.publicID(key),
+		Overwrite: &overwrite,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error uploading file to Cloudinary: %w", err)
+	}
+	if result.Error.Message != "" {
+		return "", fmt.Errorf("error uploading file to Cloudinary: %s", result.Error.Message)
+	}
+	return result.SecureURL, nil
+}
+
+func (s *CloudinaryStorage) Delete(ctx context.Context, key string) error {
+	result, err := s.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: s.publicID(key)})
+	if err != nil {
+		return fmt.Errorf("error deleting file from Cloudinary: %w", err)
+	}
+	if result.Error.Message != "" {
+		return fmt.Errorf("error deleting file from Cloudinary: %s", result.Error.Message)
+	}
+	return nil
+}
+
+func (s *CloudinaryStorage) publicID(key string) string {
+	return path.Join(s.Folder, strings.TrimSuffix(key, path.Ext(key)))
+}
@@ -0,0 +1,16 @@
+// Package storage stores uploaded files such as product images.
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// BlobStorage stores files under a key and serves them from a public URL.
+type BlobStorage interface {
+	// Put stores the contents of r under key and returns its public URL.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	// Delete removes the file stored under key. Deleting a missing file is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -3,81 +3,102 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
-	"os"
 )
 
+// Error codes are the stable, machine-readable "error" values of the error
+// response envelope. Clients should branch on these rather than on messages.
+const (
+	CodeBadRequest          = "BAD_REQUEST"
+	CodeValidation          = "VALIDATION_FAILED"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeNotFound            = "NOT_FOUND"
+	CodeConflict            = "CONFLICT"
+	CodeDuplicateEntry      = "DUPLICATE_ENTRY"
+	CodeForeignKey          = "FOREIGN_KEY_CONSTRAINT"
+	CodeConstraintViolation = "CONSTRAINT_VIOLATION"
+	CodeInternal            = "INTERNAL_SERVER_ERROR"
+)
+
+// CustomError is an error meant for the client: its message is shown as is
+// and it is answered with HTTPStatusCode and Code.
 type CustomError struct {
-	Message        string `json:"message"`
-	ErrorCode      int    `json:"errorCode,omitempty"`
-	HTTPStatusCode int    `json:"httpStatusCode,omitempty"`
-	Service        string `json:"service,omitempty"`
-	Success        bool   `json:"success,omitempty"`
+	Message        string      `json:"message"`
+	Code           string      `json:"error"`
+	HTTPStatusCode int         `json:"httpStatusCode,omitempty"`
+	Details        interface{} `json:"details,omitempty"`
+	Service        string      `json:"service,omitempty"`
+	Success        bool        `json:"success"`
 }
 
 func (e *CustomError) Error() string {
 	return e.Message
 }
 
-var serviceName = os.Getenv("SERVICE_NAME")
+// WithDetails returns a copy of e carrying details, e.g. the fields that
+// failed validation.
+func (e *CustomError) WithDetails(details interface{}) *CustomError {
+	withDetails := *e
+	withDetails.Details = details
+	return &withDetails
+}
+
+var serviceName string
 
-func NewUnauthorizedError(message string) *CustomError {
+// SetServiceName sets the service name reported in error responses. It is
+// called once at startup with the configured name.
+func SetServiceName(name string) {
+	serviceName = name
+}
+
+// ServiceName returns the service name reported in error responses.
+func ServiceName() string {
+	return serviceName
+}
+
+// NewError returns an error answered with status and code.
+func NewError(status int, code, message string) *CustomError {
 	return &CustomError{
 		Message:        message,
-		ErrorCode:      401,
-		HTTPStatusCode: http.StatusUnauthorized,
+		Code:           code,
+		HTTPStatusCode: status,
 		Service:        serviceName,
 		Success:        false,
 	}
 }
 
+func NewUnauthorizedError(message string) *CustomError {
+	return NewError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
 func NewBadRequestError(message string) *CustomError {
-	return &CustomError{
-		Message:        message,
-		ErrorCode:      400,
-		HTTPStatusCode: http.StatusBadRequest,
-		Service:        serviceName,
-		Success:        false,
-	}
+	return NewError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// NewValidationError is for input that is well-formed but breaks a rule,
+// such as a password that is too weak.
+func NewValidationError(message string) *CustomError {
+	return NewError(http.StatusUnprocessableEntity, CodeValidation, message)
 }
 
 func NewConflictError(message string) *CustomError {
-	return &CustomError{
-		Message:        message,
-		ErrorCode:      409,
-		HTTPStatusCode: http.StatusConflict,
-		Service:        serviceName,
-		Success:        false,
-	}
+	return NewError(http.StatusConflict, CodeConflict, message)
 }
 
 func NewInternalServerError(message string) *CustomError {
-	return &CustomError{
-		Message:        message,
-		ErrorCode:      500,
-		HTTPStatusCode: http.StatusInternalServerError,
-		Service:        serviceName,
-		Success:        false,
-	}
+	return NewError(http.StatusInternalServerError, CodeInternal, message)
 }
 
 func NewUnauthenticatedError(message string) *CustomError {
-	return &CustomError{
-		Message:        message,
-		ErrorCode:      401,
-		HTTPStatusCode: http.StatusUnauthorized,
-		Service:        serviceName,
-		Success:        false,
-	}
+	return NewUnauthorizedError(message)
+}
+
+func NewForbiddenError(message string) *CustomError {
+	return NewError(http.StatusForbidden, CodeForbidden, message)
 }
 
 func NewNotFoundError(message string) *CustomError {
-	return &CustomError{
-		Message:        message,
-		ErrorCode:      404,
-		HTTPStatusCode: http.StatusNotFound,
-		Service:        serviceName,
-		Success:        false,
-	}
+	return NewError(http.StatusNotFound, CodeNotFound, message)
 }
 
 func (e *CustomError) ToJSON() ([]byte, error) {
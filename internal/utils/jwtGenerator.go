@@ -2,13 +2,14 @@ package utils
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
+// AccessTokenTTL is how long an access token is valid. Clients renew it with
+// their refresh token.
+const AccessTokenTTL = 15 * time.Minute
 
 type Claims struct {
 	UserID string `json:"user_id"`
@@ -16,18 +17,23 @@ type Claims struct {
 	jwt.StandardClaims
 }
 
-func GenerateJWT(userID, role string) (string, error) {
-	// Load the JWT secret from environment
-	jwtKey := []byte(os.Getenv("JWT_SECRET"))
-	fmt.Printf("CHECK: %w", jwtKey)
+// GenerateJWT issues a short-lived access token signed with jwtKey. Each token
+// carries a unique ID (jti) so it can be revoked before it expires.
+func GenerateJWT(jwtKey []byte, userID, role string) (string, error) {
+	tokenID, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
 
 	// Define token expiration
-	expirationTime := time.Now().Add(24 * time.Hour)
+	now := time.Now()
 	claims := &Claims{
 		UserID: userID,
 		Role:   role,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(AccessTokenTTL).Unix(),
 		},
 	}
 
@@ -40,5 +46,3 @@ func GenerateJWT(userID, role string) (string, error) {
 
 	return tokenString, nil
 }
-
-
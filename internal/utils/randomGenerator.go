@@ -2,10 +2,12 @@ package utils
 
 import (
 	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"math/big"
 	"time"
 )
+
 func GenerateRandomNumber() (string, error) {
 	// Define the maximum value for a 6-digit number (exclusive)
 	max := big.NewInt(900000) // 999999 - 100000 + 1
@@ -27,4 +29,13 @@ func GetOtpExpiryTime() time.Time {
 	expiryDuration := 10 * time.Minute
 	expiredAt := time.Now().Add(expiryDuration)
 	return expiredAt
-}
\ No newline at end of file
+}
+
+// GenerateRandomToken returns n random bytes encoded as URL-safe base64.
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
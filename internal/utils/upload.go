@@ -1,57 +1,77 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"mime/multipart"
-	"context"
-	"os"
+	"net/http"
+	"path"
 
-	"github.com/joho/godotenv"
-	"github.com/cloudinary/cloudinary-go/v2"
-	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"instashop/internal/storage"
 )
 
-// UploadImage uploads a file to Cloudinary and returns the public URL.
-func UploadImage(file *multipart.FileHeader) (string, error) {
-	// Load environment variables
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error loading .env file")
-	}
+// MaxImageSize is the largest image UploadImage accepts.
+const MaxImageSize = 5 << 20
 
-	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
-	apiKey := os.Getenv("CLOUDINARY_API_KEY")
-	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
-	if cloudName == "" || apiKey == "" || apiSecret == "" {
-		log.Fatalf("Cloudinary environment variables are not set")
-	}
+// imageExtensions maps the accepted image types to their file extensions.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
 
-	// Create a new Cloudinary instance
-	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
-	if err != nil {
-		log.Printf("error creating Cloudinary instance: %v", err)
-		return "", fmt.Errorf("error creating Cloudinary instance: %w", err)
+// UploadedImage describes an image stored by UploadImage.
+type UploadedImage struct {
+	URL         string
+	Key         string
+	ContentType string
+	Size        int64
+}
+
+// UploadImage validates an uploaded image and stores it under folder. The
+// type is detected from the file contents rather than trusted from the client.
+func UploadImage(ctx context.Context, store storage.BlobStorage, folder string, file *multipart.FileHeader) (*UploadedImage, error) {
+	if file.Size > MaxImageSize {
+		return nil, NewBadRequestError(fmt.Sprintf("%s is larger than %d MB", file.Filename, MaxImageSize>>20))
 	}
 
-	// Open the file
 	f, err := file.Open()
 	if err != nil {
-		log.Printf("error opening file: %v", err)
-		return "", fmt.Errorf("error opening file: %w", err)
+		return nil, fmt.Errorf("error opening file: %w", err)
 	}
 	defer f.Close()
 
-	// Upload the file to Cloudinary
-	uploadParams := uploader.UploadParams{
-		Folder: "your-folder-name", // Specify folder in Cloudinary (optional)
+	// Sniff the content type from the first bytes of the file
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	if n == 0 {
+		return nil, NewBadRequestError(fmt.Sprintf("%s is empty", file.Filename))
+	}
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, NewBadRequestError(fmt.Sprintf("%s is not a JPEG, PNG, WebP or GIF image", file.Filename))
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	name, err := GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
 	}
-	uploadResult, err := cld.Upload.Upload(context.TODO(), f, uploadParams)
+	key := path.Join(folder, name+ext)
+
+	// Never store more than the size limit, whatever the header claimed
+	url, err := store.Put(ctx, key, io.LimitReader(f, MaxImageSize), contentType)
 	if err != nil {
-		log.Printf("error uploading file to Cloudinary: %v", err)
-		return "", fmt.Errorf("error uploading file to Cloudinary: %w", err)
+		return nil, err
 	}
 
-	// Return the secure URL of the uploaded file
-	return uploadResult.SecureURL, nil
+	return &UploadedImage{URL: url, Key: key, ContentType: contentType, Size: file.Size}, nil
 }
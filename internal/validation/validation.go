@@ -0,0 +1,156 @@
+// Package validation checks request bodies against the rules in their
+// binding tags and describes failures per field, for clients to show next to
+// the form input that caused them.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxPrice is the largest price the decimal(10,2) price columns hold.
+const MaxPrice = 99999999.99
+
+var (
+	slugPattern     = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// IsSlug reports whether s is lowercase words and digits separated by single
+// hyphens, e.g. "summer-sale-2024".
+func IsSlug(s string) bool {
+	return slugPattern.MatchString(s)
+}
+
+// IsPrice reports whether f is a positive amount with at most two decimal
+// places that fits the price columns.
+func IsPrice(f float64) bool {
+	if f <= 0 || f > MaxPrice {
+		return false
+	}
+	cents := f * 100
+	return math.Abs(cents-math.Round(cents)) < 1e-6
+}
+
+// IsCurrency reports whether s looks like an ISO 4217 code such as "NGN".
+func IsCurrency(s string) bool {
+	return currencyPattern.MatchString(s)
+}
+
+// FieldError is a field that failed validation and why, e.g.
+// {"field": "items[0].quantity", "reason": "must be at least 1"}.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+
+	// Report fields by the name clients send
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	rules := map[string]validator.Func{
+		"slug": func(fl validator.FieldLevel) bool {
+			return IsSlug(fl.Field().String())
+		},
+		"price": func(fl validator.FieldLevel) bool {
+			return fl.Field().CanFloat() && IsPrice(fl.Field().Float())
+		},
+	}
+	for tag, rule := range rules {
+		if err := v.RegisterValidation(tag, rule); err != nil {
+			panic(fmt.Sprintf("validation: could not register %s: %v", tag, err))
+		}
+	}
+	return v
+}
+
+// Struct checks s against its binding tags. It returns nil, or the failing
+// fields.
+func Struct(s interface{}) []FieldError {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		// Not a struct, which is a programming error rather than bad input
+		panic(fmt.Sprintf("validation: %v", err))
+	}
+
+	failures := make([]FieldError, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		failures = append(failures, FieldError{Field: fieldName(fe), Reason: reason(fe)})
+	}
+	return failures
+}
+
+// fieldName is the path of the field from the top of the request, e.g.
+// "items[0].quantity" rather than "PlaceOrderRequest.items[0].quantity".
+func fieldName(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func reason(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "slug":
+		return "must be lowercase letters and digits separated by single hyphens"
+	case "price":
+		return fmt.Sprintf("must be a positive amount of at most %.2f with no more than two decimal places", MaxPrice)
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min", "gte":
+		return bound(fe, "at least")
+	case "max", "lte":
+		return bound(fe, "at most")
+	case "gt":
+		return bound(fe, "more than")
+	case "lt":
+		return bound(fe, "less than")
+	case "len":
+		return bound(fe, "exactly")
+	default:
+		return "is invalid"
+	}
+}
+
+// bound describes a size rule in the unit of the field: characters for
+// strings, items for lists and the value itself for numbers.
+func bound(fe validator.FieldError, comparison string) string {
+	switch fe.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("must be %s %s characters long", comparison, fe.Param())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("must have %s %s items", comparison, fe.Param())
+	default:
+		return fmt.Sprintf("must be %s %s", comparison, fe.Param())
+	}
+}
@@ -0,0 +1,14 @@
+CREATE TABLE order_products (
+    order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    PRIMARY KEY (order_id, product_id),
+    CONSTRAINT fk_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+INSERT INTO order_products (order_id, product_id)
+SELECT DISTINCT order_id, product_id FROM order_items;
+
+DROP TABLE IF EXISTS order_items;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS total;
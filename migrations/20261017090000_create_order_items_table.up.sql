@@ -0,0 +1,26 @@
+ALTER TABLE orders ADD COLUMN total DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+CREATE TABLE order_items (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL,
+    line_total DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_order_item_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_item_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE RESTRICT
+);
+
+CREATE INDEX idx_order_items_order_id ON order_items (order_id);
+
+-- Carry existing orders over as single-quantity lines at the current product price.
+INSERT INTO order_items (order_id, product_id, quantity, unit_price, line_total)
+SELECT op.order_id, op.product_id, 1, p.price, p.price
+FROM order_products op
+JOIN products p ON p.id = op.product_id;
+
+UPDATE orders o
+SET total = COALESCE((SELECT SUM(i.line_total) FROM order_items i WHERE i.order_id = o.id), 0);
+
+DROP TABLE order_products;
@@ -0,0 +1,3 @@
+ALTER TABLE products ADD COLUMN stock INT NOT NULL DEFAULT 0;
+
+ALTER TABLE products ADD CONSTRAINT chk_products_stock_non_negative CHECK (stock >= 0);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_products_status;
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS fk_products_reviewed_by,
+    DROP COLUMN IF EXISTS reviewed_at,
+    DROP COLUMN IF EXISTS reviewed_by,
+    DROP COLUMN IF EXISTS decline_reason;
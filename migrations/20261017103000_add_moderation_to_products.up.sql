@@ -0,0 +1,7 @@
+ALTER TABLE products
+    ADD COLUMN decline_reason TEXT,
+    ADD COLUMN reviewed_by INT,
+    ADD COLUMN reviewed_at TIMESTAMP,
+    ADD CONSTRAINT fk_products_reviewed_by FOREIGN KEY (reviewed_by) REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_products_status ON products (status);
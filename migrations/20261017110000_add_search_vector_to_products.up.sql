@@ -0,0 +1,11 @@
+ALTER TABLE products ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+
+-- Keyset pagination indexes for the catalog sort orders.
+CREATE INDEX idx_products_created_at_id ON products (created_at, id);
+CREATE INDEX idx_products_price_id ON products (price, id);
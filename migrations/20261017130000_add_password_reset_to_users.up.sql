@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN password_reset_token VARCHAR(64),
+    ADD COLUMN password_reset_expires_at TIMESTAMP,
+    ADD COLUMN password_reset_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN password_changed_at TIMESTAMP;
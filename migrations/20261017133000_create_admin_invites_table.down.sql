@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS role_changes;
+DROP TABLE IF EXISTS admin_invites;
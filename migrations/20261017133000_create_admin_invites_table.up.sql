@@ -0,0 +1,29 @@
+CREATE TABLE admin_invites (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by INT,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_by INT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_admin_invites_invited_by FOREIGN KEY (invited_by) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT fk_admin_invites_accepted_by FOREIGN KEY (accepted_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_admin_invites_email ON admin_invites (email);
+
+CREATE TABLE role_changes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    from_role VARCHAR(20),
+    to_role VARCHAR(20) NOT NULL,
+    changed_by INT,
+    invite_id INT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_role_changes_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_role_changes_changed_by FOREIGN KEY (changed_by) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT fk_role_changes_invite FOREIGN KEY (invite_id) REFERENCES admin_invites (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_role_changes_user_id ON role_changes (user_id);
@@ -0,0 +1,4 @@
+ALTER TABLE products DROP COLUMN IF EXISTS store_id;
+
+DROP TABLE IF EXISTS store_staff;
+DROP TABLE IF EXISTS stores;
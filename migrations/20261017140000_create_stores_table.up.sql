@@ -0,0 +1,44 @@
+CREATE TABLE stores (
+    id SERIAL PRIMARY KEY,
+    owner_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    logo_url VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_stores_owner FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_stores_owner_id ON stores (owner_id);
+
+CREATE TABLE store_staff (
+    id SERIAL PRIMARY KEY,
+    store_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_store_staff_store FOREIGN KEY (store_id) REFERENCES stores (id) ON DELETE CASCADE,
+    CONSTRAINT fk_store_staff_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT uq_store_staff_store_user UNIQUE (store_id, user_id)
+);
+
+CREATE INDEX idx_store_staff_user_id ON store_staff (user_id);
+
+ALTER TABLE products ADD COLUMN store_id INT;
+
+-- Give every existing seller a store holding their products
+INSERT INTO stores (owner_id, name, slug)
+SELECT u.id, u.username, 'store-' || u.id
+FROM users u
+WHERE EXISTS (SELECT 1 FROM products p WHERE p.user_id = u.id);
+
+UPDATE products p
+SET store_id = s.id
+FROM stores s
+WHERE s.owner_id = p.user_id;
+
+ALTER TABLE products
+    ALTER COLUMN store_id SET NOT NULL,
+    ADD CONSTRAINT fk_products_store FOREIGN KEY (store_id) REFERENCES stores (id) ON DELETE CASCADE;
+
+CREATE INDEX idx_products_store_id ON products (store_id);
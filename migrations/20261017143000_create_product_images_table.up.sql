@@ -0,0 +1,17 @@
+CREATE TABLE product_images (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    url VARCHAR(500) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size BIGINT NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_product_images_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_images_product_id_position ON product_images (product_id, position);
+
+-- At most one primary image per product
+CREATE UNIQUE INDEX uq_product_images_primary ON product_images (product_id) WHERE is_primary;
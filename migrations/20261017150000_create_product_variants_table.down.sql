@@ -0,0 +1,12 @@
+DELETE FROM cart_items WHERE variant_id IS NOT NULL;
+DROP INDEX IF EXISTS uq_cart_items_cart_variant;
+DROP INDEX IF EXISTS uq_cart_items_cart_product;
+ALTER TABLE cart_items ADD CONSTRAINT uq_cart_items_cart_product UNIQUE (cart_id, product_id);
+ALTER TABLE cart_items DROP COLUMN IF EXISTS variant_id;
+
+ALTER TABLE order_items DROP COLUMN IF EXISTS variant_id;
+
+DROP TABLE IF EXISTS product_variant_values;
+DROP TABLE IF EXISTS product_variants;
+DROP TABLE IF EXISTS product_option_values;
+DROP TABLE IF EXISTS product_options;
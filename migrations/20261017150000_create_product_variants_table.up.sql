@@ -0,0 +1,51 @@
+CREATE TABLE product_options (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_product_options_product_name UNIQUE (product_id, name),
+    CONSTRAINT fk_product_options_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE TABLE product_option_values (
+    id SERIAL PRIMARY KEY,
+    option_id INT NOT NULL,
+    value VARCHAR(50) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    CONSTRAINT uq_product_option_values_option_value UNIQUE (option_id, value),
+    CONSTRAINT fk_product_option_values_option FOREIGN KEY (option_id) REFERENCES product_options (id) ON DELETE CASCADE
+);
+
+CREATE TABLE product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    sku VARCHAR(64) NOT NULL UNIQUE,
+    price DECIMAL(10,2) CHECK (price >= 0),
+    stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_product_variants_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_variants_product_id ON product_variants (product_id);
+
+CREATE TABLE product_variant_values (
+    variant_id INT NOT NULL,
+    option_value_id INT NOT NULL,
+    PRIMARY KEY (variant_id, option_value_id),
+    CONSTRAINT fk_product_variant_values_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_variant_values_value FOREIGN KEY (option_value_id) REFERENCES product_option_values (id) ON DELETE RESTRICT
+);
+
+-- Orders keep the variant they were placed for; a variant with orders
+-- cannot be deleted
+ALTER TABLE order_items ADD COLUMN variant_id INT;
+ALTER TABLE order_items ADD CONSTRAINT fk_order_items_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE RESTRICT;
+
+-- A cart holds each product once, or each variant of it once
+ALTER TABLE cart_items ADD COLUMN variant_id INT;
+ALTER TABLE cart_items ADD CONSTRAINT fk_cart_items_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE CASCADE;
+ALTER TABLE cart_items DROP CONSTRAINT uq_cart_items_cart_product;
+CREATE UNIQUE INDEX uq_cart_items_cart_product ON cart_items (cart_id, product_id) WHERE variant_id IS NULL;
+CREATE UNIQUE INDEX uq_cart_items_cart_variant ON cart_items (cart_id, variant_id) WHERE variant_id IS NOT NULL;
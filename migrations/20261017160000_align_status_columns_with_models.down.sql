@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users ADD CONSTRAINT idx_deleted_at_deleted_at_idx UNIQUE (deleted_at);
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ALTER COLUMN status TYPE VARCHAR(10);
+
+ALTER TABLE order_status_history DROP CONSTRAINT IF EXISTS chk_order_status_history_status;
+ALTER TABLE order_status_history ALTER COLUMN to_status TYPE VARCHAR(10);
+ALTER TABLE order_status_history ALTER COLUMN from_status TYPE VARCHAR(10);
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS chk_orders_status;
+ALTER TABLE orders ALTER COLUMN status TYPE VARCHAR(10);
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS chk_products_status;
+ALTER TABLE products ALTER COLUMN status TYPE VARCHAR(10);
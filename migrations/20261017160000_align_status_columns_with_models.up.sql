@@ -0,0 +1,27 @@
+-- Status columns were VARCHAR(10) with no constraint on the values, so a
+-- longer status would fail at runtime and a mistyped one would be stored.
+-- Widen them and check the values against the statuses the models define.
+ALTER TABLE products ALTER COLUMN status TYPE VARCHAR(20);
+ALTER TABLE products ADD CONSTRAINT chk_products_status
+    CHECK (status IN ('pending', 'declined', 'approved'));
+
+ALTER TABLE orders ALTER COLUMN status TYPE VARCHAR(20);
+ALTER TABLE orders ADD CONSTRAINT chk_orders_status
+    CHECK (status IN ('pending', 'declined', 'approved', 'canceled', 'paid', 'shipped', 'delivered'));
+
+ALTER TABLE order_status_history ALTER COLUMN from_status TYPE VARCHAR(20);
+ALTER TABLE order_status_history ALTER COLUMN to_status TYPE VARCHAR(20);
+ALTER TABLE order_status_history ADD CONSTRAINT chk_order_status_history_status
+    CHECK (
+        (from_status IS NULL OR from_status IN ('pending', 'declined', 'approved', 'canceled', 'paid', 'shipped', 'delivered'))
+        AND to_status IN ('pending', 'declined', 'approved', 'canceled', 'paid', 'shipped', 'delivered')
+    );
+
+ALTER TABLE payments ALTER COLUMN status TYPE VARCHAR(20);
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status
+    CHECK (status IN ('pending', 'succeeded', 'failed', 'refunded'));
+
+-- users.deleted_at was UNIQUE, so two users soft-deleted in the same instant
+-- would collide. The model declares a plain index.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS idx_deleted_at_deleted_at_idx;
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
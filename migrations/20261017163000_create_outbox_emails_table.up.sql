@@ -0,0 +1,19 @@
+CREATE TABLE outbox_emails (
+    id SERIAL PRIMARY KEY,
+    recipient VARCHAR(255) NOT NULL,
+    template VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_outbox_emails_status CHECK (status IN ('pending', 'sent', 'failed'))
+);
+
+-- Workers look for pending emails that are due
+CREATE INDEX idx_outbox_emails_due ON outbox_emails (next_attempt_at) WHERE status = 'pending';
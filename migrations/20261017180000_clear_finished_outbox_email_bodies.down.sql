@@ -0,0 +1 @@
+-- The cleared bodies cannot be restored
@@ -0,0 +1,3 @@
+-- Sent and failed emails no longer keep their bodies, which hold one-time
+-- codes and tokens
+UPDATE outbox_emails SET text_body = '', html_body = '' WHERE status <> 'pending';
@@ -0,0 +1,12 @@
+// Package migrations holds the SQL schema migrations. Each migration is a pair
+// of files named <version>_<name>.up.sql and <version>_<name>.down.sql, where
+// version is a timestamp such as 20261017160000. Migrations are applied in
+// version order by internal/migrate.
+package migrations
+
+import "embed"
+
+// FS contains the migration files.
+//
+//go:embed *.sql
+var FS embed.FS
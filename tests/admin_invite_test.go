@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"instashop/internal/middleware"
+	"instashop/internal/model"
+	"instashop/internal/service"
+	"instashop/internal/utils"
+)
+
+// seedInvite stores an invite directly so no invite email is sent.
+func seedInvite(t *testing.T, db *gorm.DB, email, token string, invitedBy *uint, expiresAt time.Time) *model.AdminInvite {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(token))
+	invite := &model.AdminInvite{
+		Email:     email,
+		TokenHash: hex.EncodeToString(sum[:]),
+		InvitedBy: invitedBy,
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Create(invite).Error; err != nil {
+		t.Fatalf("failed to seed invite: %v", err)
+	}
+	return invite
+}
+
+func TestBootstrapInviteCreatesOnlyTheFirstAdmin(t *testing.T) {
+	db := testDB(t)
+	invites := newAdminInviteService(db, "bootstrap-secret")
+	ctx := context.Background()
+
+	first := seedInvite(t, db, "first@example.com", "first-token", nil, time.Now().Add(time.Hour))
+	seedInvite(t, db, "second@example.com", "second-token", nil, time.Now().Add(time.Hour))
+
+	admin, err := invites.AcceptInvite(ctx, "first-token", "first", "Str0ng!Passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.Role != model.RoleAdmin || !admin.VerifiedEmail {
+		t.Errorf("got role %q verified %v, want a verified admin", admin.Role, admin.VerifiedEmail)
+	}
+
+	// The invite is single use
+	if _, err := invites.AcceptInvite(ctx, "first-token", "again", "Str0ng!Passw0rd"); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v reusing an invite, want a 400", err)
+	}
+
+	// Once an admin exists no other bootstrap invite can be used or issued
+	if _, err := invites.AcceptInvite(ctx, "second-token", "second", "Str0ng!Passw0rd"); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got error %v accepting a second bootstrap invite, want a 409", err)
+	}
+	if _, err := invites.CreateBootstrapInvite(ctx, "bootstrap-secret", "third@example.com"); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got error %v requesting a bootstrap invite, want a 409", err)
+	}
+
+	changes, err := invites.ListRoleChanges(ctx, admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].ToRole != model.RoleAdmin || changes[0].ChangedBy != nil || *changes[0].InviteID != first.ID {
+		t.Errorf("unexpected role changes %+v", changes)
+	}
+}
+
+func TestInvitePromotesExistingUser(t *testing.T) {
+	db := testDB(t)
+	invites := newAdminInviteService(db, "")
+	ctx := context.Background()
+
+	inviter := seedUser(t, db, "inviter", model.RoleAdmin)
+	editor := seedUser(t, db, "seller", model.RoleEditor)
+	if err := db.Model(editor).Update("password", utils.HashPassword("Str0ng!Passw0rd")).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	seedInvite(t, db, editor.Email, "expired-token", &inviter.ID, time.Now().Add(-time.Minute))
+	if _, err := invites.AcceptInvite(ctx, "expired-token", "", "Str0ng!Passw0rd"); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v accepting an expired invite, want a 400", err)
+	}
+
+	seedInvite(t, db, editor.Email, "valid-token", &inviter.ID, time.Now().Add(time.Hour))
+	if _, err := invites.AcceptInvite(ctx, "valid-token", "", "wrong password"); httpStatus(err) != http.StatusUnauthorized {
+		t.Errorf("got error %v with the wrong password, want a 401", err)
+	}
+
+	promoted, err := invites.AcceptInvite(ctx, "valid-token", "", "Str0ng!Passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promoted.ID != editor.ID || promoted.Role != model.RoleAdmin {
+		t.Errorf("got user %d with role %q, want user %d promoted to admin", promoted.ID, promoted.Role, editor.ID)
+	}
+
+	changes, err := invites.ListRoleChanges(ctx, editor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].FromRole != model.RoleEditor || *changes[0].ChangedBy != inviter.ID {
+		t.Errorf("unexpected role changes %+v", changes)
+	}
+}
+
+func TestChangeRoleIsAudited(t *testing.T) {
+	db := testDB(t)
+	invites := newAdminInviteService(db, "")
+	ctx := context.Background()
+
+	admin := seedUser(t, db, "admin", model.RoleAdmin)
+	buyer := seedUser(t, db, "buyer", model.RoleUser)
+
+	if _, err := invites.ChangeRole(ctx, admin.ID, admin.ID, model.RoleUser); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v changing own role, want a 400", err)
+	}
+	if _, err := invites.ChangeRole(ctx, buyer.ID, admin.ID, model.RoleUser); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got error %v when a non-admin changes a role, want a 403", err)
+	}
+	if _, err := invites.ChangeRole(ctx, admin.ID, buyer.ID, "owner"); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v for an unknown role, want a 400", err)
+	}
+
+	updated, err := invites.ChangeRole(ctx, admin.ID, buyer.ID, model.RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Role != model.RoleEditor {
+		t.Errorf("got role %q, want editor", updated.Role)
+	}
+
+	changes, err := invites.ListRoleChanges(ctx, buyer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].FromRole != model.RoleUser || changes[0].ToRole != model.RoleEditor || *changes[0].ChangedBy != admin.ID {
+		t.Errorf("unexpected role changes %+v", changes)
+	}
+}
+
+func TestDemotionRejectsOldAccessTokens(t *testing.T) {
+	db := testDB(t)
+	tokens := newTokenService(db)
+	invites := newAdminInviteService(db, "")
+	ctx := context.Background()
+
+	admin := seedUser(t, db, "admin", model.RoleAdmin)
+	other := seedUser(t, db, "other", model.RoleAdmin)
+	pair, err := tokens.IssueTokens(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", middleware.VerifyToken([]byte("test-secret"), tokens), func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func() int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if got := get(); got != http.StatusOK {
+		t.Fatalf("got %d with a fresh token, want 200", got)
+	}
+	// Tokens issued in the same second as the demotion stay valid
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	if _, err := invites.ChangeRole(ctx, admin.ID, other.ID, model.RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != http.StatusUnauthorized {
+		t.Errorf("got %d with an admin token after demotion, want 401", got)
+	}
+	if _, err := tokens.Refresh(ctx, pair.RefreshToken); httpStatus(err) != http.StatusUnauthorized {
+		t.Errorf("got error %v refreshing after demotion, want a 401", err)
+	}
+}
+
+func TestDemotionKeepsTokensIssuedAfterIt(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	users := shop.store.Repositories().Users
+	admin := &model.User{Email: "admin@example.com", Username: "admin", Password: "Str0ngPassw0rd", Role: model.RoleAdmin}
+	other := &model.User{Email: "other@example.com", Username: "other", Password: "Str0ngPassw0rd", Role: model.RoleAdmin}
+	for _, user := range []*model.User{admin, other} {
+		if err := users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invites := service.NewAdminInviteService(shop.store.Repositories(), shop.store, "", "")
+	demoted, err := invites.ChangeRole(ctx, admin.ID, other.ID, model.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if demoted.TokensValidAfter == nil || !demoted.TokensValidAfter.Equal(demoted.TokensValidAfter.Truncate(time.Second)) {
+		t.Fatalf("got tokens valid after %v, want a whole second", demoted.TokensValidAfter)
+	}
+
+	// Signing in again right away works, as issue times are whole seconds
+	claims := &utils.Claims{UserID: strconv.Itoa(int(other.ID))}
+	claims.Id = "fresh"
+	claims.IssuedAt = time.Now().Unix()
+	tokens := service.NewTokenService(shop.store.Repositories(), shop.store, "test-secret")
+	if revoked, err := tokens.IsTokenRevoked(ctx, claims); err != nil || revoked {
+		t.Errorf("token issued after the demotion revoked=%v err=%v", revoked, err)
+	}
+}
@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/config"
+	"instashop/internal/database"
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+	"instashop/internal/server"
+	"instashop/internal/utils"
+)
+
+const apiPassword = "Str0ngPassw0rd"
+
+// apiHarness is the whole application, as served by server.RegisterRoutes, on
+// a fresh schema. Every test gets its own harness, so no state is shared
+// between tests. Email is queued in the outbox as usual and delivered to a
+// capture transport by deliverEmails.
+type apiHarness struct {
+	t       *testing.T
+	db      *gorm.DB
+	handler http.Handler
+	emails  *mailer.CaptureTransport
+	outbox  *mailer.Worker
+}
+
+func newAPIHarness(t *testing.T) *apiHarness {
+	t.Helper()
+
+	db := testDB(t)
+	dbService, err := database.Wrap(db, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emails := mailer.NewCaptureTransport()
+	return &apiHarness{
+		t:       t,
+		db:      db,
+		handler: server.New(serverConfig(t), dbService, emails).Handler(),
+		emails:  emails,
+		outbox:  mailer.NewWorker(db, emails, mailer.WorkerConfig{MaxAttempts: 1, Backoff: time.Minute, MaxBackoff: time.Hour}),
+	}
+}
+
+// serverConfig returns the settings to serve the API in tests with: the fake
+// payment provider and uploads in a temporary directory.
+func serverConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	env := requiredEnv()
+	env["PAYMENT_PROVIDER"] = "fake"
+	env["PAYMENT_WEBHOOK_SECRET"] = "whsec_test"
+	env["UPLOAD_DIR"] = t.TempDir()
+	cfg, err := config.Parse(nil, envMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// do sends a JSON request, authenticated when token is set, and decodes the
+// response into out when out is not nil. It returns the status code.
+func (h *apiHarness) do(method, path, token string, body, out interface{}) int {
+	h.t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	h.handler.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			h.t.Fatalf("%s %s: invalid response %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+// mustDo is do for requests that have to answer want.
+func (h *apiHarness) mustDo(method, path, token string, body, out interface{}, want int) {
+	h.t.Helper()
+
+	var raw json.RawMessage
+	if got := h.do(method, path, token, body, &raw); got != want {
+		h.t.Fatalf("%s %s: got %d %s, want %d", method, path, got, raw, want)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+}
+
+// deliverEmails sends the queued emails and returns every email sent so far.
+func (h *apiHarness) deliverEmails() []mailer.Message {
+	h.t.Helper()
+
+	for {
+		claimed, err := h.outbox.ProcessBatch(context.Background())
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		if claimed == 0 {
+			return h.emails.Messages()
+		}
+	}
+}
+
+// seedAccount adds a verified account that can log in with apiPassword.
+func (h *apiHarness) seedAccount(username, role string) *model.User {
+	h.t.Helper()
+
+	user := &model.User{
+		Username:      username,
+		Email:         username + "@example.com",
+		Password:      utils.HashPassword(apiPassword),
+		VerifiedEmail: true,
+		Role:          role,
+	}
+	if err := h.db.Create(user).Error; err != nil {
+		h.t.Fatalf("failed to seed user: %v", err)
+	}
+	return user
+}
+
+// login returns an access token for the account.
+func (h *apiHarness) login(email string) string {
+	h.t.Helper()
+
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	h.mustDo("POST", "/v1/auth/login", "", object{"email": email, "password": apiPassword}, &tokens, http.StatusOK)
+	return tokens.Token
+}
+
+// object is shorthand for a JSON object in a request body.
+type object = map[string]interface{}
+
+func TestShopFlowOverAPI(t *testing.T) {
+	h := newAPIHarness(t)
+
+	// Fixtures: a seller with a store, and an admin to approve the product
+	seller := h.seedAccount("seller", "editor")
+	seedStore(t, h.db, seller.ID)
+	admin := h.seedAccount("admin", "admin")
+
+	// A buyer signs up and verifies their email with the emailed code
+	h.mustDo("POST", "/v1/auth/users/create", "", object{"username": "buyer", "email": "buyer@example.com", "password": apiPassword}, nil, http.StatusCreated)
+	if got := h.do("POST", "/v1/auth/login", "", object{"email": "buyer@example.com", "password": apiPassword}, nil); got != http.StatusForbidden {
+		t.Errorf("got %d logging in unverified, want 403", got)
+	}
+	messages := h.deliverEmails()
+	if len(messages) != 1 || messages[0].To != "buyer@example.com" || messages[0].Template != mailer.TemplateVerifyEmail {
+		t.Fatalf("got emails %+v, want one verification email to the buyer", messages)
+	}
+	h.mustDo("POST", "/v1/auth/verify-email", "", object{"email": "buyer@example.com", "otpToken": emailCode(t, messages[0])}, nil, http.StatusOK)
+	buyerToken := h.login("buyer@example.com")
+
+	// The seller lists a product, which sells once an admin approves it
+	sellerToken := h.login(seller.Email)
+	var created struct {
+		Product model.Product `json:"product"`
+	}
+	h.mustDo("POST", "/v1/products", sellerToken, object{"name": "Desk lamp", "description": "A lamp for a desk", "price": 25.5, "stock": 5}, &created, http.StatusCreated)
+	productID := created.Product.ID
+	if created.Product.Status != model.StatusPending {
+		t.Errorf("got new product status %q, want pending", created.Product.Status)
+	}
+	order := object{"items": []object{{"product_id": productID, "quantity": 2}}}
+	if got := h.do("POST", "/v1/orders/", buyerToken, order, nil); got != http.StatusBadRequest {
+		t.Errorf("got %d ordering a pending product, want 400", got)
+	}
+	h.mustDo("PATCH", fmt.Sprintf("/v1/admin/products/%d/approve", productID), h.login(admin.Email), nil, nil, http.StatusOK)
+
+	// The buyer orders two, which takes them out of stock
+	var placed struct {
+		Order model.Order `json:"order"`
+	}
+	h.mustDo("POST", "/v1/orders/", buyerToken, order, &placed, http.StatusCreated)
+	if placed.Order.Status != model.OrderStatusPending || placed.Order.Total != 51 {
+		t.Errorf("got order %s totalling %v, want pending totalling 51", placed.Order.Status, placed.Order.Total)
+	}
+	if got := h.productStock(productID); got != 3 {
+		t.Errorf("got stock %d after ordering, want 3", got)
+	}
+
+	// Only the buyer may cancel it, and cancelling returns the stock
+	otherToken := h.login(h.seedAccount("other", "user").Email)
+	if got := h.do("PATCH", fmt.Sprintf("/v1/orders/%d", placed.Order.ID), otherToken, nil, nil); got == http.StatusOK {
+		t.Error("another user cancelled the buyer's order")
+	}
+	h.mustDo("PATCH", fmt.Sprintf("/v1/orders/%d", placed.Order.ID), buyerToken, nil, nil, http.StatusOK)
+	if got := h.productStock(productID); got != 5 {
+		t.Errorf("got stock %d after cancelling, want 5", got)
+	}
+
+	var listed struct {
+		Orders []model.Order `json:"orders"`
+	}
+	h.mustDo("GET", "/v1/orders/", buyerToken, nil, &listed, http.StatusOK)
+	if len(listed.Orders) != 1 || listed.Orders[0].Status != model.OrderStatusCanceled {
+		t.Errorf("got orders %+v, want the one cancelled order", listed.Orders)
+	}
+}
+
+func TestAPIHarnessStartsEmpty(t *testing.T) {
+	// Same usernames as TestShopFlowOverAPI: each harness has its own schema
+	h := newAPIHarness(t)
+	h.seedAccount("seller", "editor")
+
+	var count int64
+	if err := h.db.Model(&model.Product{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || len(h.deliverEmails()) != 0 {
+		t.Errorf("got %d products and queued emails in a new harness, want none", count)
+	}
+	if got := h.do("GET", "/v1/orders/", "", nil, nil); got != http.StatusUnauthorized {
+		t.Errorf("got %d listing orders anonymously, want 401", got)
+	}
+}
+
+// productStock reads a product's stock straight from the database.
+func (h *apiHarness) productStock(productID uint) int {
+	h.t.Helper()
+
+	var product model.Product
+	if err := h.db.First(&product, productID).Error; err != nil {
+		h.t.Fatal(err)
+	}
+	return product.Stock
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"instashop/internal/model"
+)
+
+func TestCartCheckout(t *testing.T) {
+	db := testDB(t)
+	seller := seedUser(t, db, "seller", "editor")
+	buyer := seedUser(t, db, "buyer", "user")
+	shirt := seedProduct(t, db, seller.ID, 12.5, 10)
+	socks := seedProduct(t, db, seller.ID, 3, 10)
+	cart := newCartService(db, newOrderService(db))
+	ctx := context.Background()
+
+	for _, add := range []struct {
+		productID uint
+		quantity  int
+	}{{shirt.ID, 1}, {socks.ID, 2}, {shirt.ID, 1}} {
+		if err := cart.AddItem(ctx, buyer.ID, add.productID, 0, add.quantity); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := cart.GetCart(ctx, buyer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Items) != 2 || summary.Total != 31 {
+		t.Fatalf("unexpected cart %+v", summary)
+	}
+
+	// A price change must be confirmed before checking out
+	if err := db.Model(socks).Update("price", 4).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cart.Checkout(ctx, buyer.ID); httpStatus(err) != http.StatusConflict {
+		t.Fatalf("got error %v after a price change, want a 409", err)
+	}
+
+	order, err := cart.Checkout(ctx, buyer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Total != 33 || len(order.Items) != 2 {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	var remaining int64
+	db.Model(&model.CartItem{}).Count(&remaining)
+	if remaining != 0 {
+		t.Errorf("cart still has %d items after checkout", remaining)
+	}
+
+	if _, err := cart.Checkout(ctx, buyer.ID); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v checking out an empty cart, want a 400", err)
+	}
+}
+
+func TestCheckoutRepricesWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	const buyerID = 1000
+	product := shop.seedProduct(t, 2000, 10, 5)
+	shop.store.AddCartItem(buyerID, &model.CartItem{ProductID: product.ID, Quantity: 2, UnitPrice: 10})
+
+	// The price changes after the item was added
+	product.Price = 12
+	if err := shop.store.Repositories().Products.Save(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shop.carts.Checkout(ctx, buyerID); httpStatus(err) != http.StatusConflict {
+		t.Fatalf("got error %v after a price change, want a 409", err)
+	}
+	items := shop.store.CartItems(buyerID)
+	if len(items) != 1 || items[0].UnitPrice != 12 {
+		t.Errorf("got cart %+v, want the item repriced to 12", items)
+	}
+	if got := shop.stock(t, product.ID); got != 5 {
+		t.Errorf("got stock %d after a refused checkout, want 5", got)
+	}
+
+	order, err := shop.carts.Checkout(ctx, buyerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Total != 24 || len(shop.store.CartItems(buyerID)) != 0 {
+		t.Errorf("got order total %v and cart %+v, want 24 and an empty cart", order.Total, shop.store.CartItems(buyerID))
+	}
+	if got := shop.stock(t, product.ID); got != 3 {
+		t.Errorf("got stock %d after checkout, want 3", got)
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"instashop/internal/model"
+	"instashop/internal/service"
+)
+
+func TestCatalogPagination(t *testing.T) {
+	db := testDB(t)
+	seller := seedUser(t, db, "seller", "editor")
+	for _, price := range []float64{30, 10, 20, 10, 40} {
+		seedProduct(t, db, seller.ID, price, 1)
+	}
+	pending := seedProduct(t, db, seller.ID, 5, 1)
+	if err := db.Model(pending).Update("status", model.StatusPending).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	catalog := newCatalogService(db)
+	query := service.CatalogQuery{Sort: service.CatalogSortPriceAsc, Limit: 2}
+
+	var prices []float64
+	seen := make(map[uint]bool)
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+
+		page, err := catalog.ListProducts(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, product := range page.Products {
+			if seen[product.ID] {
+				t.Fatalf("product %d returned twice", product.ID)
+			}
+			seen[product.ID] = true
+			prices = append(prices, product.Price)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	want := []float64{10, 10, 20, 30, 40}
+	if len(prices) != len(want) {
+		t.Fatalf("got prices %v, want %v", prices, want)
+	}
+	for i := range want {
+		if prices[i] != want[i] {
+			t.Fatalf("got prices %v, want %v", prices, want)
+		}
+	}
+}
+
+func TestCatalogSearch(t *testing.T) {
+	db := testDB(t)
+	seller := seedUser(t, db, "seller", "editor")
+	boots := seedProduct(t, db, seller.ID, 50, 1)
+	hat := seedProduct(t, db, seller.ID, 15, 1)
+	db.Model(boots).Updates(map[string]interface{}{"name": "Leather boots", "description": "Waterproof hiking boots"})
+	db.Model(hat).Updates(map[string]interface{}{"name": "Straw hat", "description": "Keeps the sun off"})
+
+	maxPrice := 100.0
+	page, err := newCatalogService(db).ListProducts(context.Background(), service.CatalogQuery{
+		Search:   "hiking",
+		MaxPrice: &maxPrice,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Products) != 1 || page.Products[0].ID != boots.ID {
+		t.Fatalf("unexpected search results %+v", page.Products)
+	}
+}
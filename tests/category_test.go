@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+	"instashop/internal/service"
+)
+
+func TestCategoryTree(t *testing.T) {
+	db := testDB(t)
+	categories := newCategoryService(db)
+	ctx := context.Background()
+
+	clothing, err := categories.CreateCategory(ctx, service.CategoryInput{Name: "Clothing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clothing.Slug != "clothing" {
+		t.Errorf("got slug %q, want clothing", clothing.Slug)
+	}
+	shirts, err := categories.CreateCategory(ctx, service.CategoryInput{Name: "Shirts", ParentID: &clothing.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tees, err := categories.CreateCategory(ctx, service.CategoryInput{Name: "T-Shirts", ParentID: &shirts.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := categories.CreateCategory(ctx, service.CategoryInput{Name: "Clothing"}); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got error %v reusing a slug, want a 409", err)
+	}
+	if _, err := categories.UpdateCategory(ctx, clothing.ID, service.CategoryUpdate{ParentID: &tees.ID}); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v moving a category under its descendant, want a 400", err)
+	}
+	if err := categories.DeleteCategory(ctx, shirts.ID); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got error %v deleting a category with subcategories, want a 409", err)
+	}
+
+	tree, err := categories.ListCategories(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 1 || len(tree[0].Children) != 1 || len(tree[0].Children[0].Children) != 1 || tree[0].Children[0].Children[0].ID != tees.ID {
+		t.Errorf("got tree %+v, want clothing > shirts > t-shirts", tree)
+	}
+
+	top := uint(0)
+	moved, err := categories.UpdateCategory(ctx, tees.ID, service.CategoryUpdate{ParentID: &top})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.ParentID != nil {
+		t.Errorf("got parent %v, want a top-level category", *moved.ParentID)
+	}
+}
+
+func TestCatalogTaxonomyFilters(t *testing.T) {
+	db := testDB(t)
+	categories := newCategoryService(db)
+	products := newProductService(db, mailer.NewDirect(mailer.NewCaptureTransport()))
+	catalog := newCatalogService(db)
+	ctx := context.Background()
+
+	clothing, err := categories.CreateCategory(ctx, service.CategoryInput{Name: "Clothing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shirts, err := categories.CreateCategory(ctx, service.CategoryInput{Name: "Shirts", ParentID: &clothing.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	books, err := categories.CreateCategory(ctx, service.CategoryInput{Name: "Books"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seller := seedUser(t, db, "seller", model.RoleEditor)
+	shirt := seedProduct(t, db, seller.ID, 10, 1)
+	jacket := seedProduct(t, db, seller.ID, 50, 1)
+	novel := seedProduct(t, db, seller.ID, 15, 1)
+
+	assign := func(product *model.Product, categoryID uint, tags ...string) {
+		t.Helper()
+		if tags == nil {
+			tags = []string{}
+		}
+		if _, err := products.UpdateProduct(ctx, product.ID, seller.ID, *product, service.ProductTaxonomy{CategoryID: &categoryID, Tags: tags}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assign(shirt, shirts.ID, "Summer", "cotton")
+	assign(jacket, clothing.ID, "winter")
+	assign(novel, books.ID, "summer ")
+
+	missing := uint(9999)
+	if _, err := products.UpdateProduct(ctx, novel.ID, seller.ID, *novel, service.ProductTaxonomy{CategoryID: &missing}); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v assigning a missing category, want a 400", err)
+	}
+
+	tests := []struct {
+		name  string
+		query service.CatalogQuery
+		want  []uint
+	}{
+		{"category subtree", service.CatalogQuery{Category: "clothing"}, []uint{shirt.ID, jacket.ID}},
+		{"leaf category", service.CatalogQuery{Category: "shirts"}, []uint{shirt.ID}},
+		{"tag", service.CatalogQuery{Tag: "summer"}, []uint{shirt.ID, novel.ID}},
+		{"category and tag", service.CatalogQuery{Category: "clothing", Tag: "summer"}, []uint{shirt.ID}},
+		{"unknown category", service.CatalogQuery{Category: "garden"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Sort = service.CatalogSortPriceAsc
+			page, err := catalog.ListProducts(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[uint]bool)
+			for _, product := range page.Products {
+				got[product.ID] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d products, want %d", len(got), len(tt.want))
+			}
+			for _, id := range tt.want {
+				if !got[id] {
+					t.Errorf("product %d missing from results", id)
+				}
+			}
+		})
+	}
+
+	found, err := products.GetProduct(ctx, shirt.ID, seller.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Category == nil || found.Category.ID != shirts.ID || len(found.Tags) != 2 {
+		t.Errorf("got category %+v and tags %+v, want shirts and two tags", found.Category, found.Tags)
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"instashop/internal/config"
+)
+
+// envMap looks settings up in a map instead of the process environment.
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+// requiredEnv holds the settings that have no default.
+func requiredEnv() map[string]string {
+	return map[string]string{
+		"DB_HOST":             "localhost",
+		"DB_USERNAME":         "instashop",
+		"DB_DATABASE":         "instashop",
+		"JWT_SECRET":          "secret",
+		"MAILTRAP_HOST":       "smtp.example.com",
+		"SENDER":              "shop@example.com",
+		"PAYSTACK_SECRET_KEY": "sk_test",
+	}
+}
+
+func TestParseConfigDefaults(t *testing.T) {
+	cfg, err := config.Parse(nil, envMap(requiredEnv()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Port != 8080 || cfg.Server.ShutdownTimeout != 15*time.Second || cfg.Database.Port != 5432 || cfg.Database.ConnMaxLifetime != 30*time.Minute {
+		t.Errorf("got port %d, shutdown timeout %s, db port %d, lifetime %s, want the defaults",
+			cfg.Server.Port, cfg.Server.ShutdownTimeout, cfg.Database.Port, cfg.Database.ConnMaxLifetime)
+	}
+	if cfg.Storage.Driver != "local" || cfg.Payment.Provider != "paystack" || cfg.Payment.Currency != "NGN" {
+		t.Errorf("got storage %q, payment %q in %q, want the defaults", cfg.Storage.Driver, cfg.Payment.Provider, cfg.Payment.Currency)
+	}
+	if got := cfg.Database.DSN(); got != "postgres://instashop:@localhost:5432/instashop?sslmode=disable" {
+		t.Errorf("got DSN %q", got)
+	}
+}
+
+func TestParseConfigEnvironmentOverridesYAML(t *testing.T) {
+	yaml := []byte(`
+server:
+  port: 9000
+database:
+  host: db.internal
+  max_open_conns: 10
+  conn_max_lifetime: 5m
+payment:
+  currency: USD
+`)
+	env := requiredEnv()
+	delete(env, "DB_HOST")
+	env["PORT"] = "9100"
+
+	cfg, err := config.Parse(yaml, envMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 9100 {
+		t.Errorf("got port %d, want the environment to win", cfg.Server.Port)
+	}
+	if cfg.Database.Host != "db.internal" || cfg.Database.MaxOpenConns != 10 || cfg.Database.ConnMaxLifetime != 5*time.Minute {
+		t.Errorf("got database %+v, want the YAML settings", cfg.Database)
+	}
+	if cfg.Payment.Currency != "USD" {
+		t.Errorf("got currency %q, want USD", cfg.Payment.Currency)
+	}
+}
+
+func TestParseConfigListsEveryProblem(t *testing.T) {
+	env := map[string]string{
+		"DB_PORT":          "not-a-port",
+		"STORAGE_DRIVER":   "cloudinary",
+		"PAYMENT_PROVIDER": "cash",
+		"PAYMENT_CURRENCY": "naira",
+	}
+
+	_, err := config.Parse(nil, envMap(env))
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got error %v, want a validation error", err)
+	}
+
+	want := []string{"DB_HOST", "DB_USERNAME", "DB_DATABASE", "JWT_SECRET", "MAILTRAP_HOST", "SENDER",
+		"CLOUDINARY_CLOUD_NAME", "CLOUDINARY_API_KEY", "CLOUDINARY_API_SECRET"}
+	if len(verr.Missing) != len(want) {
+		t.Fatalf("got missing %v, want %v", verr.Missing, want)
+	}
+	for i := range want {
+		if verr.Missing[i] != want[i] {
+			t.Fatalf("got missing %v, want %v", verr.Missing, want)
+		}
+	}
+	if len(verr.Invalid) != 3 {
+		t.Errorf("got invalid %v, want DB_PORT, PAYMENT_PROVIDER and PAYMENT_CURRENCY", verr.Invalid)
+	}
+}
+
+func TestParseDatabaseConfigIgnoresOtherSections(t *testing.T) {
+	env := map[string]string{
+		"DB_HOST":          "localhost",
+		"DB_USERNAME":      "instashop",
+		"DB_DATABASE":      "instashop",
+		"PORT":             "not-a-port",
+		"PAYMENT_PROVIDER": "cash",
+	}
+
+	db, err := config.ParseDatabase(nil, envMap(env))
+	if err != nil {
+		t.Fatalf("got %v with only the database settings, want none", err)
+	}
+	if db.Host != "localhost" || db.Port != 5432 || db.MaxOpenConns != 50 {
+		t.Errorf("got database %+v, want the settings and defaults", db)
+	}
+
+	delete(env, "DB_HOST")
+	env["DB_PORT"] = "0"
+	_, err = config.ParseDatabase(nil, envMap(env))
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) || len(verr.Missing) != 1 || verr.Missing[0] != "DB_HOST" || len(verr.Invalid) != 1 {
+		t.Errorf("got %v, want DB_HOST missing and DB_PORT invalid", err)
+	}
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"instashop/internal/middleware"
+	"instashop/internal/repository"
+	"instashop/internal/utils"
+)
+
+type errorEnvelope struct {
+	Success        bool        `json:"success"`
+	Message        string      `json:"message"`
+	Error          string      `json:"error"`
+	HTTPStatusCode int         `json:"httpStatusCode"`
+	Details        interface{} `json:"details"`
+}
+
+// serveError answers a request whose handler pushed err.
+func serveError(t *testing.T, err error) (int, errorEnvelope) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandlerMiddleware)
+	r.GET("/", func(c *gin.Context) {
+		_ = c.Error(err)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var body errorEnvelope
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not an error envelope: %s", rr.Body)
+	}
+	return rr.Code, body
+}
+
+func TestErrorHandlerMapsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"not found", utils.NewNotFoundError("product not found"), http.StatusNotFound, utils.CodeNotFound, "product not found"},
+		{"wrapped conflict", fmt.Errorf("checkout: %w", utils.NewConflictError("not enough stock")), http.StatusConflict, utils.CodeConflict, "not enough stock"},
+		{"validation", utils.NewValidationError("password is too short"), http.StatusUnprocessableEntity, utils.CodeValidation, "password is too short"},
+		{"forbidden", utils.NewForbiddenError("not your store"), http.StatusForbidden, utils.CodeForbidden, "not your store"},
+		{"missing record", fmt.Errorf("failed to retrieve order: %w", gorm.ErrRecordNotFound), http.StatusNotFound, utils.CodeNotFound, "Record not found"},
+		{"missing repository record", fmt.Errorf("failed to retrieve order: %w", repository.ErrNotFound), http.StatusNotFound, utils.CodeNotFound, "Record not found"},
+		{"pq unique violation", fmt.Errorf("failed to create store: %w", &pq.Error{Code: "23505"}), http.StatusConflict, utils.CodeDuplicateEntry, "Duplicate value entered"},
+		{"pgx foreign key violation", &pgconn.PgError{Code: "23503"}, http.StatusBadRequest, utils.CodeForeignKey, "Foreign key constraint error"},
+		{"pq check violation", &pq.Error{Code: "23514"}, http.StatusBadRequest, utils.CodeConstraintViolation, "Value violates a constraint"},
+		{"pq other", &pq.Error{Code: "53300", Message: "too many connections"}, http.StatusInternalServerError, utils.CodeInternal, "Internal Server Error"},
+		{"unknown", errors.New("dial tcp: connection refused"), http.StatusInternalServerError, utils.CodeInternal, "Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := serveError(t, tt.err)
+			if status != tt.status || body.HTTPStatusCode != tt.status {
+				t.Errorf("got status %d (%d in body), want %d", status, body.HTTPStatusCode, tt.status)
+			}
+			if body.Success || body.Error != tt.code || body.Message != tt.message {
+				t.Errorf("got %+v, want code %s and message %q", body, tt.code, tt.message)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerIncludesDetails(t *testing.T) {
+	details := map[string]string{"email": "is required"}
+	_, body := serveError(t, utils.NewValidationError("invalid input").WithDetails(details))
+
+	got, ok := body.Details.(map[string]interface{})
+	if !ok || got["email"] != "is required" {
+		t.Errorf("got details %#v", body.Details)
+	}
+}
+
+func TestAuthErrorsUseTheEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.NoRoute(middleware.HandleNotFound)
+	r.GET("/private", middleware.RequirePermission(middleware.PermUserManage), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for path, want := range map[string]struct {
+		status int
+		code   string
+	}{
+		"/private": {http.StatusUnauthorized, utils.CodeUnauthorized},
+		"/missing": {http.StatusNotFound, utils.CodeNotFound},
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var body errorEnvelope
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: response is not an error envelope: %s", path, rr.Body)
+		}
+		if rr.Code != want.status || body.Error != want.code {
+			t.Errorf("%s: got status %d and code %q, want %d and %q", path, rr.Code, body.Error, want.status, want.code)
+		}
+	}
+}
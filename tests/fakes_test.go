@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+	"instashop/internal/repository/memory"
+	"instashop/internal/service"
+)
+
+// fakeSessions stands in for the TokenService, recording whose sessions were
+// opened.
+type fakeSessions struct {
+	issued []uint
+}
+
+func (f *fakeSessions) IssueTokens(ctx context.Context, user *model.User) (*service.TokenPair, error) {
+	f.issued = append(f.issued, user.ID)
+	return &service.TokenPair{AccessToken: fmt.Sprintf("access-%d", user.ID), RefreshToken: fmt.Sprintf("refresh-%d", user.ID)}, nil
+}
+
+// fakeShop is the services on in-memory repositories, for testing business
+// rules without a database.
+type fakeShop struct {
+	store    *memory.Store
+	emails   *mailer.CaptureTransport
+	sessions *fakeSessions
+	users    *service.UserService
+	products *service.ProductService
+	orders   *service.OrderService
+	carts    *service.CartService
+}
+
+func newFakeShop() *fakeShop {
+	emails := mailer.NewCaptureTransport()
+	store := memory.New(mailer.NewDirect(emails))
+	sessions := &fakeSessions{}
+	orders := service.NewOderService(store.Repositories(), store)
+	return &fakeShop{
+		store:    store,
+		emails:   emails,
+		sessions: sessions,
+		users:    service.NewUserService(store.Repositories(), store, sessions),
+		products: service.NewProductService(store.Repositories(), store),
+		orders:   orders,
+		carts:    service.NewCartService(store.Repositories(), store, orders),
+	}
+}
+
+// seedProduct adds an approved product with its own store.
+func (f *fakeShop) seedProduct(t *testing.T, sellerID uint, price float64, stock int) *model.Product {
+	t.Helper()
+
+	store := &model.Store{OwnerID: sellerID, Name: "Test store", Slug: fmt.Sprintf("store-%d", sellerID)}
+	f.store.CreateStore(store)
+	product := &model.Product{
+		UserID:      sellerID,
+		StoreID:     store.ID,
+		Name:        "Test product",
+		Description: "A product used in tests",
+		Price:       price,
+		Stock:       stock,
+		Status:      model.StatusApproved,
+	}
+	if err := f.store.Repositories().Products.Create(context.Background(), product); err != nil {
+		t.Fatalf("failed to seed product: %v", err)
+	}
+	return product
+}
+
+// stock returns the current stock of a product.
+func (f *fakeShop) stock(t *testing.T, productID uint) int {
+	t.Helper()
+
+	product, err := f.store.Repositories().Products.FindByID(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return product.Stock
+}
+
+var emailCodePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// emailCode returns the six-digit code in an email.
+func emailCode(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+
+	code := emailCodePattern.FindString(msg.Text)
+	if code == "" {
+		t.Fatalf("no code in %q email: %s", msg.Template, msg.Text)
+	}
+	return code
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"instashop/internal/controller"
+	"instashop/internal/service"
+)
+
+// fakeDatabase reports fixed pool statistics.
+type fakeDatabase struct{}
+
+func (fakeDatabase) Ping(context.Context) error { return nil }
+func (fakeDatabase) Health() map[string]string {
+	return map[string]string{"status": "up", "open_connections": "3"}
+}
+func (fakeDatabase) Close() error      { return nil }
+func (fakeDatabase) GetGORM() *gorm.DB { return nil }
+
+func healthRouter(checks ...service.HealthCheck) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	health := controller.NewHealthController(service.NewHealthService(fakeDatabase{}, 50*time.Millisecond, checks...))
+
+	r := gin.New()
+	r.GET("/healthz", health.Liveness)
+	r.GET("/readyz", health.Readiness)
+	r.GET("/health", health.Details)
+	return r
+}
+
+func TestReadinessReportsEachCheck(t *testing.T) {
+	up := func(context.Context) error { return nil }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	broken := func(context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name   string
+		checks []service.HealthCheck
+		want   int
+	}{
+		{"all up", []service.HealthCheck{{Name: "database", Check: up}, {Name: "smtp", Check: up}}, http.StatusOK},
+		{"timed out", []service.HealthCheck{{Name: "database", Check: up}, {Name: "smtp", Check: slow}}, http.StatusServiceUnavailable},
+		{"down", []service.HealthCheck{{Name: "database", Check: broken}, {Name: "smtp", Check: up}}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			healthRouter(tt.checks...).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+			if rr.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, tt.want, rr.Body)
+			}
+
+			var body struct {
+				Ready  bool              `json:"ready"`
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Ready != (tt.want == http.StatusOK) || len(body.Checks) != 2 {
+				t.Errorf("got body %s", rr.Body)
+			}
+		})
+	}
+}
+
+func TestLivenessIgnoresDependencies(t *testing.T) {
+	broken := func(context.Context) error { return errors.New("connection refused") }
+
+	rr := httptest.NewRecorder()
+	healthRouter(service.HealthCheck{Name: "database", Check: broken}).ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("got status %d, want 200", rr.Code)
+	}
+}
+
+func TestHealthDetailsIncludeErrorsAndPoolStats(t *testing.T) {
+	broken := func(context.Context) error { return errors.New("connection refused") }
+
+	rr := httptest.NewRecorder()
+	healthRouter(service.HealthCheck{Name: "smtp", Check: broken}).ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+
+	var body struct {
+		Checks   map[string]service.CheckResult `json:"checks"`
+		Database map[string]string              `json:"database"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Checks["smtp"].Error != "connection refused" {
+		t.Errorf("got smtp check %+v, want its error", body.Checks["smtp"])
+	}
+	if body.Database["open_connections"] != "3" {
+		t.Errorf("got database stats %v", body.Database)
+	}
+}
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"instashop/internal/lifecycle"
+)
+
+func TestShutdownRunsHooksInReverseOrder(t *testing.T) {
+	manager := lifecycle.New()
+	var stopped []string
+	for _, name := range []string{"database", "mailer", "http server"} {
+		name := name
+		manager.OnShutdown(name, func(context.Context) error {
+			stopped = append(stopped, name)
+			if name == "mailer" {
+				return errors.New("boom")
+			}
+			return nil
+		})
+	}
+
+	err := manager.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to stop mailer: boom") {
+		t.Errorf("got error %v, want the mailer failure", err)
+	}
+	if want := []string{"http server", "mailer", "database"}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("got stop order %v, want %v", stopped, want)
+	}
+
+	// A second shutdown is a no-op
+	if err := manager.Shutdown(context.Background()); err != nil || len(stopped) != 3 {
+		t.Errorf("got error %v and %d stops after a second shutdown", err, len(stopped))
+	}
+}
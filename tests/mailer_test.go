@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+)
+
+func TestRenderEmailTemplates(t *testing.T) {
+	emails := []mailer.Email{
+		{To: "a@example.com", Template: mailer.TemplateVerifyEmail, Data: mailer.VerifyEmailData{Code: "123456", ExpiresIn: "10 minutes"}},
+		{To: "a@example.com", Template: mailer.TemplatePasswordReset, Data: mailer.PasswordResetData{Code: "654321", ExpiresIn: "10 minutes"}},
+		{To: "a@example.com", Template: mailer.TemplatePasswordChanged},
+		{To: "a@example.com", Template: mailer.TemplateAdminInvite, Data: mailer.AdminInviteData{Link: "https://example.com/invite?token=x", ExpiresAt: time.Now()}},
+		{To: "a@example.com", Template: mailer.TemplateProductReviewed, Data: mailer.ProductReviewedData{ProductName: "Mug", Approved: true}},
+	}
+	for _, email := range emails {
+		msg, err := mailer.Render(email)
+		if err != nil {
+			t.Errorf("%s: %v", email.Template, err)
+			continue
+		}
+		if msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+			t.Errorf("%s: got subject %q", email.Template, msg.Subject)
+		}
+		if strings.TrimSpace(msg.Text) == "" || !strings.Contains(msg.HTML, "<html>") {
+			t.Errorf("%s: got text %q and html %q", email.Template, msg.Text, msg.HTML)
+		}
+	}
+}
+
+func TestRenderEscapesHTMLOnly(t *testing.T) {
+	msg, err := mailer.Render(mailer.Email{
+		To:       "seller@example.com",
+		Template: mailer.TemplateProductReviewed,
+		Data:     mailer.ProductReviewedData{ProductName: "<b>Mug</b>", Reason: "Blurry photos"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Subject != `Your product "<b>Mug</b>" has been declined` {
+		t.Errorf("got subject %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Text, "<b>Mug</b> was declined") || !strings.Contains(msg.Text, "Reason: Blurry photos") {
+		t.Errorf("got text %q", msg.Text)
+	}
+	if strings.Contains(msg.HTML, "<b>Mug</b>") || !strings.Contains(msg.HTML, "&lt;b&gt;Mug&lt;/b&gt;") {
+		t.Errorf("product name is not escaped in %q", msg.HTML)
+	}
+}
+
+func TestRenderRejectsBadEmails(t *testing.T) {
+	if _, err := mailer.Render(mailer.Email{To: "a@example.com", Template: "welcome"}); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+	if _, err := mailer.Render(mailer.Email{Template: mailer.TemplatePasswordChanged}); err == nil {
+		t.Error("expected an error for a missing recipient")
+	}
+}
+
+func TestBackoffDoublesUpToTheMaximum(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		10: 10 * time.Minute,
+	} {
+		if got := mailer.Backoff(30*time.Second, 10*time.Minute, attempts); got != want {
+			t.Errorf("after %d attempts got %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestFileTransportWritesMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sink := mailer.NewFileTransport(dir, "shop@example.com")
+
+	err := mailer.NewDirect(sink).Send(context.Background(), mailer.Email{
+		To:       "buyer@example.com",
+		Template: mailer.TemplateVerifyEmail,
+		Data:     mailer.VerifyEmailData{Code: "123456", ExpiresIn: "10 minutes"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*-verify_email.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got files %v (%v), want one email", files, err)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: buyer@example.com", "From: shop@example.com", "text/plain", "text/html", "123456"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("email does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestOutboxWorkerDeliversQueuedEmails(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	capture := mailer.NewCaptureTransport()
+	worker := mailer.NewWorker(db, capture, mailer.WorkerConfig{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour})
+
+	if err := mailer.NewOutbox(db).Send(ctx, mailer.Email{To: "buyer@example.com", Template: mailer.TemplatePasswordChanged}); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := worker.ProcessBatch(ctx)
+	if err != nil || claimed != 1 {
+		t.Fatalf("claimed %d emails (%v), want 1", claimed, err)
+	}
+	if messages := capture.Messages(); len(messages) != 1 || messages[0].To != "buyer@example.com" || messages[0].Subject != "Your password was changed" {
+		t.Fatalf("got messages %+v", messages)
+	}
+
+	var email model.OutboxEmail
+	if err := db.First(&email).Error; err != nil {
+		t.Fatal(err)
+	}
+	if email.Status != model.EmailSent || email.SentAt == nil || email.Attempts != 1 {
+		t.Errorf("got email %+v, want it sent after one attempt", email)
+	}
+	if email.TextBody != "" || email.HTMLBody != "" {
+		t.Errorf("got bodies %q and %q, want them cleared once sent", email.TextBody, email.HTMLBody)
+	}
+
+	// Sent emails are not claimed again
+	if claimed, err := worker.ProcessBatch(ctx); err != nil || claimed != 0 {
+		t.Errorf("claimed %d emails (%v) after sending, want 0", claimed, err)
+	}
+}
+
+func TestOutboxWorkerRetriesWithBackoff(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	capture := mailer.NewCaptureTransport()
+	capture.SetErr(errors.New("connection refused"))
+	worker := mailer.NewWorker(db, capture, mailer.WorkerConfig{MaxAttempts: 2, Backoff: time.Minute, MaxBackoff: time.Hour})
+
+	if err := mailer.NewOutbox(db).Send(ctx, mailer.Email{To: "buyer@example.com", Template: mailer.TemplatePasswordChanged}); err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed, err := worker.ProcessBatch(ctx); err != nil || claimed != 1 {
+		t.Fatalf("claimed %d emails (%v), want 1", claimed, err)
+	}
+	var email model.OutboxEmail
+	if err := db.First(&email).Error; err != nil {
+		t.Fatal(err)
+	}
+	if email.Status != model.EmailPending || email.LastError != "connection refused" || !email.NextAttemptAt.After(time.Now().Add(30*time.Second)) {
+		t.Fatalf("got email %+v, want a retry scheduled about a minute out", email)
+	}
+	if email.TextBody == "" || email.HTMLBody == "" {
+		t.Fatal("the bodies of an email waiting for a retry were cleared")
+	}
+
+	// Not due yet
+	if claimed, err := worker.ProcessBatch(ctx); err != nil || claimed != 0 {
+		t.Fatalf("claimed %d emails (%v) before the backoff passed", claimed, err)
+	}
+
+	// The last attempt fails the email for good
+	if err := db.Model(&email).Update("next_attempt_at", gorm.Expr("NOW()")).Error; err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := worker.ProcessBatch(ctx); err != nil || claimed != 1 {
+		t.Fatalf("claimed %d emails (%v), want 1", claimed, err)
+	}
+	if err := db.First(&email, email.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if email.Status != model.EmailFailed || email.Attempts != 2 {
+		t.Errorf("got email %+v, want it failed after two attempts", email)
+	}
+	if email.TextBody != "" || email.HTMLBody != "" {
+		t.Errorf("got bodies %q and %q, want them cleared once failed", email.TextBody, email.HTMLBody)
+	}
+}
+
+func TestOutboxWithTxOnlyQueuesOnCommit(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	outbox := mailer.NewOutbox(db)
+
+	rollback := errors.New("rollback")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := outbox.WithTx(tx).Send(ctx, mailer.Email{To: "buyer@example.com", Template: mailer.TemplatePasswordChanged}); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("got error %v", err)
+	}
+
+	var count int64
+	if err := db.Model(&model.OutboxEmail{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("got %d queued emails after a rollback, want 0", count)
+	}
+}
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"instashop/internal/migrate"
+	"instashop/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20261017100000_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"20261017100000_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"000001_a.up.sql":           {Data: []byte("CREATE TABLE a ();")},
+		"000001_a.down.sql":         {Data: []byte("DROP TABLE a;")},
+		"migrations.go":             {Data: []byte("package migrations")},
+	}
+
+	loaded, err := migrate.Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 2 || loaded[0].Version != 1 || loaded[0].Name != "a" || loaded[1].Version != 20261017100000 {
+		t.Fatalf("got migrations %+v, want a then b", loaded)
+	}
+	if loaded[1].Up != "CREATE TABLE b ();" || loaded[1].Down != "DROP TABLE b;" {
+		t.Errorf("got scripts %q and %q for b", loaded[1].Up, loaded[1].Down)
+	}
+}
+
+func TestLoadMigrationsRejectsInvalidSets(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"missing down", fstest.MapFS{
+			"1_a.up.sql": {Data: []byte("SELECT 1;")},
+		}, "no down file"},
+		{"missing up", fstest.MapFS{
+			"1_a.down.sql": {Data: []byte("SELECT 1;")},
+		}, "no up file"},
+		{"duplicate version", fstest.MapFS{
+			"1_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"1_a.down.sql": {Data: []byte("SELECT 1;")},
+			"1_b.up.sql":   {Data: []byte("SELECT 1;")},
+			"1_b.down.sql": {Data: []byte("SELECT 1;")},
+		}, "used by both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := migrate.Load(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	loaded, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db := testDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.New(sqlDB, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || !status.Known {
+			t.Errorf("migration %d_%s is not applied after testDB", status.Version, status.Name)
+		}
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("got %d migrations, error %v re-running up, want none", len(applied), err)
+	}
+
+	rolledBack, err := migrator.Down(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := migrator.Migrations[len(migrator.Migrations)-1]
+	if len(rolledBack) != 2 || rolledBack[0].Version != last.Version {
+		t.Fatalf("got rolled back %+v, want the last two newest first", rolledBack)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[1].Version != last.Version {
+		t.Errorf("got applied %+v, want the two rolled back", applied)
+	}
+}
+
+func TestMigrateCommandOnlyNeedsDatabaseSettings(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+	}
+
+	adminDB, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adminDB.Close()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := adminDB.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { adminDB.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	bin := filepath.Join(t.TempDir(), "instashop")
+	if out, err := exec.Command("go", "build", "-o", bin, "instashop/cmd/api").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build: %v\n%s", err, out)
+	}
+
+	// Nothing but the database settings: no JWT secret, sender or payment keys
+	password, _ := u.User.Password()
+	env := []string{
+		"DB_HOST=" + u.Hostname(),
+		"DB_USERNAME=" + u.User.Username(),
+		"DB_PASSWORD=" + password,
+		"DB_DATABASE=" + strings.TrimPrefix(u.Path, "/"),
+		"PGOPTIONS=-c search_path=" + schema,
+	}
+	if port := u.Port(); port != "" {
+		env = append(env, "DB_PORT="+port)
+	}
+	if sslMode := u.Query().Get("sslmode"); sslMode != "" {
+		env = append(env, "DB_SSLMODE="+sslMode)
+	}
+	cmd := exec.Command(bin, "migrate", "up")
+	cmd.Env = env
+	cmd.Dir = t.TempDir()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("migrate up failed: %v\n%s", err, out)
+	}
+
+	var applied int
+	if err := adminDB.QueryRow("SELECT COUNT(*) FROM " + schema + ".schema_versions").Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := migrate.Load(migrations.FS); applied != len(loaded) {
+		t.Errorf("got %d migrations applied, want %d:\n%s", applied, len(loaded), out)
+	}
+}
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"instashop/internal/model"
+	"instashop/internal/service"
+)
+
+func TestPlaceOrderDoesNotOversell(t *testing.T) {
+	cases := []struct {
+		name   string
+		stock  int
+		buyers int
+	}{
+		{name: "last unit", stock: 1, buyers: 2},
+		{name: "many buyers", stock: 3, buyers: 10},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := testDB(t)
+			seller := seedUser(t, db, "seller", "editor")
+			buyer := seedUser(t, db, "buyer", "user")
+			product := seedProduct(t, db, seller.ID, 10, tc.stock)
+			orderService := newOrderService(db)
+
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			errs := make(chan error, tc.buyers)
+			for i := 0; i < tc.buyers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					_, err := orderService.PlaceOrder(context.Background(), buyer.ID, []service.OrderItemInput{
+						{ProductID: product.ID, Quantity: 1},
+					})
+					errs <- err
+				}()
+			}
+			close(start)
+			wg.Wait()
+			close(errs)
+
+			placed, conflicts := 0, 0
+			for err := range errs {
+				switch {
+				case err == nil:
+					placed++
+				case httpStatus(err) == http.StatusConflict:
+					conflicts++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+
+			if placed != tc.stock {
+				t.Errorf("placed %d orders, want %d", placed, tc.stock)
+			}
+			if conflicts != tc.buyers-tc.stock {
+				t.Errorf("got %d conflicts, want %d", conflicts, tc.buyers-tc.stock)
+			}
+
+			var reloaded model.Product
+			if err := db.First(&reloaded, product.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if reloaded.Stock != 0 {
+				t.Errorf("stock is %d after selling out, want 0", reloaded.Stock)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderRejectsInsufficientStock(t *testing.T) {
+	db := testDB(t)
+	seller := seedUser(t, db, "seller", "editor")
+	buyer := seedUser(t, db, "buyer", "user")
+	product := seedProduct(t, db, seller.ID, 10, 2)
+	orderService := newOrderService(db)
+
+	_, err := orderService.PlaceOrder(context.Background(), buyer.ID, []service.OrderItemInput{
+		{ProductID: product.ID, Quantity: 3},
+	})
+	if httpStatus(err) != http.StatusConflict {
+		t.Fatalf("got error %v, want a 409 conflict", err)
+	}
+
+	var reloaded model.Product
+	if err := db.First(&reloaded, product.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Stock != 2 {
+		t.Errorf("stock is %d after a rejected order, want 2", reloaded.Stock)
+	}
+}
+
+func TestCancelOrderReleasesStock(t *testing.T) {
+	db := testDB(t)
+	seller := seedUser(t, db, "seller", "editor")
+	buyer := seedUser(t, db, "buyer", "user")
+	product := seedProduct(t, db, seller.ID, 10, 5)
+	orderService := newOrderService(db)
+	ctx := context.Background()
+
+	order, err := orderService.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{
+		{ProductID: product.ID, Quantity: 2},
+		{ProductID: product.ID, Quantity: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order.Items) != 1 || order.Items[0].Quantity != 3 || order.Total != 30 {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	if err := orderService.CancelOrder(ctx, order.ID, buyer.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloaded model.Product
+	if err := db.First(&reloaded, product.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Stock != 5 {
+		t.Errorf("stock is %d after cancel, want 5", reloaded.Stock)
+	}
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	db := testDB(t)
+	seller := seedUser(t, db, "seller", "editor")
+	buyer := seedUser(t, db, "buyer", "user")
+	product := seedProduct(t, db, seller.ID, 10, 5)
+	orderService := newOrderService(db)
+	ctx := context.Background()
+
+	order, err := orderService.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{
+		{ProductID: product.ID, Quantity: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := seedUser(t, db, "admin", "admin")
+
+	if _, err := orderService.UpdateOrderStatus(ctx, order.ID, "shipped-ish", admin.ID); httpStatus(err) != http.StatusBadRequest {
+		t.Fatalf("got error %v for an unknown status, want a 400", err)
+	}
+
+	if _, err := orderService.UpdateOrderStatus(ctx, order.ID, model.OrderStatusShipped, admin.ID); httpStatus(err) != http.StatusConflict {
+		t.Fatalf("got error %v shipping a pending order, want a 409", err)
+	}
+
+	updated, err := orderService.UpdateOrderStatus(ctx, order.ID, model.OrderStatusDeclined, admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != model.OrderStatusDeclined {
+		t.Errorf("status is %q, want declined", updated.Status)
+	}
+
+	var reloaded model.Product
+	if err := db.First(&reloaded, product.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Stock != 5 {
+		t.Errorf("stock is %d after decline, want 5", reloaded.Stock)
+	}
+
+	history, err := orderService.GetOrderHistory(ctx, order.ID, buyer.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].ToStatus != model.OrderStatusDeclined || *history[1].ChangedBy != admin.ID {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	if _, err := orderService.GetOrderHistory(ctx, order.ID, seller.ID, false); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got error %v reading another user's history, want a 403", err)
+	}
+}
+
+func TestPlaceOrderRejectsUnapprovedProducts(t *testing.T) {
+	db := testDB(t)
+	seller := seedUser(t, db, "seller", "editor")
+	buyer := seedUser(t, db, "buyer", "user")
+	product := seedProduct(t, db, seller.ID, 10, 5)
+	if err := db.Model(product).Update("status", model.StatusPending).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := newOrderService(db).PlaceOrder(context.Background(), buyer.ID, []service.OrderItemInput{
+		{ProductID: product.ID, Quantity: 1},
+	})
+	if httpStatus(err) != http.StatusBadRequest {
+		t.Fatalf("got error %v ordering a pending product, want a 400", err)
+	}
+}
@@ -0,0 +1,280 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/controller"
+	"instashop/internal/middleware"
+	"instashop/internal/model"
+	"instashop/internal/repository"
+	"instashop/internal/service"
+)
+
+func TestPlaceAndCancelOrderWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	mug := shop.seedProduct(t, 1, 12.5, 5)
+	pen := shop.seedProduct(t, 1, 0.1, 10)
+
+	order, err := shop.orders.PlaceOrder(ctx, 2, []service.OrderItemInput{
+		{ProductID: mug.ID, Quantity: 1},
+		{ProductID: pen.ID, Quantity: 3},
+		{ProductID: mug.ID, Quantity: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order.Items) != 2 || order.Total != 25.3 || order.Status != model.OrderStatusPending {
+		t.Errorf("got %d items, total %v and status %s, want 2 items, total 25.3 and pending", len(order.Items), order.Total, order.Status)
+	}
+	if got := shop.stock(t, mug.ID); got != 3 {
+		t.Errorf("got mug stock %d, want 3", got)
+	}
+
+	// A failing order reserves nothing
+	if _, err := shop.orders.PlaceOrder(ctx, 2, []service.OrderItemInput{
+		{ProductID: pen.ID, Quantity: 1},
+		{ProductID: mug.ID, Quantity: 4},
+	}); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got %v ordering more than the stock, want 409", err)
+	}
+	if got := shop.stock(t, pen.ID); got != 7 {
+		t.Errorf("got pen stock %d after a failed order, want 7", got)
+	}
+
+	if err := shop.orders.CancelOrder(ctx, order.ID, 3); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got %v canceling another user's order, want 403", err)
+	}
+	if err := shop.orders.CancelOrder(ctx, order.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := shop.stock(t, mug.ID); got != 5 {
+		t.Errorf("got mug stock %d after canceling, want 5", got)
+	}
+	if err := shop.orders.CancelOrder(ctx, order.ID, 2); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v canceling twice, want 400", err)
+	}
+
+	history, err := shop.orders.GetOrderHistory(ctx, order.ID, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].ToStatus != model.OrderStatusCanceled {
+		t.Errorf("got history %+v, want placed then canceled", history)
+	}
+	if _, err := shop.orders.UpdateOrderStatus(ctx, order.ID, model.OrderStatusShipped, 1); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got %v shipping a canceled order, want 409", err)
+	}
+}
+
+func TestOrderLinesSnapshotPricesWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	mug := shop.seedProduct(t, 1, 12.5, 5)
+	pen := shop.seedProduct(t, 1, 0.1, 10)
+	shirt := shop.seedProduct(t, 1, 20, 0)
+	price := 25.0
+	large := &model.ProductVariant{ProductID: shirt.ID, SKU: "SHIRT-L", Price: &price, Stock: 2}
+	shop.store.CreateVariant(large)
+
+	order, err := shop.orders.PlaceOrder(ctx, 2, []service.OrderItemInput{
+		{ProductID: mug.ID, Quantity: 1},
+		{ProductID: pen.ID, Quantity: 3},
+		{ProductID: shirt.ID, VariantID: large.ID, Quantity: 1},
+		{ProductID: mug.ID, Quantity: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type line struct {
+		variantID uint
+		quantity  int
+		unitPrice float64
+		lineTotal float64
+	}
+	want := map[uint]line{
+		mug.ID:   {0, 2, 12.5, 25},
+		pen.ID:   {0, 3, 0.1, 0.3},
+		shirt.ID: {large.ID, 1, 25, 25},
+	}
+	check := func(when string, order *model.Order) {
+		t.Helper()
+		if len(order.Items) != len(want) || order.Total != 50.3 {
+			t.Errorf("%s: got %d lines totalling %v, want %d totalling 50.3", when, len(order.Items), order.Total, len(want))
+		}
+		for _, item := range order.Items {
+			got := line{quantity: item.Quantity, unitPrice: item.UnitPrice, lineTotal: item.LineTotal}
+			if item.VariantID != nil {
+				got.variantID = *item.VariantID
+			}
+			if got != want[item.ProductID] {
+				t.Errorf("%s: got line %+v for product %d, want %+v", when, got, item.ProductID, want[item.ProductID])
+			}
+		}
+	}
+	check("placed", order)
+
+	// Later price changes do not reach orders already placed
+	mug.Price = 15
+	if err := shop.store.Repositories().Products.Save(ctx, mug); err != nil {
+		t.Fatal(err)
+	}
+	newPrice := 30.0
+	large.Price = &newPrice
+	if err := shop.store.Repositories().Variants.Save(ctx, large); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := shop.store.Repositories().Orders.FindWithItems(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("after price changes", reloaded)
+}
+
+func TestUpdateOrderStatusLeavesPaymentToPaymentsWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	mug := shop.seedProduct(t, 1, 12.5, 5)
+
+	order, err := shop.orders.PlaceOrder(ctx, 2, []service.OrderItemInput{{ProductID: mug.ID, Quantity: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shop.orders.UpdateOrderStatus(ctx, order.ID, model.OrderStatusPaid, 1); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v marking an unpaid order paid, want 400", err)
+	}
+
+	// As the payment service does once the charge succeeds
+	order.Status = model.OrderStatusPaid
+	if err := shop.store.Repositories().Orders.Save(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shop.orders.UpdateOrderStatus(ctx, order.ID, model.OrderStatusCanceled, 1); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got %v canceling a paid order without a refund, want 409", err)
+	}
+	if got := shop.stock(t, mug.ID); got != 3 {
+		t.Errorf("got stock %d after a refused cancel, want 3", got)
+	}
+
+	if _, err := shop.orders.UpdateOrderStatus(ctx, order.ID, model.OrderStatusShipped, 1); err != nil {
+		t.Errorf("got %v shipping a paid order", err)
+	}
+}
+
+func TestPlaceOrderRulesWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	shirt := shop.seedProduct(t, 1, 20, 0)
+	price := 25.0
+	large := &model.ProductVariant{ProductID: shirt.ID, SKU: "SHIRT-L", Price: &price, Stock: 2}
+	shop.store.CreateVariant(large)
+
+	mug := shop.seedProduct(t, 1, 12.5, 5)
+	pending := shop.seedProduct(t, 1, 5, 5)
+	pending.Status = model.StatusPending
+	if err := shop.store.Repositories().Products.Save(ctx, pending); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		items  []service.OrderItemInput
+		status int
+	}{
+		{"no items", nil, http.StatusBadRequest},
+		{"unknown product", []service.OrderItemInput{{ProductID: 999, Quantity: 1}}, http.StatusNotFound},
+		{"not approved", []service.OrderItemInput{{ProductID: pending.ID, Quantity: 1}}, http.StatusBadRequest},
+		{"variant required", []service.OrderItemInput{{ProductID: shirt.ID, Quantity: 1}}, http.StatusBadRequest},
+		{"variant of another product", []service.OrderItemInput{{ProductID: mug.ID, VariantID: large.ID, Quantity: 1}}, http.StatusNotFound},
+		{"variant out of stock", []service.OrderItemInput{{ProductID: shirt.ID, VariantID: large.ID, Quantity: 3}}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := shop.orders.PlaceOrder(ctx, 2, tt.items); httpStatus(err) != tt.status {
+				t.Errorf("got %v, want %d", err, tt.status)
+			}
+		})
+	}
+
+	// Variants are priced and stocked on their own
+	order, err := shop.orders.PlaceOrder(ctx, 2, []service.OrderItemInput{{ProductID: shirt.ID, VariantID: large.ID, Quantity: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Total != 50 || order.Items[0].Variant == nil || order.Items[0].Variant.Stock != 0 {
+		t.Errorf("got total %v and variant %+v, want 50 and no stock left", order.Total, order.Items[0].Variant)
+	}
+}
+
+func TestUnitOfWorkRollsBackWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	mug := shop.seedProduct(t, 1, 12.5, 5)
+
+	failed := errors.New("failed")
+	err := shop.store.Do(ctx, func(tx repository.Repositories) error {
+		if err := tx.Products.AdjustStock(ctx, mug.ID, nil, -5); err != nil {
+			return err
+		}
+		if err := tx.Users.Create(ctx, &model.User{Email: "buyer@example.com", Username: "buyer"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("got %v, want the error of the unit of work", err)
+	}
+
+	if got := shop.stock(t, mug.ID); got != 5 {
+		t.Errorf("got stock %d after rolling back, want 5", got)
+	}
+	if _, err := shop.store.Repositories().Users.FindByEmail(ctx, "buyer@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("got %v looking up a rolled back user, want ErrNotFound", err)
+	}
+}
+
+func TestOrderHandlersWithoutDatabase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	shop := newFakeShop()
+	mug := shop.seedProduct(t, 1, 12.5, 1)
+	orders := controller.NewOrderController(shop.orders)
+
+	r := gin.New()
+	r.Use(middleware.ErrorHandlerMiddleware, func(c *gin.Context) {
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 2, Role: middleware.RoleUser})
+	})
+	r.POST("/orders", orders.PlaceOrderHandler)
+	r.GET("/orders", orders.ListOrdersHandler)
+
+	body := fmt.Sprintf(`{"items": [{"product_id": %d, "quantity": 1}]}`, mug.ID)
+	for _, want := range []int{http.StatusCreated, http.StatusConflict} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+		if rr.Code != want {
+			t.Fatalf("got status %d, want %d: %s", rr.Code, want, rr.Body)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	var listed struct {
+		Orders []model.Order `json:"orders"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Orders) != 1 || listed.Orders[0].Items[0].ProductID != mug.ID {
+		t.Errorf("got orders %+v, want the placed order", listed.Orders)
+	}
+}
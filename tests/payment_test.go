@@ -0,0 +1,226 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"instashop/internal/model"
+	"instashop/internal/payment"
+	"instashop/internal/service"
+)
+
+func TestPaystackProvider(t *testing.T) {
+	const secret = "sk_test_secret"
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Invalid key"})
+			return
+		}
+
+		switch r.URL.Path {
+		case "/transaction/initialize":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": true,
+				"data": map[string]interface{}{
+					"authorization_url": "https://checkout.paystack.com/abc",
+					"reference":         body["reference"],
+				},
+			})
+		case "/transaction/verify/ref_1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": true,
+				"data":   map[string]interface{}{"reference": "ref_1", "status": "success", "amount": 2500, "currency": "NGN"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Not found"})
+		}
+	}))
+	defer api.Close()
+
+	provider := payment.NewPaystackProvider(secret, api.URL)
+	ctx := context.Background()
+
+	charge, err := provider.InitializeCharge(ctx, payment.ChargeRequest{Reference: "ref_1", Email: "a@example.com", Amount: 2500, Currency: "NGN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charge.AuthorizationURL != "https://checkout.paystack.com/abc" || charge.Reference != "ref_1" {
+		t.Errorf("unexpected charge %+v", charge)
+	}
+
+	result, err := provider.VerifyCharge(ctx, "ref_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != payment.ChargeStatusSuccess || result.Amount != 2500 {
+		t.Errorf("unexpected verification %+v", result)
+	}
+
+	if _, err := provider.VerifyCharge(ctx, "missing"); err == nil {
+		t.Error("expected an error verifying an unknown charge")
+	}
+
+	payload := []byte(`{"event":"charge.success","data":{"reference":"ref_1","status":"success","amount":2500,"currency":"NGN"}}`)
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write(payload)
+	header := http.Header{}
+	header.Set("x-paystack-signature", hex.EncodeToString(mac.Sum(nil)))
+
+	event, err := provider.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Reference != "ref_1" || event.Status != payment.ChargeStatusSuccess {
+		t.Errorf("unexpected webhook event %+v", event)
+	}
+
+	// Refunds and disputes carry a transaction too but do not settle a charge
+	refund := []byte(`{"event":"refund.processed","data":{"reference":"ref_1","status":"reversed","amount":2500,"currency":"NGN"}}`)
+	mac = hmac.New(sha512.New, []byte(secret))
+	mac.Write(refund)
+	refundHeader := http.Header{}
+	refundHeader.Set("x-paystack-signature", hex.EncodeToString(mac.Sum(nil)))
+	if event, err := provider.ParseWebhook(refund, refundHeader); err != nil || event != nil {
+		t.Errorf("got %+v, %v for a refund event, want no result", event, err)
+	}
+
+	header.Set("x-paystack-signature", "00"+header.Get("x-paystack-signature")[2:])
+	if _, err := provider.ParseWebhook(payload, header); !errors.Is(err, payment.ErrInvalidSignature) {
+		t.Errorf("got error %v for a tampered signature, want ErrInvalidSignature", err)
+	}
+}
+
+func TestPaymentWebhookIsIdempotent(t *testing.T) {
+	db := testDB(t)
+	seller := seedUser(t, db, "seller", "editor")
+	buyer := seedUser(t, db, "buyer", "user")
+	product := seedProduct(t, db, seller.ID, 12.5, 5)
+	ctx := context.Background()
+
+	orderService := newOrderService(db)
+	provider := payment.NewFakeProvider("webhook-secret")
+	payments := newPaymentService(db, provider, orderService)
+
+	order, err := orderService.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{{ProductID: product.ID, Quantity: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := payments.InitializePayment(ctx, order.ID, buyer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Amount != 25 || p.AuthorizationURL == "" {
+		t.Fatalf("unexpected payment %+v", p)
+	}
+
+	payload, signature, err := provider.Complete(p.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := http.Header{}
+	forged.Set(payment.FakeSignatureHeader, provider.Sign([]byte("something else")))
+	if err := payments.HandleWebhook(ctx, payload, forged); httpStatus(err) != http.StatusUnauthorized {
+		t.Fatalf("got error %v for a forged webhook, want a 401", err)
+	}
+
+	header := http.Header{}
+	header.Set(payment.FakeSignatureHeader, signature)
+	for i := 0; i < 2; i++ {
+		if err := payments.HandleWebhook(ctx, payload, header); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+
+	var reloaded model.Order
+	if err := db.First(&reloaded, order.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Status != model.OrderStatusPaid {
+		t.Errorf("order status is %q, want paid", reloaded.Status)
+	}
+
+	var paidTransitions int64
+	db.Model(&model.OrderStatusHistory{}).
+		Where("order_id = ? AND to_status = ?", order.ID, model.OrderStatusPaid).
+		Count(&paidTransitions)
+	if paidTransitions != 1 {
+		t.Errorf("order moved to paid %d times, want 1", paidTransitions)
+	}
+}
+
+func TestPaymentWebhookAmountMismatchFailsPayment(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	buyer := &model.User{Email: "buyer@example.com", Username: "buyer", Password: "Str0ngPassw0rd"}
+	if err := shop.store.Repositories().Users.Create(ctx, buyer); err != nil {
+		t.Fatal(err)
+	}
+	product := shop.seedProduct(t, 1, 12.5, 5)
+
+	provider := payment.NewFakeProvider("webhook-secret")
+	payments := service.NewPaymentService(shop.store.Repositories(), shop.store, provider, shop.orders, "NGN", "")
+
+	order, err := shop.orders.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{{ProductID: product.ID, Quantity: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := payments.InitializePayment(ctx, order.ID, buyer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A settled charge for the wrong amount is acknowledged, not retried
+	payload, err := json.Marshal(payment.ChargeResult{Reference: p.Reference, Status: payment.ChargeStatusSuccess, Amount: 100, Currency: "NGN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set(payment.FakeSignatureHeader, provider.Sign(payload))
+	if err := payments.HandleWebhook(ctx, payload, header); err != nil {
+		t.Fatalf("got %v for a mismatched amount, want it acknowledged", err)
+	}
+
+	failed, err := shop.store.Repositories().Payments.FindByReferenceForUpdate(ctx, p.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Status != model.PaymentStatusFailed || failed.PaidAt != nil {
+		t.Errorf("got payment %+v, want it failed", failed)
+	}
+	reloaded, err := shop.store.Repositories().Orders.FindByID(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Status == model.OrderStatusPaid {
+		t.Error("order was paid by a mismatched charge")
+	}
+}
+
+func TestPaymentWebhookIgnoresOtherEvents(t *testing.T) {
+	shop := newFakeShop()
+	provider := payment.NewPaystackProvider("sk_test_secret", "")
+	payments := service.NewPaymentService(shop.store.Repositories(), shop.store, provider, shop.orders, "NGN", "")
+
+	payload := []byte(`{"event":"charge.dispute.create","data":{"reference":"unknown","status":"success","amount":2500}}`)
+	mac := hmac.New(sha512.New, []byte("sk_test_secret"))
+	mac.Write(payload)
+	header := http.Header{}
+	header.Set("x-paystack-signature", hex.EncodeToString(mac.Sum(nil)))
+	if err := payments.HandleWebhook(context.Background(), payload, header); err != nil {
+		t.Errorf("got %v for an unhandled event, want it acknowledged", err)
+	}
+}
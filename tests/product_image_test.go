@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"instashop/internal/model"
+	"instashop/internal/storage"
+	"instashop/internal/utils"
+)
+
+// pngImage is a 1x1 transparent PNG
+var pngImage, _ = base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==")
+
+// fileHeader builds the header of a file uploaded in a multipart form.
+func fileHeader(t *testing.T, filename string, content []byte) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("images", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(int64(len(content)) + 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = form.RemoveAll() })
+	return form.File["images"][0]
+}
+
+func TestUploadImage(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.NewLocalStorage(dir, "http://cdn.test/")
+	ctx := context.Background()
+
+	uploaded, err := utils.UploadImage(ctx, store, "products/1", fileHeader(t, "photo.txt", pngImage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.ContentType != "image/png" || !strings.HasSuffix(uploaded.Key, ".png") {
+		t.Errorf("got type %q and key %q, want a png", uploaded.ContentType, uploaded.Key)
+	}
+	if uploaded.URL != "http://cdn.test/"+uploaded.Key {
+		t.Errorf("got URL %q for key %q", uploaded.URL, uploaded.Key)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(uploaded.Key))); err != nil {
+		t.Errorf("uploaded file not stored: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"not an image", []byte("#!/bin/sh\necho hello\n")},
+		{"empty", nil},
+		{"too large", append(append([]byte{}, pngImage...), make([]byte, utils.MaxImageSize)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := utils.UploadImage(ctx, store, "products/1", fileHeader(t, "photo.png", tt.content))
+			if httpStatus(err) != http.StatusBadRequest {
+				t.Errorf("got error %v, want a 400", err)
+			}
+		})
+	}
+}
+
+func TestLocalStorageRejectsEscapingKeys(t *testing.T) {
+	store := storage.NewLocalStorage(t.TempDir(), "/uploads")
+	for _, key := range []string{"", "../secret", "a/../../b", "/etc/passwd"} {
+		if _, err := store.Put(context.Background(), key, strings.NewReader("x"), "text/plain"); err == nil {
+			t.Errorf("stored a file under key %q", key)
+		}
+	}
+}
+
+func TestProductImages(t *testing.T) {
+	db := testDB(t)
+	dir := t.TempDir()
+	images := newProductImageService(db, storage.NewLocalStorage(dir, "/uploads"))
+	ctx := context.Background()
+
+	owner := seedUser(t, db, "seller", model.RoleEditor)
+	outsider := seedUser(t, db, "outsider", model.RoleEditor)
+	product := seedProduct(t, db, owner.ID, 10, 5)
+
+	files := []*multipart.FileHeader{
+		fileHeader(t, "a.png", pngImage),
+		fileHeader(t, "b.png", pngImage),
+		fileHeader(t, "c.png", pngImage),
+	}
+	if _, err := images.AddImages(ctx, product.ID, outsider.ID, files); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got error %v adding images to another store's product, want a 403", err)
+	}
+
+	added, err := images.AddImages(ctx, product.ID, owner.ID, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 3 || !added[0].IsPrimary || added[1].IsPrimary || added[2].Position != 2 {
+		t.Fatalf("got images %+v, want three in order with the first primary", added)
+	}
+
+	if _, err := images.ReorderImages(ctx, product.ID, owner.ID, []uint{added[2].ID, added[0].ID}); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v reordering a subset, want a 400", err)
+	}
+	reordered, err := images.ReorderImages(ctx, product.ID, owner.ID, []uint{added[2].ID, added[0].ID, added[1].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reordered[0].ID != added[2].ID || reordered[0].Position != 0 {
+		t.Errorf("got first image %+v, want image %d", reordered[0], added[2].ID)
+	}
+
+	if _, err := images.SetPrimaryImage(ctx, product.ID, added[1].ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := images.DeleteImage(ctx, product.ID, added[1].ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := images.ListImages(ctx, product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].ID != added[2].ID || !listed[0].IsPrimary || listed[1].IsPrimary {
+		t.Errorf("got images %+v, want the first remaining image promoted to primary", listed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(added[1].StorageKey))); !os.IsNotExist(err) {
+		t.Errorf("deleted image still stored: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+	"instashop/internal/service"
+)
+
+func TestCreateProductWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+
+	if _, err := shop.products.CreateProduct(ctx, 1, 0, "Mug", "A mug", 12.5, 5, service.ProductTaxonomy{}); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v without a store, want 400", err)
+	}
+
+	store := &model.Store{OwnerID: 1, Name: "Mugs", Slug: "mugs"}
+	shop.store.CreateStore(store)
+	if _, err := shop.products.CreateProduct(ctx, 2, store.ID, "Mug", "A mug", 12.5, 5, service.ProductTaxonomy{}); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got %v adding to another seller's store, want 403", err)
+	}
+	shop.store.AddStaff(store.ID, 2)
+
+	kitchen := &model.Category{Name: "Kitchen", Slug: "kitchen"}
+	shop.store.CreateCategory(kitchen)
+	missing := uint(999)
+	if _, err := shop.products.CreateProduct(ctx, 2, store.ID, "Mug", "A mug", 12.5, 5, service.ProductTaxonomy{CategoryID: &missing}); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v for an unknown category, want 400", err)
+	}
+
+	product, err := shop.products.CreateProduct(ctx, 2, store.ID, "Mug", "A mug", 12.5, 5, service.ProductTaxonomy{
+		CategoryID: &kitchen.ID,
+		Tags:       []string{"Coffee", "coffee!", "Gifts"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Status != model.StatusPending || product.CategoryID == nil || *product.CategoryID != kitchen.ID {
+		t.Errorf("got status %s and category %v, want a pending product in kitchen", product.Status, product.CategoryID)
+	}
+	if len(product.Tags) != 2 || product.Tags[0].Slug != "coffee" || product.Tags[1].Slug != "gifts" {
+		t.Errorf("got tags %+v, want coffee and gifts", product.Tags)
+	}
+
+	// The failed attempt with an unknown category left nothing behind
+	products, err := shop.products.GetAllProductsByUserID(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 {
+		t.Errorf("got %d products, want 1", len(products))
+	}
+}
+
+func TestReviewProductWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	seller := &model.User{Email: "seller@example.com", Username: "seller"}
+	if err := shop.store.Repositories().Users.Create(ctx, seller); err != nil {
+		t.Fatal(err)
+	}
+	product := shop.seedProduct(t, seller.ID, 12.5, 5)
+	product.Status = model.StatusPending
+	if err := shop.store.Repositories().Products.Save(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := shop.products.ReviewProduct(ctx, product.ID, 9, model.StatusDeclined, " "); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v declining without a reason, want 400", err)
+	}
+	reviewed, err := shop.products.ReviewProduct(ctx, product.ID, 9, model.StatusDeclined, "Blurry photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reviewed.Status != model.StatusDeclined || reviewed.ReviewedBy == nil || *reviewed.ReviewedBy != 9 {
+		t.Errorf("got %+v, want declined by 9", reviewed)
+	}
+	if _, err := shop.products.ReviewProduct(ctx, product.ID, 9, model.StatusApproved, ""); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got %v reviewing twice, want 409", err)
+	}
+
+	messages := shop.emails.Messages()
+	if len(messages) != 1 || messages[0].To != seller.Email || messages[0].Template != mailer.TemplateProductReviewed {
+		t.Errorf("got emails %+v, want one review email to the seller", messages)
+	}
+}
+
+func TestManageProductAsStoreMemberWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	product := shop.seedProduct(t, 1, 12.5, 5)
+	product.Status = model.StatusPending
+	if err := shop.store.Repositories().Products.Save(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	shop.store.AddStaff(product.StoreID, 2)
+
+	// An editor who does not work at the store cannot touch its product
+	const outsider = 3
+	if _, err := shop.products.GetProduct(ctx, product.ID, outsider); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got %v reading another store's product, want 403", err)
+	}
+	if _, err := shop.products.UpdateProduct(ctx, product.ID, outsider, model.Product{Name: "Cup", Description: "A cup", Price: 1}, service.ProductTaxonomy{}); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got %v updating another store's product, want 403", err)
+	}
+	if _, err := shop.products.UpdateStock(ctx, product.ID, outsider, 0); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got %v restocking another store's product, want 403", err)
+	}
+	if err := shop.products.DeletePendingProduct(ctx, product.ID, outsider); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got %v deleting another store's product, want 403", err)
+	}
+	if got := shop.stock(t, product.ID); got != 5 {
+		t.Errorf("got stock %d after the outsider's attempts, want 5", got)
+	}
+
+	// Staff manage the products of the store they work at, not just their own
+	if _, err := shop.products.GetProduct(ctx, product.ID, 2); err != nil {
+		t.Errorf("staff could not read the store's product: %v", err)
+	}
+	updated, err := shop.products.UpdateProduct(ctx, product.ID, 2, model.Product{Name: "Cup", Description: "A cup", Price: 8}, service.ProductTaxonomy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Cup" || updated.Price != 8 {
+		t.Errorf("got %+v, want the staff member's update", updated)
+	}
+	if _, err := shop.products.UpdateStock(ctx, product.ID, 2, 9); err != nil {
+		t.Fatal(err)
+	}
+	if got := shop.stock(t, product.ID); got != 9 {
+		t.Errorf("got stock %d, want 9", got)
+	}
+	if err := shop.products.DeletePendingProduct(ctx, product.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shop.products.GetProduct(ctx, product.ID, 1); httpStatus(err) != http.StatusNotFound {
+		t.Errorf("got %v reading the deleted product, want 404", err)
+	}
+}
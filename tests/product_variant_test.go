@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+	"instashop/internal/service"
+)
+
+func TestProductVariants(t *testing.T) {
+	db := testDB(t)
+	variants := newProductVariantService(db)
+	ctx := context.Background()
+
+	seller := seedUser(t, db, "seller", model.RoleEditor)
+	outsider := seedUser(t, db, "outsider", model.RoleEditor)
+	product := seedProduct(t, db, seller.ID, 20, 0)
+
+	if _, err := variants.AddOption(ctx, product.ID, outsider.ID, service.OptionInput{Name: "Size", Values: []string{"S"}}); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got error %v adding an option to another store's product, want a 403", err)
+	}
+	if _, err := variants.AddOption(ctx, product.ID, seller.ID, service.OptionInput{Name: "Size", Values: []string{"S", "M", "s"}}); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v for duplicate option values, want a 400", err)
+	}
+	if _, err := variants.AddOption(ctx, product.ID, seller.ID, service.OptionInput{Name: "Size", Values: []string{"S", "M"}}); err != nil {
+		t.Fatal(err)
+	}
+	colour, err := variants.AddOption(ctx, product.ID, seller.ID, service.OptionInput{Name: "Colour", Values: []string{"Red", "Blue"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := 25.0
+	redM, err := variants.CreateVariant(ctx, product.ID, seller.ID, service.VariantInput{
+		SKU: "TEE-RED-M", Price: &price, Stock: 2, Options: map[string]string{"Size": "M", "colour": "red"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redM.Values) != 2 {
+		t.Errorf("got %d option values, want 2", len(redM.Values))
+	}
+
+	tests := []struct {
+		name   string
+		input  service.VariantInput
+		status int
+	}{
+		{"missing option", service.VariantInput{SKU: "TEE-S", Options: map[string]string{"Size": "S"}}, http.StatusBadRequest},
+		{"unknown value", service.VariantInput{SKU: "TEE-XL", Options: map[string]string{"Size": "XL", "Colour": "Red"}}, http.StatusBadRequest},
+		{"same options", service.VariantInput{SKU: "TEE-RED-M-2", Options: map[string]string{"Size": "M", "Colour": "Red"}}, http.StatusConflict},
+		{"same sku", service.VariantInput{SKU: "TEE-RED-M", Options: map[string]string{"Size": "S", "Colour": "Red"}}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := variants.CreateVariant(ctx, product.ID, seller.ID, tt.input); httpStatus(err) != tt.status {
+				t.Errorf("got error %v, want a %d", err, tt.status)
+			}
+		})
+	}
+
+	blueS, err := variants.CreateVariant(ctx, product.ID, seller.ID, service.VariantInput{
+		SKU: "TEE-BLUE-S", Stock: 1, Options: map[string]string{"Size": "S", "Colour": "Blue"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := variants.DeleteOption(ctx, product.ID, colour.ID, seller.ID); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got error %v deleting an option in use, want a 409", err)
+	}
+	if _, err := variants.AddOptionValue(ctx, product.ID, colour.ID, seller.ID, "Green"); err != nil {
+		t.Errorf("adding a value to an option in use: %v", err)
+	}
+
+	products := newProductService(db, mailer.NewDirect(mailer.NewCaptureTransport()))
+	found, err := products.GetProduct(ctx, product.ID, seller.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Options) != 2 || len(found.Variants) != 2 || len(found.Variants[0].Values) != 2 {
+		t.Errorf("got options %+v and variants %+v, want them nested in the product", found.Options, found.Variants)
+	}
+
+	newStock := 5
+	updated, err := variants.UpdateVariant(ctx, product.ID, blueS.ID, seller.ID, service.VariantUpdate{Stock: &newStock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Stock != 5 || updated.SKU != "TEE-BLUE-S" {
+		t.Errorf("got variant %+v after update", updated)
+	}
+}
+
+func TestOrderVariant(t *testing.T) {
+	db := testDB(t)
+	variants := newProductVariantService(db)
+	orders := newOrderService(db)
+	ctx := context.Background()
+
+	seller := seedUser(t, db, "seller", model.RoleEditor)
+	buyer := seedUser(t, db, "buyer", model.RoleUser)
+	product := seedProduct(t, db, seller.ID, 20, 0)
+
+	if _, err := variants.AddOption(ctx, product.ID, seller.ID, service.OptionInput{Name: "Size", Values: []string{"S", "M"}}); err != nil {
+		t.Fatal(err)
+	}
+	price := 25.0
+	large, err := variants.CreateVariant(ctx, product.ID, seller.ID, service.VariantInput{SKU: "TEE-M", Price: &price, Stock: 2, Options: map[string]string{"Size": "M"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := variants.CreateVariant(ctx, product.ID, seller.ID, service.VariantInput{SKU: "TEE-S", Stock: 1, Options: map[string]string{"Size": "S"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := orders.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{{ProductID: product.ID, Quantity: 1}}); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v ordering without a variant, want a 400", err)
+	}
+	if _, err := orders.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{{ProductID: product.ID, VariantID: small.ID, Quantity: 2}}); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got error %v ordering more than the variant stock, want a 409", err)
+	}
+
+	order, err := orders.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{
+		{ProductID: product.ID, VariantID: large.ID, Quantity: 2},
+		{ProductID: product.ID, VariantID: small.ID, Quantity: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order.Items) != 2 || order.Total != 70 {
+		t.Errorf("got %d items totalling %.2f, want 2 totalling 70 (2 x 25 override + 1 x 20 product price)", len(order.Items), order.Total)
+	}
+	for _, item := range order.Items {
+		if item.VariantID == nil || item.Variant == nil {
+			t.Errorf("order item %+v does not reference its variant", item)
+		}
+	}
+
+	var reserved model.ProductVariant
+	db.First(&reserved, large.ID)
+	if reserved.Stock != 0 {
+		t.Errorf("got variant stock %d after ordering, want 0", reserved.Stock)
+	}
+
+	if err := variants.DeleteVariant(ctx, product.ID, large.ID, seller.ID); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got error %v deleting an ordered variant, want a 409", err)
+	}
+
+	if err := orders.CancelOrder(ctx, order.ID, buyer.ID); err != nil {
+		t.Fatal(err)
+	}
+	var released model.ProductVariant
+	db.First(&released, large.ID)
+	if released.Stock != 2 {
+		t.Errorf("got variant stock %d after canceling, want 2", released.Stock)
+	}
+}
+
+func TestCartVariants(t *testing.T) {
+	db := testDB(t)
+	variants := newProductVariantService(db)
+	orders := newOrderService(db)
+	cart := newCartService(db, orders)
+	ctx := context.Background()
+
+	seller := seedUser(t, db, "seller", model.RoleEditor)
+	buyer := seedUser(t, db, "buyer", model.RoleUser)
+	product := seedProduct(t, db, seller.ID, 20, 0)
+
+	if _, err := variants.AddOption(ctx, product.ID, seller.ID, service.OptionInput{Name: "Size", Values: []string{"S", "M"}}); err != nil {
+		t.Fatal(err)
+	}
+	small, err := variants.CreateVariant(ctx, product.ID, seller.ID, service.VariantInput{SKU: "TEE-S", Stock: 5, Options: map[string]string{"Size": "S"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	price := 30.0
+	medium, err := variants.CreateVariant(ctx, product.ID, seller.ID, service.VariantInput{SKU: "TEE-M", Price: &price, Stock: 5, Options: map[string]string{"Size": "M"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cart.AddItem(ctx, buyer.ID, product.ID, 0, 1); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v adding a product without choosing a variant, want a 400", err)
+	}
+	for _, variantID := range []uint{small.ID, medium.ID, small.ID} {
+		if err := cart.AddItem(ctx, buyer.ID, product.ID, variantID, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := cart.GetCart(ctx, buyer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Items) != 2 || summary.Total != 70 {
+		t.Fatalf("got cart %+v, want two variant lines totalling 70", summary)
+	}
+
+	if err := cart.RemoveItem(ctx, buyer.ID, product.ID, medium.ID); err != nil {
+		t.Fatal(err)
+	}
+	order, err := cart.Checkout(ctx, buyer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order.Items) != 1 || order.Items[0].VariantID == nil || *order.Items[0].VariantID != small.ID || order.Items[0].Quantity != 2 {
+		t.Errorf("got order items %+v, want 2 of variant %d", order.Items, small.ID)
+	}
+}
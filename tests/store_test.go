@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"instashop/internal/model"
+	"instashop/internal/service"
+	"instashop/internal/storage"
+)
+
+func TestCreateStore(t *testing.T) {
+	db := testDB(t)
+	owner := seedUser(t, db, "seller", model.RoleEditor)
+	stores := newStoreService(db, newOrderService(db), storage.NewLocalStorage(t.TempDir(), "https://cdn.example.com"))
+	ctx := context.Background()
+
+	store, err := stores.CreateStore(ctx, owner.ID, service.StoreInput{Name: "Ada's Shoes & Bags"}, fileHeader(t, "logo.png", pngImage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Slug != "ada-s-shoes-bags" {
+		t.Errorf("got slug %q, want ada-s-shoes-bags", store.Slug)
+	}
+	if !strings.HasPrefix(store.LogoURL, "https://cdn.example.com/stores/") {
+		t.Errorf("got logo %q, want it under the stores folder", store.LogoURL)
+	}
+
+	if _, err := stores.CreateStore(ctx, owner.ID, service.StoreInput{Name: "Other", Slug: store.Slug}, nil); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got error %v reusing a slug, want a 409", err)
+	}
+	if _, err := stores.CreateStore(ctx, owner.ID, service.StoreInput{Name: "Other", Slug: "Not A Slug"}, nil); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v for an invalid slug, want a 400", err)
+	}
+
+	found, err := stores.GetStoreBySlug(ctx, store.Slug)
+	if err != nil || found.ID != store.ID {
+		t.Fatalf("got store %+v, error %v looking up by slug", found, err)
+	}
+}
+
+func TestFailedStoreUpdateDeletesUploadedLogo(t *testing.T) {
+	shop := newFakeShop()
+	dir := t.TempDir()
+	stores := service.NewStoreService(shop.store.Repositories(), shop.store, shop.orders, storage.NewLocalStorage(dir, "https://cdn.example.com"))
+	ctx := context.Background()
+
+	taken := &model.Store{OwnerID: 1, Name: "Taken", Slug: "taken"}
+	mine := &model.Store{OwnerID: 1, Name: "Mine", Slug: "mine"}
+	shop.store.CreateStore(taken)
+	shop.store.CreateStore(mine)
+
+	// The slug conflict is only found after the logo is uploaded
+	_, err := stores.UpdateStore(ctx, mine.ID, 1, service.StoreInput{Slug: taken.Slug}, fileHeader(t, "logo.png", pngImage))
+	if httpStatus(err) != http.StatusConflict {
+		t.Fatalf("got error %v reusing a slug, want a 409", err)
+	}
+
+	var files []string
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("the failed update left logos %v behind", files)
+	}
+}
+
+func TestStoreStaffManageStoreOrders(t *testing.T) {
+	db := testDB(t)
+	orders := newOrderService(db)
+	stores := newStoreService(db, orders, nil)
+	ctx := context.Background()
+
+	owner := seedUser(t, db, "seller", model.RoleEditor)
+	staff := seedUser(t, db, "helper", model.RoleUser)
+	outsider := seedUser(t, db, "outsider", model.RoleUser)
+	buyer := seedUser(t, db, "buyer", model.RoleUser)
+	otherSeller := seedUser(t, db, "other", model.RoleEditor)
+
+	product := seedProduct(t, db, owner.ID, 10, 5)
+	otherProduct := seedProduct(t, db, otherSeller.ID, 20, 5)
+	store := seedStore(t, db, owner.ID)
+
+	if _, err := stores.AddStaff(ctx, store.ID, staff.ID, outsider.Email); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got error %v when staff add staff, want a 403", err)
+	}
+	if _, err := stores.AddStaff(ctx, store.ID, owner.ID, staff.Email); err != nil {
+		t.Fatal(err)
+	}
+
+	own, err := orders.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{{ProductID: product.ID, Quantity: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed, err := orders.PlaceOrder(ctx, buyer.ID, []service.OrderItemInput{
+		{ProductID: product.ID, Quantity: 1},
+		{ProductID: otherProduct.ID, Quantity: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := stores.ListStoreOrders(ctx, store.ID, staff.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 {
+		t.Fatalf("got %d store orders, want 2", len(listed))
+	}
+	for _, order := range listed {
+		for _, item := range order.Items {
+			if item.ProductID != product.ID {
+				t.Errorf("order %d lists item for product %d from another store", order.ID, item.ProductID)
+			}
+		}
+	}
+
+	if _, err := stores.ListStoreOrders(ctx, store.ID, outsider.ID, ""); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got error %v listing orders as an outsider, want a 403", err)
+	}
+
+	approved, err := stores.UpdateStoreOrderStatus(ctx, store.ID, own.ID, staff.ID, model.OrderStatusApproved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.Status != model.OrderStatusApproved {
+		t.Errorf("got status %q, want approved", approved.Status)
+	}
+
+	if _, err := stores.UpdateStoreOrderStatus(ctx, store.ID, mixed.ID, staff.ID, model.OrderStatusApproved); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got error %v changing an order shared with another store, want a 403", err)
+	}
+	if _, err := stores.UpdateStoreOrderStatus(ctx, store.ID, own.ID, staff.ID, model.OrderStatusPaid); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v marking an order paid, want a 400", err)
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+
+	"instashop/internal/utils"
+)
+
+func TestRefreshTokenRotationAndReuse(t *testing.T) {
+	db := testDB(t)
+	user := seedUser(t, db, "buyer", "user")
+	tokens := newTokenService(db)
+	ctx := context.Background()
+
+	first, err := tokens.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := tokens.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Replaying the old token revokes the whole family
+	if _, err := tokens.Refresh(ctx, first.RefreshToken); httpStatus(err) != http.StatusUnauthorized {
+		t.Fatalf("got error %v reusing a refresh token, want a 401", err)
+	}
+	if _, err := tokens.Refresh(ctx, second.RefreshToken); httpStatus(err) != http.StatusUnauthorized {
+		t.Fatalf("got error %v after reuse detection, want a 401", err)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	db := testDB(t)
+	user := seedUser(t, db, "buyer", "user")
+	tokens := newTokenService(db)
+	ctx := context.Background()
+
+	pair, err := tokens.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &utils.Claims{}
+	if _, err := jwt.ParseWithClaims(pair.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := tokens.IsTokenRevoked(ctx, claims); err != nil || revoked {
+		t.Fatalf("fresh token revoked=%v err=%v", revoked, err)
+	}
+
+	if err := tokens.Logout(ctx, claims, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := tokens.IsTokenRevoked(ctx, claims); err != nil || !revoked {
+		t.Fatalf("token after logout revoked=%v err=%v", revoked, err)
+	}
+	if _, err := tokens.Refresh(ctx, pair.RefreshToken); httpStatus(err) != http.StatusUnauthorized {
+		t.Fatalf("got error %v refreshing after logout, want a 401", err)
+	}
+}
@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"instashop/internal/mailer"
+	"instashop/internal/model"
+	"instashop/internal/service"
+)
+
+func TestSignupAndVerifyErrors(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	users := newUserService(db, mailer.NewDirect(mailer.NewCaptureTransport()))
+
+	if err := users.CreateUser(ctx, &model.User{Email: "buyer@example.com"}); httpStatus(err) != http.StatusUnprocessableEntity {
+		t.Errorf("got %v for a signup without a password, want 422", err)
+	}
+	if err := users.CreateUser(ctx, &model.User{Email: "buyer@example.com", Username: "buyer", Password: "short"}); httpStatus(err) != http.StatusUnprocessableEntity {
+		t.Errorf("got %v for a weak password, want 422", err)
+	}
+	if err := users.CreateUser(ctx, &model.User{Email: "buyer@example.com", Username: "buyer", Password: "Str0ngPassw0rd"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.CreateUser(ctx, &model.User{Email: "buyer@example.com", Username: "other", Password: "Str0ngPassw0rd"}); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got %v for a taken email, want 409", err)
+	}
+
+	// A wrong code no longer verifies the email
+	if err := users.VerifyEmail("buyer@example.com", "000000x"); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v for a wrong code, want 400", err)
+	}
+	if err := users.VerifyEmail("nobody@example.com", "000000"); httpStatus(err) != http.StatusNotFound {
+		t.Errorf("got %v for an unknown email, want 404", err)
+	}
+	if _, err := users.Login("buyer@example.com", "Str0ngPassw0rd"); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got %v logging in unverified, want 403", err)
+	}
+	if _, err := users.Login("nobody@example.com", "Str0ngPassw0rd"); httpStatus(err) != http.StatusUnauthorized {
+		t.Errorf("got %v logging in as an unknown user, want 401", err)
+	}
+}
+
+func TestAccountLifecycleWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+
+	if err := shop.users.CreateUser(ctx, &model.User{Email: "buyer@example.com", Username: "buyer", Password: "Str0ngPassw0rd"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := shop.users.CreateUser(ctx, &model.User{Email: "buyer@example.com", Username: "other", Password: "Str0ngPassw0rd"}); httpStatus(err) != http.StatusConflict {
+		t.Errorf("got %v for a taken email, want 409", err)
+	}
+
+	// Only the successful signup sends a code
+	messages := shop.emails.Messages()
+	if len(messages) != 1 || messages[0].Template != mailer.TemplateVerifyEmail {
+		t.Fatalf("got emails %+v, want one verification email", messages)
+	}
+	if _, err := shop.users.Login("buyer@example.com", "Str0ngPassw0rd"); httpStatus(err) != http.StatusForbidden {
+		t.Errorf("got %v logging in unverified, want 403", err)
+	}
+	if err := shop.users.VerifyEmail("buyer@example.com", emailCode(t, messages[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := shop.users.Login("buyer@example.com", "wrong-Passw0rd"); httpStatus(err) != http.StatusUnauthorized {
+		t.Errorf("got %v for a wrong password, want 401", err)
+	}
+	tokens, err := shop.users.Login("buyer@example.com", "Str0ngPassw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.AccessToken == "" || len(shop.sessions.issued) != 1 {
+		t.Errorf("got tokens %+v and sessions %v, want one session", tokens, shop.sessions.issued)
+	}
+}
+
+func TestCreateAdminRecordsWhoCreatedIt(t *testing.T) {
+	shop := newFakeShop()
+
+	admin := &model.User{Email: "admin@example.com", Username: "admin", Password: "Str0ngPassw0rd", Role: model.RoleUser}
+	if err := shop.users.CreateAdmin(context.Background(), 7, admin); err != nil {
+		t.Fatal(err)
+	}
+	if admin.Role != model.RoleAdmin {
+		t.Errorf("got role %q, want admin", admin.Role)
+	}
+
+	changes := shop.store.RoleChanges()
+	if len(changes) != 1 || changes[0].UserID != admin.ID || changes[0].ToRole != model.RoleAdmin ||
+		changes[0].ChangedBy == nil || *changes[0].ChangedBy != 7 {
+		t.Errorf("got role changes %+v, want admin created by 7", changes)
+	}
+}
+
+func TestPasswordResetWithoutDatabase(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	user := &model.User{Email: "buyer@example.com", Username: "buyer", Password: "Str0ngPassw0rd"}
+	if err := shop.users.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown addresses look the same as known ones
+	if err := shop.users.ForgotPassword(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("got %v for an unknown email, want nil", err)
+	}
+	if err := shop.users.ForgotPassword(ctx, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	messages := shop.emails.Messages()
+	code := emailCode(t, messages[len(messages)-1])
+
+	tokens := service.NewTokenService(shop.store.Repositories(), shop.store, "test-secret")
+	pair, err := tokens.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := shop.users.ResetPassword(ctx, user.Email, "000000", "N3wPassw0rd!"); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v for a wrong code, want 400", err)
+	}
+
+	if err := shop.users.ResetPassword(ctx, user.Email, code, "N3wPassw0rd!"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.Refresh(ctx, pair.RefreshToken); httpStatus(err) != http.StatusUnauthorized {
+		t.Errorf("got %v refreshing a session from before the reset, want 401", err)
+	}
+	if err := shop.users.ResetPassword(ctx, user.Email, code, "An0therPassw0rd!"); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v reusing the code, want 400", err)
+	}
+
+	// The code is burned after too many wrong guesses
+	if err := shop.users.ForgotPassword(ctx, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	messages = shop.emails.Messages()
+	code = emailCode(t, messages[len(messages)-1])
+	for i := 0; i < 5; i++ {
+		_ = shop.users.ResetPassword(ctx, user.Email, "000000", "N3wPassw0rd!")
+	}
+	if err := shop.users.ResetPassword(ctx, user.Email, code, "N3wPassw0rd!"); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v for a burned code, want 400", err)
+	}
+
+	// Resets stay locked for a while, without telling the caller
+	sent := len(shop.emails.Messages())
+	if err := shop.users.ForgotPassword(ctx, user.Email); err != nil {
+		t.Errorf("got %v while locked, want nil", err)
+	}
+	if got := len(shop.emails.Messages()); got != sent {
+		t.Errorf("sent %d reset emails while locked, want none", got-sent)
+	}
+}
+
+func TestPasswordResetGuessesSurviveNewCodes(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	user := &model.User{Email: "buyer@example.com", Username: "buyer", Password: "Str0ngPassw0rd"}
+	if err := shop.users.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Asking for a new code after each wrong guess does not earn more guesses
+	var code string
+	for i := 0; i < 5; i++ {
+		if err := shop.users.ForgotPassword(ctx, user.Email); err != nil {
+			t.Fatal(err)
+		}
+		messages := shop.emails.Messages()
+		code = emailCode(t, messages[len(messages)-1])
+		_ = shop.users.ResetPassword(ctx, user.Email, "000000", "N3wPassw0rd!")
+	}
+	if err := shop.users.ResetPassword(ctx, user.Email, code, "N3wPassw0rd!"); httpStatus(err) != http.StatusBadRequest {
+		t.Errorf("got %v after five wrong guesses across codes, want 400", err)
+	}
+}
+
+func TestRefreshRejectsTokensFromBeforePasswordChange(t *testing.T) {
+	shop := newFakeShop()
+	ctx := context.Background()
+	user := &model.User{Email: "buyer@example.com", Username: "buyer", Password: "Str0ngPassw0rd"}
+	if err := shop.users.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := service.NewTokenService(shop.store.Repositories(), shop.store, "test-secret")
+	pair, err := tokens.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token that escaped revocation is still older than the password
+	changedAt := time.Now().Add(time.Second)
+	user.PasswordChangedAt = &changedAt
+	if err := shop.store.Repositories().Users.Save(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.Refresh(ctx, pair.RefreshToken); httpStatus(err) != http.StatusUnauthorized {
+		t.Errorf("got %v refreshing a token from before the password change, want 401", err)
+	}
+}
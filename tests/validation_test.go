@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"instashop/internal/controller"
+	"instashop/internal/middleware"
+	"instashop/internal/utils"
+	"instashop/internal/validation"
+)
+
+// validationRouter serves handlers that reject the request body before they
+// reach their service, so no services are needed.
+func validationRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	users := controller.NewUserController(nil, nil)
+	products := controller.NewProductController(nil)
+	orders := controller.NewOrderController(nil)
+
+	r := gin.New()
+	r.Use(middleware.ErrorHandlerMiddleware, func(c *gin.Context) {
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1, Role: middleware.RoleUser})
+	})
+	r.POST("/signup", users.CreateUser)
+	r.POST("/products", products.CreateProduct)
+	r.POST("/orders", orders.PlaceOrderHandler)
+	return r
+}
+
+func TestRequestValidationListsFailingFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		fields []validation.FieldError
+	}{
+		{
+			name:   "missing fields",
+			path:   "/signup",
+			body:   `{"email": "not-an-email"}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []validation.FieldError{
+				{Field: "username", Reason: "is required"},
+				{Field: "email", Reason: "must be a valid email address"},
+				{Field: "password", Reason: "is required"},
+			},
+		},
+		{
+			name:   "role cannot be chosen at signup",
+			path:   "/signup",
+			body:   `{"username": "ada", "email": "ada@example.com", "password": "Str0ngPassw0rd", "role": "admin"}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []validation.FieldError{{Field: "role", Reason: "is not allowed"}},
+		},
+		{
+			name:   "price and bounds",
+			path:   "/products",
+			body:   `{"name": "` + strings.Repeat("x", 256) + `", "description": "A mug", "price": 10.005, "stock": -1}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []validation.FieldError{
+				{Field: "name", Reason: "must be at most 255 characters long"},
+				{Field: "price", Reason: "must be a positive amount of at most 99999999.99 with no more than two decimal places"},
+				{Field: "stock", Reason: "must be at least 0"},
+			},
+		},
+		{
+			name:   "wrong type",
+			path:   "/products",
+			body:   `{"name": "Mug", "description": "A mug", "price": "12.50"}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []validation.FieldError{{Field: "price", Reason: "must be a number"}},
+		},
+		{
+			name:   "order items cannot carry prices",
+			path:   "/orders",
+			body:   `{"items": [{"product_id": 1, "quantity": 1, "unit_price": 0.01}]}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []validation.FieldError{{Field: "unit_price", Reason: "is not allowed"}},
+		},
+		{
+			name:   "nested fields",
+			path:   "/orders",
+			body:   `{"items": [{"product_id": 1, "quantity": 1}, {"quantity": 0}]}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []validation.FieldError{
+				{Field: "items[1].product_id", Reason: "is required"},
+				{Field: "items[1].quantity", Reason: "is required"},
+			},
+		},
+		{
+			name:   "not json",
+			path:   "/orders",
+			body:   `{"items": [`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	r := validationRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if rr.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, tt.status, rr.Body)
+			}
+
+			var body struct {
+				Error   string                  `json:"error"`
+				Details []validation.FieldError `json:"details"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.status == http.StatusUnprocessableEntity && body.Error != utils.CodeValidation {
+				t.Errorf("got code %q, want %q", body.Error, utils.CodeValidation)
+			}
+			if !reflect.DeepEqual(body.Details, tt.fields) {
+				t.Errorf("got fields %+v, want %+v", body.Details, tt.fields)
+			}
+		})
+	}
+}
+
+func TestCustomValidators(t *testing.T) {
+	for price, want := range map[float64]bool{
+		12.5: true, 0.01: true, validation.MaxPrice: true,
+		0: false, -1: false, 10.005: false, 100000000: false,
+	} {
+		if got := validation.IsPrice(price); got != want {
+			t.Errorf("IsPrice(%v) = %v, want %v", price, got, want)
+		}
+	}
+	for slug, want := range map[string]bool{
+		"summer-sale": true, "shoes2024": true,
+		"Summer": false, "a--b": false, "-a": false, "": false,
+	} {
+		if got := validation.IsSlug(slug); got != want {
+			t.Errorf("IsSlug(%q) = %v, want %v", slug, got, want)
+		}
+	}
+	for currency, want := range map[string]bool{"NGN": true, "USD": true, "ngn": false, "NAIRA": false} {
+		if got := validation.IsCurrency(currency); got != want {
+			t.Errorf("IsCurrency(%q) = %v, want %v", currency, got, want)
+		}
+	}
+}